@@ -0,0 +1,55 @@
+package api_handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"unified/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Access classifies the routes of this package for auth.Middleware
+func Access(c *gin.Context) auth.Request {
+	req := access(c, strings.TrimPrefix(c.FullPath(), "/ns/:namespace")) //namespaced routes need the same as the root ones
+	req.Namespace = c.Param("namespace")
+	return req
+}
+
+func access(c *gin.Context, path string) auth.Request {
+	key, hasKey := c.Params.Get("key")
+	switch {
+	case path == "/health" || path == "/healthz" || path == "/readyz":
+		return auth.Request{Public: true}
+	case path == "/ns" || path == "/metrics" || path == "/status" || path == "/_stats":
+		return auth.Request{Role: auth.RoleRead}
+	case path == "/_diff" || path == "/cache/reconcile" || strings.HasPrefix(path, "/cache/tags/"):
+		return auth.Request{Role: auth.RoleAdmin, AllKeys: true}
+	case hasKey && c.Request.Method == http.MethodGet:
+		return auth.Request{Role: auth.RoleRead, Keys: []string{key}}
+	case hasKey:
+		return auth.Request{Role: auth.RoleWrite, Keys: []string{key}}
+	case c.Request.Method == http.MethodGet: //getall, scan and events
+		return auth.Request{Role: auth.RoleRead, AllKeys: true, Prefix: c.Query("prefix")}
+	case c.Request.Method == http.MethodPost:
+		return auth.Request{Role: auth.RoleWrite, Keys: []string{bodyKey(c)}}
+	}
+	return auth.Request{Role: auth.RoleAdmin, AllKeys: true} //delete all
+}
+
+// bodyKey peeks at the key of a set request, leaving the body for the handler
+func bodyKey(c *gin.Context) string {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return ""
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(data))
+	var body struct {
+		Key string `json:"key"`
+	}
+	json.Unmarshal(data, &body)
+	return body.Key
+}
@@ -0,0 +1,33 @@
+package api_handler
+
+import (
+	"net/http"
+	"strings"
+
+	"unified/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditAction classifies the mutating routes of this package for logging.Audit, on both servers
+func AuditAction(c *gin.Context) (logging.Action, bool) {
+	path := strings.TrimPrefix(c.FullPath(), "/ns/:namespace")
+	method := c.Request.Method
+	switch {
+	case method == http.MethodGet || path == "":
+		return logging.Action{}, false
+	case path == "/cache/reconcile":
+		return logging.Action{Name: "reconcile"}, true
+	case strings.HasPrefix(path, "/cache/tags/"):
+		return logging.Action{Name: "invalidate_tag", Key: c.Param("tag")}, true
+	case strings.HasSuffix(path, "/ttl"):
+		return logging.Action{Name: "expire", Key: c.Param("key")}, true
+	case method == http.MethodPost:
+		return logging.Action{Name: "set", Key: bodyKey(c)}, true
+	case method == http.MethodDelete && c.Param("key") != "":
+		return logging.Action{Name: "delete", Key: c.Param("key")}, true
+	case method == http.MethodDelete:
+		return logging.Action{Name: "flush"}, true
+	}
+	return logging.Action{}, false
+}
@@ -0,0 +1,48 @@
+package api_handler
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"unified/events"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	eventBuffer      = 256 //events queued per client before it starts missing some
+	eventKeepAlive   = 15 * time.Second
+	eventContentType = "text/event-stream"
+)
+
+// streamEvents sends bus events as Server-Sent Events until the client disconnects or the bus is closed.
+// ?prefix= limits the stream to matching keys, flushes are always sent
+func streamEvents(c *gin.Context, bus *events.Bus) {
+	sub := bus.Subscribe(c.Query("prefix"), eventBuffer)
+	defer sub.Close()
+
+	c.Header("Content-Type", eventContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") //disable proxy buffering
+	c.Status(http.StatusOK)
+	c.Writer.Flush() //let the client see the stream open before the first event
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-sub.Events():
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Op, event)
+			return true
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			return true
+		}
+	})
+}
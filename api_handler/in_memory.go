@@ -4,15 +4,27 @@ import (
 	"net/http"
 	"time"
 
+	"unified/events"
 	"unified/in_memory"
+	"unified/tracing"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// memorySpan traces an operation on the in-memory cache as a child of the request's span
+func memorySpan(c *gin.Context, op string) trace.Span {
+	_, span := tracing.Start(c.Request.Context(), "tier memory", attribute.String("cache.tier", "memory"), attribute.String("cache.op", op))
+	return span
+}
+
 func SetupInMemoryRoutes(r *gin.Engine, cache in_memory.Cache) {
 	r.GET("/inmemory/:key", func(c *gin.Context) {
 		key := c.Param("key") //extract key from request
+		span := memorySpan(c, "get")
 		value, found := cache.Get(key)
+		tracing.End(span, nil)
 		if !found {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Key not found"})
 			return
@@ -21,10 +33,33 @@ func SetupInMemoryRoutes(r *gin.Engine, cache in_memory.Cache) {
 	})
 
 	r.GET("/inmemory", func(c *gin.Context) {
+		cursor, match, count, scan, err := scanQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		span := memorySpan(c, "getall")
+		if s, ok := cache.(scanner); ok && scan {
+			items, next, err := s.Scan(cursor, match, count)
+			tracing.End(span, err)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"items": items, "cursor": next})
+			return
+		}
 		items := cache.GetAll()
+		tracing.End(span, nil)
 		c.JSON(http.StatusOK, gin.H{"items": items})
 	})
 
+	if source, ok := cache.(interface{ Events() *events.Bus }); ok {
+		r.GET("/_inmemory/events", func(c *gin.Context) {
+			streamEvents(c, source.Events())
+		})
+	}
+
 	r.POST("/inmemory", func(c *gin.Context) {
 		var json struct {
 			Key        string `json:"key"`
@@ -35,13 +70,18 @@ func SetupInMemoryRoutes(r *gin.Engine, cache in_memory.Cache) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		span := memorySpan(c, "set")
 		cache.Set(json.Key, json.Value, time.Duration(json.Expiration)*time.Second)
+		tracing.End(span, nil)
 		c.JSON(http.StatusOK, gin.H{"message": "Successfully set value"})
 	})
 
 	r.DELETE("/inmemory/:key", func(c *gin.Context) {
 		key := c.Param("key") //extract key from request
-		if cache.Delete(key) {
+		span := memorySpan(c, "delete")
+		deleted := cache.Delete(key)
+		tracing.End(span, nil)
+		if deleted {
 			c.JSON(http.StatusOK, gin.H{"message": "Successfully deleted key"})
 		} else {
 			c.JSON(http.StatusNotFound, gin.H{"message": "Key is not found"})
@@ -49,7 +89,10 @@ func SetupInMemoryRoutes(r *gin.Engine, cache in_memory.Cache) {
 	})
 
 	r.DELETE("/inmemory", func(c *gin.Context) {
-		if cache.DeleteAll() {
+		span := memorySpan(c, "flush")
+		deleted := cache.DeleteAll()
+		tracing.End(span, nil)
+		if deleted {
 			c.JSON(http.StatusOK, gin.H{"message": "Successfully deleted all keys"})
 		} else {
 			c.JSON(http.StatusNotFound, gin.H{"message": "No keys found"})
@@ -0,0 +1,45 @@
+package api_handler
+
+import (
+	"errors"
+	"net/http"
+
+	"unified/multicache"
+	"unified/namespace"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context key of the namespace cache resolved for a request
+const namespaceCache = "namespace_cache"
+
+// SetupNamespaceRoutes serves every /cache route per tenant under /ns/:namespace, namespaces are created on first write
+func SetupNamespaceRoutes(r *gin.Engine, registry *namespace.Registry) {
+	//LIST
+	r.GET("/ns", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"namespaces": registry.Stats()})
+	})
+
+	group := r.Group("/ns/:namespace", func(c *gin.Context) {
+		lookup := registry.Get
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead { //reads never create one
+			lookup = registry.Lookup
+		}
+		ns, err := lookup(c.Param("namespace"))
+		switch {
+		case errors.Is(err, namespace.ErrInvalidName):
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": "error", "error": err.Error()})
+			return
+		case errors.Is(err, namespace.ErrTooMany):
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "error", "error": err.Error()})
+			return
+		case errors.Is(err, namespace.ErrNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"status": "error", "error": err.Error()})
+			return
+		}
+		c.Set(namespaceCache, ns.Cache)
+	})
+	cacheRoutes(group, func(c *gin.Context) *multicache.MultiCache {
+		return c.MustGet(namespaceCache).(*multicache.MultiCache)
+	})
+}
@@ -0,0 +1,42 @@
+package api_handler
+
+import (
+	"net/http"
+
+	"unified/health"
+	"unified/multicache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupProbeRoutes adds the orchestrator probes: /healthz while the process serves, /readyz while
+// readiness passes, and /status with the checks and the state of each tier of multiCache
+func SetupProbeRoutes(r gin.IRoutes, readiness *health.Readiness, multiCache *multicache.MultiCache) {
+	//LIVENESS
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	//READINESS
+	r.GET("/readyz", func(c *gin.Context) {
+		ready, checks := readiness.Check(c.Request.Context())
+		status := http.StatusOK
+		if !ready {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"ready": ready, "checks": checks})
+	})
+	//STATUS
+	r.GET("/status", func(c *gin.Context) {
+		ready, checks := readiness.Check(c.Request.Context())
+		stats := multiCache.Stats()
+		c.JSON(http.StatusOK, gin.H{
+			"status":         stats.Redis.Status, //ok or degraded
+			"ready":          ready,
+			"checks":         checks,
+			"uptime_seconds": int64(readiness.Uptime().Seconds()),
+			"memory":         stats.Memory,
+			"redis":          stats.Redis,
+			"tiers":          stats.Tiers,
+		})
+	})
+}
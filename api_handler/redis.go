@@ -1,11 +1,13 @@
 package api_handler
 
 import (
+	"errors"
 	"net/http"
 	"time"
 	"unified/redis_cache"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
 var cacheInstance *redis_cache.RedisCache
@@ -23,7 +25,7 @@ func SetupRedisRoutes(router *gin.Engine, cache *redis_cache.RedisCache) {
 type SetRequest struct {
 	Key   string `json:"key" binding:"required"` //struct tags
 	Value string `json:"value" binding:"required"`
-	TTL   int    `json:"ttl" binding:"required"`
+	TTL   int    `json:"ttl" binding:"gte=0"` //seconds, 0 never expires
 }
 
 func setHandler(c *gin.Context) {
@@ -33,7 +35,7 @@ func setHandler(c *gin.Context) {
 		return
 	}
 
-	err := cacheInstance.Set(req.Key, req.Value, time.Duration(req.TTL)*time.Second)
+	err := cacheInstance.WithContext(c.Request.Context()).Set(req.Key, req.Value, time.Duration(req.TTL)*time.Second)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -43,16 +45,39 @@ func setHandler(c *gin.Context) {
 
 func getHandler(c *gin.Context) {
 	key := c.Param("key")
-	value, err := cacheInstance.Get(key)
+	value, err := cacheInstance.WithContext(c.Request.Context()).Get(key)
+	if errors.Is(err, redis.Nil) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Key not found"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Key not found"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"key": key, "value": value})
 }
 
 func getAllHandler(c *gin.Context) {
-	values, err := cacheInstance.GetAll()
+	cursor, match, count, scan, err := scanQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if scan { //paged listing is wrapped to carry the cursor
+		values, next, err := cacheInstance.WithContext(c.Request.Context()).Scan(cursor, match, count)
+		if isInvalidCursor(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"values": values, "cursor": next})
+		return
+	}
+
+	values, err := cacheInstance.WithContext(c.Request.Context()).GetAll()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -62,7 +87,7 @@ func getAllHandler(c *gin.Context) {
 
 func deleteHandler(c *gin.Context) {
 	key := c.Param("key")
-	err := cacheInstance.Delete(key)
+	err := cacheInstance.WithContext(c.Request.Context()).Delete(key)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -71,7 +96,7 @@ func deleteHandler(c *gin.Context) {
 }
 
 func deleteAllHandler(c *gin.Context) {
-	err := cacheInstance.DeleteAll()
+	err := cacheInstance.WithContext(c.Request.Context()).DeleteAll()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
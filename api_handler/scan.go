@@ -0,0 +1,51 @@
+package api_handler
+
+import (
+	"errors"
+	"strconv"
+
+	"unified/in_memory"
+	"unified/redis_cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultScanCount = 100
+	maxScanCount     = 1000
+)
+
+// Cursor based listing, used by caches that support it
+type scanner interface {
+	Scan(cursor string, match string, count int) (map[string]interface{}, string, error)
+}
+
+// scanQuery reads ?cursor=&match=&prefix=&count=, scan is false when none are given and
+// the whole cache should be listed instead
+func scanQuery(c *gin.Context) (cursor string, match string, count int, scan bool, err error) {
+	cursor, hasCursor := c.GetQuery("cursor")
+	match, hasMatch := c.GetQuery("match")
+	prefix, hasPrefix := c.GetQuery("prefix")
+	countParam, hasCount := c.GetQuery("count")
+	if !hasCursor && !hasMatch && !hasPrefix && !hasCount {
+		return "", "", 0, false, nil
+	}
+
+	if hasMatch && hasPrefix {
+		return "", "", 0, true, errors.New("use either match or prefix")
+	}
+	if hasPrefix {
+		match = in_memory.GlobPrefix(prefix)
+	}
+	count = defaultScanCount
+	if hasCount {
+		if count, err = strconv.Atoi(countParam); err != nil || count <= 0 || count > maxScanCount {
+			return "", "", 0, true, errors.New("count must be between 1 and 1000")
+		}
+	}
+	return cursor, match, count, true, nil
+}
+
+func isInvalidCursor(err error) bool {
+	return errors.Is(err, in_memory.ErrInvalidCursor) || errors.Is(err, redis_cache.ErrInvalidCursor)
+}
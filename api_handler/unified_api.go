@@ -1,30 +1,48 @@
 package api_handler
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"unified/multicache"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
-func SetupUnifiedRoutes(multiCache *multicache.MultiCache) *gin.Engine {
-	r := gin.Default()
+// SetupUnifiedRoutes serves multiCache, middleware such as logging.Middleware or auth.Middleware runs before every route
+func SetupUnifiedRoutes(multiCache *multicache.MultiCache, middleware ...gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(middleware...)
+	cacheRoutes(r, func(*gin.Context) *multicache.MultiCache { return multiCache })
+	//HEALTH
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, multiCache.Health()) //degraded still serves from memory
+	})
+
+	return r
+}
+
+// cacheRoutes registers the /cache routes on r, serving the cache cacheOf picks for each request
+func cacheRoutes(r gin.IRoutes, cacheOf func(*gin.Context) *multicache.MultiCache) {
 	//SET
 	r.POST("/cache", func(c *gin.Context) {
+		multiCache := cacheOf(c)
 		var req struct {
 			Key   string      `json:"key"`
 			Value interface{} `json:"value"`
 			TTL   int         `json:"ttl"`
+			Tags  []string    `json:"tags"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		ttl := time.Duration(req.TTL) * time.Second
-		if err := multiCache.Set(req.Key, req.Value, ttl); err != nil {
+		ttl := time.Duration(req.TTL) * time.Second //0 never expires
+		if err := multiCache.SetContext(c.Request.Context(), req.Key, req.Value, ttl, req.Tags...); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
 			return
 		}
@@ -33,8 +51,17 @@ func SetupUnifiedRoutes(multiCache *multicache.MultiCache) *gin.Engine {
 	})
 	//GET
 	r.GET("/cache/:key", func(c *gin.Context) {
+		multiCache := cacheOf(c)
 		key := c.Param("key")
-		value, err := multiCache.Get(key)
+		value, err := multiCache.GetContext(c.Request.Context(), key)
+		if errors.Is(err, redis.Nil) {
+			c.JSON(http.StatusNotFound, gin.H{"status": "Key not found"})
+			return
+		}
+		if errors.Is(err, multicache.ErrRedisUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "error", "error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
 			return
@@ -47,9 +74,84 @@ func SetupUnifiedRoutes(multiCache *multicache.MultiCache) *gin.Engine {
 
 		c.JSON(http.StatusOK, gin.H{"key": key, "value": value})
 	})
+	//TTL
+	r.GET("/cache/:key/ttl", func(c *gin.Context) {
+		multiCache := cacheOf(c)
+		key := c.Param("key")
+		ttl, err := multiCache.TTLContext(c.Request.Context(), key)
+		if errors.Is(err, redis.Nil) {
+			c.JSON(http.StatusNotFound, gin.H{"status": "Key not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+			return
+		}
+
+		seconds := int64(ttl / time.Second)
+		if ttl < 0 { //no expiry
+			seconds = -1
+		}
+		c.JSON(http.StatusOK, gin.H{"key": key, "ttl": seconds})
+	})
+	//EXPIRE, PERSIST
+	r.PUT("/cache/:key/ttl", func(c *gin.Context) {
+		multiCache := cacheOf(c)
+		key := c.Param("key")
+		var req struct {
+			TTL *int `json:"ttl"` //seconds, 0 never expires
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.TTL == nil || *req.TTL < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "ttl must be 0 or more seconds"})
+			return
+		}
+
+		var found bool
+		var err error
+		if *req.TTL == 0 {
+			found, err = multiCache.PersistContext(c.Request.Context(), key)
+		} else {
+			found, err = multiCache.ExpireContext(c.Request.Context(), key, time.Duration(*req.TTL)*time.Second)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"status": "Key not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"key": key, "ttl": *req.TTL})
+	})
 	//GETALL
 	r.GET("/cache", func(c *gin.Context) {
-		values, err := multiCache.GetAll()
+		multiCache := cacheOf(c)
+		cursor, match, count, scan, err := scanQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": err.Error()})
+			return
+		}
+		if scan {
+			values, next, err := multiCache.ScanContext(c.Request.Context(), cursor, match, count)
+			switch {
+			case isInvalidCursor(err):
+				c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": err.Error()})
+			case errors.Is(err, multicache.ErrRedisUnavailable):
+				c.JSON(http.StatusServiceUnavailable, gin.H{"status": "error", "error": err.Error()})
+			case err != nil:
+				c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+			default:
+				c.JSON(http.StatusOK, gin.H{"values": values, "cursor": next})
+			}
+			return
+		}
+
+		values, err := multiCache.GetAllContext(c.Request.Context())
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
 			return
@@ -57,25 +159,78 @@ func SetupUnifiedRoutes(multiCache *multicache.MultiCache) *gin.Engine {
 
 		c.JSON(http.StatusOK, gin.H{"values": values})
 	})
+	//STATS, outside /cache so a key named stats stays reachable
+	r.GET("/_stats", func(c *gin.Context) {
+		multiCache := cacheOf(c)
+		c.JSON(http.StatusOK, multiCache.Stats())
+	})
+	//EVENTS
+	r.GET("/_events", func(c *gin.Context) {
+		multiCache := cacheOf(c)
+		streamEvents(c, multiCache.Events())
+	})
+	//DIFF
+	r.GET("/_diff", func(c *gin.Context) {
+		multiCache := cacheOf(c)
+		divergences, err := multiCache.DiffContext(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"count": len(divergences), "divergences": divergences})
+	})
+	//RECONCILE
+	r.POST("/cache/reconcile", func(c *gin.Context) {
+		multiCache := cacheOf(c)
+		authority := multicache.TierRedis
+		switch c.DefaultQuery("authority", "redis") {
+		case "redis":
+		case "memory":
+			authority = multicache.TierMemory
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "authority must be redis or memory"})
+			return
+		}
+
+		divergences, err := multiCache.ReconcileContext(c.Request.Context(), authority)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"authority": authority.String(), "repaired": len(divergences), "divergences": divergences})
+	})
 	//DELETE
 	r.DELETE("/cache/:key", func(c *gin.Context) {
+		multiCache := cacheOf(c)
 		key := c.Param("key")
-		if err := multiCache.Delete(key); err != nil {
+		if err := multiCache.DeleteContext(c.Request.Context(), key); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{"status": "Key deleted successfully"})
 	})
+	//INVALIDATE TAG
+	r.DELETE("/cache/tags/:tag", func(c *gin.Context) {
+		multiCache := cacheOf(c)
+		keys, err := multiCache.InvalidateTagContext(c.Request.Context(), c.Param("tag"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "Tag invalidated", "deleted": len(keys), "keys": keys})
+	})
 	//DELETEALL
 	r.DELETE("/cache", func(c *gin.Context) {
-		if err := multiCache.DeleteAll(); err != nil {
+		multiCache := cacheOf(c)
+		if err := multiCache.DeleteAllContext(c.Request.Context()); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{"status": "All keys deleted successfully"})
 	})
-
-	return r
 }
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"net/http"
+)
+
+// Header carrying an API key
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys authenticates static keys sent in the X-API-Key header
+type APIKeys struct {
+	principals map[[sha256.Size]byte]*Principal //by key hash, so lookups do not leak key bytes through timing
+}
+
+func NewAPIKeys() *APIKeys {
+	return &APIKeys{principals: make(map[[sha256.Size]byte]*Principal)}
+}
+
+// Add registers key for principal, configure before serving
+func (a *APIKeys) Add(key string, principal *Principal) {
+	a.principals[sha256.Sum256([]byte(key))] = principal
+}
+
+func (a *APIKeys) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	principal, ok := a.principals[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return principal, nil
+}
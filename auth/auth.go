@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrExpired            = errors.New("credentials expired")
+)
+
+// Role grants a permission and everything below it
+type Role int
+
+const (
+	RoleRead  Role = iota + 1 //get, ttl, scan, stats, events
+	RoleWrite                 //set, expire, delete single keys
+	RoleAdmin                 //flush, tag invalidation, reconcile
+)
+
+func ParseRole(s string) (Role, error) {
+	switch s {
+	case "read":
+		return RoleRead, nil
+	case "write":
+		return RoleWrite, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return 0, fmt.Errorf("unknown role %q, want read, write or admin", s)
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleRead:
+		return "read"
+	case RoleWrite:
+		return "write"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+// Principal is an authenticated caller
+type Principal struct {
+	Name       string
+	Role       Role
+	Prefixes   []string //keys the caller may touch, empty for every key
+	Namespaces []string //namespaces the caller may touch, "" for the root keyspace
+}
+
+// Request is what an HTTP request needs, as classified for the route
+type Request struct {
+	Public    bool     //no credentials needed
+	Role      Role     //minimum role
+	Namespace string   //keyspace of Keys, empty for the root one
+	Keys      []string //keys read or written
+	AllKeys   bool     //spans the keyspace, e.g. getall or flush
+	Prefix    string   //narrows an AllKeys read to keys with this prefix
+}
+
+// Allows reports whether p may perform req. A caller restricted by prefixes or namespaces only
+// reaches the namespaces it lists, the root keyspace when it lists none
+func (p *Principal) Allows(req Request) bool {
+	if p.Role < req.Role {
+		return false
+	}
+	if len(p.Prefixes) == 0 && len(p.Namespaces) == 0 {
+		return true
+	}
+	if !p.reaches(req.Namespace) {
+		return false
+	}
+	if len(p.Prefixes) == 0 {
+		return true
+	}
+	if req.AllKeys {
+		return req.Prefix != "" && p.covers(req.Prefix)
+	}
+	for _, key := range req.Keys {
+		if !p.covers(key) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Principal) reaches(namespace string) bool {
+	if len(p.Namespaces) == 0 {
+		return namespace == ""
+	}
+	return slices.Contains(p.Namespaces, namespace)
+}
+
+func (p *Principal) covers(key string) bool {
+	for _, prefix := range p.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator validates the credentials of a request, ErrNoCredentials if it carries none it understands
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain tries each authenticator in turn until one finds credentials
+type Chain []Authenticator
+
+func (chain Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range chain {
+		principal, err := authenticator.Authenticate(r)
+		if !errors.Is(err, ErrNoCredentials) {
+			return principal, err
+		}
+	}
+	return nil, ErrNoCredentials
+}
+
+// Context key of the authenticated principal
+const principalKey = "auth_principal"
+
+// PrincipalOf returns the caller authenticated by Middleware, nil for public routes
+func PrincipalOf(c *gin.Context) *Principal {
+	if principal, ok := c.Get(principalKey); ok {
+		return principal.(*Principal)
+	}
+	return nil
+}
+
+// Middleware authenticates every routed request and checks it against classify's verdict:
+// 401 without valid credentials, 403 when the role, namespaces or key prefixes do not allow it
+func Middleware(authenticator Authenticator, classify func(*gin.Context) Request) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.FullPath() == "" { //unrouted, let it 404
+			c.Next()
+			return
+		}
+		req := classify(c)
+		if req.Public {
+			c.Next()
+			return
+		}
+		principal, err := authenticator.Authenticate(c.Request)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="cache"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "error": err.Error()})
+			return
+		}
+		c.Set(principalKey, principal) //also kept when forbidden, for the audit log
+		if !principal.Allows(req) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "error", "error": "forbidden for " + principal.Name})
+			return
+		}
+		c.Next()
+	}
+}
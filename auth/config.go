@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Config is the JSON credentials file given with -auth-file
+type Config struct {
+	HMACSecret string      `json:"hmac_secret"` //enables bearer tokens, empty to disable
+	APIKeys    []KeyConfig `json:"api_keys"`
+}
+
+type KeyConfig struct {
+	Name       string   `json:"name"`
+	Key        string   `json:"key"`
+	Role       string   `json:"role"`
+	Prefixes   []string `json:"prefixes"`
+	Namespaces []string `json:"namespaces"`
+}
+
+// LoadConfig reads a credentials file and builds the authenticator it describes
+func LoadConfig(path string) (Authenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("auth config: %w", err)
+	}
+	return config.Authenticator()
+}
+
+func (config Config) Authenticator() (Authenticator, error) {
+	var chain Chain
+	if len(config.APIKeys) > 0 {
+		keys := NewAPIKeys()
+		for _, k := range config.APIKeys {
+			if k.Key == "" {
+				return nil, fmt.Errorf("auth config: api key %q is empty", k.Name)
+			}
+			role, err := ParseRole(k.Role)
+			if err != nil {
+				return nil, fmt.Errorf("auth config: api key %q: %w", k.Name, err)
+			}
+			keys.Add(k.Key, &Principal{Name: k.Name, Role: role, Prefixes: k.Prefixes, Namespaces: k.Namespaces})
+		}
+		chain = append(chain, keys)
+	}
+	if config.HMACSecret != "" {
+		chain = append(chain, NewHMACTokens([]byte(config.HMACSecret)))
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("auth config: no api keys or hmac secret")
+	}
+	return chain, nil
+}
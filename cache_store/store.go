@@ -0,0 +1,117 @@
+// Package cache_store adapts the caches to the protocol servers, such as resp_server and memcache_server
+package cache_store
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"unified/in_memory"
+	"unified/multicache"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store is the cache a protocol server exposes
+type Store interface {
+	Get(key string) (interface{}, bool, error)
+	Set(key string, value interface{}, ttl time.Duration) error
+	Delete(key string) (bool, error)
+	DeleteAll() error
+	TTL(key string) (time.Duration, bool, error)
+}
+
+// Serve the unified cache
+func MultiCacheStore(mc *multicache.MultiCache) Store {
+	return multiCacheStore{mc}
+}
+
+// Serve the in-memory cache alone
+func InMemoryStore(cache *in_memory.LRUCache) Store {
+	return inMemoryStore{cache}
+}
+
+type multiCacheStore struct {
+	mc *multicache.MultiCache
+}
+
+func (s multiCacheStore) Get(key string) (interface{}, bool, error) {
+	value, err := s.mc.Get(key)
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, value != nil, nil
+}
+
+func (s multiCacheStore) Set(key string, value interface{}, ttl time.Duration) error {
+	return s.mc.Set(key, value, ttl)
+}
+
+func (s multiCacheStore) Delete(key string) (bool, error) {
+	_, exists, err := s.TTL(key)
+	if err != nil {
+		return false, err
+	}
+	return exists, s.mc.Delete(key)
+}
+
+func (s multiCacheStore) DeleteAll() error {
+	return s.mc.DeleteAll()
+}
+
+func (s multiCacheStore) TTL(key string) (time.Duration, bool, error) {
+	ttl, err := s.mc.TTL(key)
+	if errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return ttl, true, nil
+}
+
+type inMemoryStore struct {
+	cache *in_memory.LRUCache
+}
+
+func (s inMemoryStore) Get(key string) (interface{}, bool, error) {
+	value, ok := s.cache.Get(key)
+	return value, ok, nil
+}
+
+func (s inMemoryStore) Set(key string, value interface{}, ttl time.Duration) error {
+	s.cache.Set(key, value, ttl)
+	return nil
+}
+
+func (s inMemoryStore) Delete(key string) (bool, error) {
+	return s.cache.Delete(key), nil
+}
+
+func (s inMemoryStore) DeleteAll() error {
+	s.cache.DeleteAll()
+	return nil
+}
+
+func (s inMemoryStore) TTL(key string) (time.Duration, bool, error) {
+	ttl, ok := s.cache.TTL(key)
+	return ttl, ok, nil
+}
+
+// EncodeValue sends strings as-is and anything else, e.g. set through the JSON API, as JSON
+func EncodeValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
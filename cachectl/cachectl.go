@@ -0,0 +1,506 @@
+package cachectl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"unified/auth"
+	"unified/client"
+	"unified/grpc_api/cachepb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const usage = `Usage: cachectl [flags] <command> [args]
+
+Commands:
+  get <key>                     print a value
+  set [-ttl 60s] <key> <value>  store a value, JSON values are kept as JSON on /cache
+  del <key>...                  delete keys
+  getall                        print every key and value
+  flush                         delete every key
+  stats                         print cache statistics (/cache only)
+  ttl <key>                     print the remaining time to live (/cache only)
+  expire <key> <ttl>            reset the time to live, e.g. 90s (/cache only)
+  persist <key>                 remove the time to live (/cache only)
+  watch [-prefix p]             stream changes over gRPC (server -grpc-addr) until interrupted
+  import [-ttl 60s] <file|->    load a JSON object of keys and values
+  export <file|->               write every key and value as a JSON object
+  token [-role read] [-prefix p] [-namespace ns] [-ttl 1h] -secret-file f <subject>
+                                print a bearer token signed with the server's hmac_secret
+  completion bash|zsh|fish      print a shell completion script
+
+Flags:
+`
+
+var commands = []string{"get", "set", "del", "getall", "flush", "stats", "ttl", "expire", "persist", "watch", "import", "export", "token", "completion"}
+
+// cli holds the parsed global flags
+type cli struct {
+	ctx      context.Context
+	client   *client.Client
+	cache    *client.CacheAPI //root or -ns namespace
+	target   target
+	name     string //target name
+	output   string //table or json
+	grpcAddr string
+	apiKey   string //credentials, also sent to gRPC
+	bearer   string
+	stdout   io.Writer
+	stdin    io.Reader
+}
+
+// Run executes cachectl with args (without the program name) and returns the exit code
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("cachectl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	addr := flags.String("addr", "", "Server URL (default http://localhost:8080 for /cache, http://localhost:8081 otherwise)")
+	targetName := flags.String("target", "cache", "Routes to use: cache, redis or inmemory")
+	output := flags.String("o", "table", "Output format: table or json")
+	timeout := flags.Duration("timeout", 5*time.Second, "Per-request timeout")
+	grpcAddr := flags.String("grpc-addr", "localhost:9090", "gRPC address used by watch")
+	ns := flags.String("ns", "", "Namespace to use with the cache target, empty for the root keyspace")
+	apiKey := flags.String("api-key", os.Getenv("CACHECTL_API_KEY"), "API key (default $CACHECTL_API_KEY)")
+	token := flags.String("token", os.Getenv("CACHECTL_TOKEN"), "Bearer token (default $CACHECTL_TOKEN)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || (*output != "table" && *output != "json") {
+		flags.Usage()
+		return 2
+	}
+	if *addr == "" {
+		*addr = "http://localhost:8080"
+		if *targetName != "cache" {
+			*addr = "http://localhost:8081"
+		}
+	}
+
+	c := &cli{
+		ctx:      ctx,
+		client:   client.New(*addr, *timeout),
+		name:     *targetName,
+		output:   *output,
+		grpcAddr: *grpcAddr,
+		apiKey:   *apiKey,
+		bearer:   *token,
+		stdout:   stdout,
+		stdin:    stdin,
+	}
+	c.client.SetAPIKey(*apiKey)
+	c.client.SetToken(*token)
+	c.cache = c.client.Cache()
+	if *ns != "" {
+		if *targetName != "cache" {
+			fmt.Fprintln(stderr, "cachectl: -ns needs the cache target")
+			return 2
+		}
+		c.cache = c.client.Namespace(*ns)
+	}
+	var err error
+	if c.target, err = newTarget(*targetName, c.client, c.cache); err != nil {
+		fmt.Fprintln(stderr, "cachectl:", err)
+		return 2
+	}
+
+	command, rest := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "get":
+		err = c.get(rest)
+	case "set":
+		err = c.set(rest)
+	case "del":
+		err = c.del(rest)
+	case "getall":
+		err = c.getAll(rest)
+	case "flush":
+		err = c.flush(rest)
+	case "stats":
+		err = c.stats(rest)
+	case "ttl":
+		err = c.ttl(rest)
+	case "expire":
+		err = c.expire(rest)
+	case "persist":
+		err = c.persist(rest)
+	case "watch":
+		err = c.watch(rest)
+	case "import":
+		err = c.importKeys(rest)
+	case "export":
+		err = c.exportKeys(rest)
+	case "token":
+		err = c.token(rest)
+	case "completion":
+		err = completion(stdout, rest)
+	default:
+		fmt.Fprintf(stderr, "cachectl: unknown command %q\n", command)
+		flags.Usage()
+		return 2
+	}
+	var usageErr usageError
+	switch {
+	case errors.As(err, &usageErr):
+		fmt.Fprintln(stderr, "cachectl:", err)
+		return 2
+	case err != nil:
+		fmt.Fprintln(stderr, "cachectl:", err)
+		return 1
+	}
+	return 0
+}
+
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func (c *cli) get(args []string) error {
+	if len(args) != 1 {
+		return usageError("usage: get <key>")
+	}
+	value, err := c.target.Get(c.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return c.printJSON(map[string]interface{}{"key": args[0], "value": value})
+	}
+	fmt.Fprintln(c.stdout, stringValue(value))
+	return nil
+}
+
+func (c *cli) set(args []string) error {
+	flags := flag.NewFlagSet("set", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	ttl := flags.Duration("ttl", 60*time.Second, "Time to live")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 2 {
+		return usageError("usage: set [-ttl 60s] <key> <value>")
+	}
+	var value interface{} = flags.Arg(1)
+	if c.name == "cache" { //the unified routes keep JSON values as such
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(flags.Arg(1)), &decoded); err == nil {
+			value = decoded
+		}
+	}
+	if err := c.target.Set(c.ctx, flags.Arg(0), value, *ttl); err != nil {
+		return err
+	}
+	return c.done("OK")
+}
+
+func (c *cli) del(args []string) error {
+	if len(args) == 0 {
+		return usageError("usage: del <key>...")
+	}
+	for _, key := range args {
+		if err := c.target.Delete(c.ctx, key); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return c.done(fmt.Sprintf("Deleted %d keys", len(args)))
+}
+
+func (c *cli) getAll(args []string) error {
+	if len(args) != 0 {
+		return usageError("usage: getall")
+	}
+	values, err := c.target.GetAll(c.ctx)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return c.printJSON(values)
+	}
+	rows := make([][]string, 0, len(values))
+	for key, value := range values {
+		rows = append(rows, []string{key, stringValue(value)})
+	}
+	return c.printTable([]string{"KEY", "VALUE"}, rows)
+}
+
+func (c *cli) flush(args []string) error {
+	if len(args) != 0 {
+		return usageError("usage: flush")
+	}
+	if err := c.target.DeleteAll(c.ctx); err != nil {
+		return err
+	}
+	return c.done("OK")
+}
+
+func (c *cli) stats(args []string) error {
+	if len(args) != 0 {
+		return usageError("usage: stats")
+	}
+	if c.name != "cache" {
+		return usageError("stats is only available on the cache target")
+	}
+	stats, err := c.cache.Stats(c.ctx)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return c.printJSON(stats)
+	}
+	var rows [][]string
+	flatten("", stats, &rows)
+	return c.printTable([]string{"STAT", "VALUE"}, rows)
+}
+
+func (c *cli) ttl(args []string) error {
+	if len(args) != 1 {
+		return usageError("usage: ttl <key>")
+	}
+	if c.name != "cache" {
+		return usageError("ttl is only available on the cache target")
+	}
+	ttl, err := c.cache.TTL(c.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return c.printJSON(map[string]interface{}{"key": args[0], "ttl": int64(ttl / time.Second)})
+	}
+	if ttl < 0 {
+		fmt.Fprintln(c.stdout, "no expiry")
+		return nil
+	}
+	fmt.Fprintln(c.stdout, ttl)
+	return nil
+}
+
+func (c *cli) expire(args []string) error {
+	if len(args) != 2 {
+		return usageError("usage: expire <key> <ttl>")
+	}
+	if c.name != "cache" {
+		return usageError("expire is only available on the cache target")
+	}
+	ttl, err := time.ParseDuration(args[1])
+	if err != nil || ttl <= 0 {
+		return usageError("expire: ttl must be a positive duration, e.g. 90s")
+	}
+	if err := c.cache.Expire(c.ctx, args[0], ttl); err != nil {
+		return err
+	}
+	return c.done("OK")
+}
+
+func (c *cli) persist(args []string) error {
+	if len(args) != 1 {
+		return usageError("usage: persist <key>")
+	}
+	if c.name != "cache" {
+		return usageError("persist is only available on the cache target")
+	}
+	if err := c.cache.Persist(c.ctx, args[0]); err != nil {
+		return err
+	}
+	return c.done("OK")
+}
+
+func (c *cli) watch(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	prefix := flags.String("prefix", "", "Only keys with this prefix")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return usageError("usage: watch [-prefix p]")
+	}
+	conn, err := grpc.NewClient(c.grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx := c.ctx
+	if c.apiKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", c.apiKey)
+	}
+	if c.bearer != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.bearer)
+	}
+	stream, err := cachepb.NewCacheClient(conn).Watch(ctx, &cachepb.WatchRequest{Prefix: *prefix})
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if c.ctx.Err() != nil { //interrupted
+				return nil
+			}
+			return err
+		}
+		op := strings.ToLower(strings.TrimPrefix(event.Op.String(), "OP_"))
+		if c.output == "json" {
+			if err := c.printJSON(map[string]interface{}{"op": op, "key": event.Key, "value": event.Value.AsInterface(), "remote": event.Remote}); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(c.stdout, "%s\t%s\t%s\n", op, event.Key, stringValue(event.Value.AsInterface()))
+	}
+}
+
+func (c *cli) importKeys(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	ttl := flags.Duration("ttl", 60*time.Second, "Time to live of imported keys")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return usageError("usage: import [-ttl 60s] <file|->")
+	}
+	in := c.stdin
+	if flags.Arg(0) != "-" {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	var values map[string]interface{}
+	if err := json.NewDecoder(bufio.NewReader(in)).Decode(&values); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	keys := sortedKeys(values)
+	for _, key := range keys {
+		if err := c.target.Set(c.ctx, key, values[key], *ttl); err != nil {
+			return fmt.Errorf("import %s: %w", key, err)
+		}
+	}
+	return c.done(fmt.Sprintf("Imported %d keys", len(keys)))
+}
+
+func (c *cli) exportKeys(args []string) error {
+	if len(args) != 1 {
+		return usageError("usage: export <file|->")
+	}
+	values, err := c.target.GetAll(c.ctx)
+	if err != nil {
+		return err
+	}
+	out := c.stdout
+	if args[0] != "-" {
+		file, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(values)
+}
+
+// done reports a successful write
+func (c *cli) token(args []string) error {
+	flags := flag.NewFlagSet("token", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	role := flags.String("role", "read", "read, write or admin")
+	ttl := flags.Duration("ttl", time.Hour, "Lifetime, 0 never expires")
+	secretFile := flags.String("secret-file", "", "File holding the server's hmac_secret")
+	var prefixes, namespaces stringList
+	flags.Var(&prefixes, "prefix", "Key prefix the token may touch, repeatable")
+	flags.Var(&namespaces, "namespace", "Namespace the token may touch, repeatable")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 || *secretFile == "" {
+		return usageError("usage: token [-role read] [-prefix p] [-namespace ns] [-ttl 1h] -secret-file f <subject>")
+	}
+	if _, err := auth.ParseRole(*role); err != nil {
+		return usageError(err.Error())
+	}
+	secret, err := os.ReadFile(*secretFile)
+	if err != nil {
+		return err
+	}
+	claims := auth.Claims{Subject: flags.Arg(0), Role: *role, Prefixes: prefixes, Namespaces: namespaces}
+	if *ttl > 0 {
+		claims.Expires = time.Now().Add(*ttl).Unix()
+	}
+	signed, err := auth.SignToken([]byte(strings.TrimSpace(string(secret))), claims)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, signed)
+	return nil
+}
+
+// stringList collects a repeated flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func (c *cli) done(message string) error {
+	if c.output == "json" {
+		return c.printJSON(map[string]string{"status": message})
+	}
+	fmt.Fprintln(c.stdout, message)
+	return nil
+}
+
+func (c *cli) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printTable prints rows sorted by their first column
+func (c *cli) printTable(header []string, rows [][]string) error {
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// flatten turns nested stats into dotted rows, e.g. memory.hits
+func flatten(prefix string, value interface{}, rows *[][]string) {
+	nested, ok := value.(map[string]interface{})
+	if !ok {
+		*rows = append(*rows, []string{prefix, stringValue(value)})
+		return
+	}
+	for key, v := range nested {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		flatten(key, v, rows)
+	}
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,78 @@
+package cachectl
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const bashCompletion = `# cachectl bash completion, load with: source <(cachectl completion bash)
+_cachectl() {
+	local cur prev
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	prev="${COMP_WORDS[COMP_CWORD-1]}"
+	case "$prev" in
+	-target) COMPREPLY=($(compgen -W "cache redis inmemory" -- "$cur")); return ;;
+	-o) COMPREPLY=($(compgen -W "table json" -- "$cur")); return ;;
+	completion) COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur")); return ;;
+	import|export) COMPREPLY=($(compgen -f -- "$cur")); return ;;
+	esac
+	if [[ "$cur" == -* ]]; then
+		COMPREPLY=($(compgen -W "-addr -target -o -timeout -grpc-addr -ns -api-key -token -ttl -prefix -role -namespace -secret-file" -- "$cur"))
+		return
+	fi
+	COMPREPLY=($(compgen -W "%s" -- "$cur"))
+}
+complete -F _cachectl cachectl
+`
+
+const zshCompletion = `#compdef cachectl
+# cachectl zsh completion, load with: source <(cachectl completion zsh)
+_cachectl() {
+	_arguments \
+		'-addr[server URL]:url:' \
+		'-target[routes to use]:target:(cache redis inmemory)' \
+		'-o[output format]:format:(table json)' \
+		'-timeout[per-request timeout]:duration:' \
+		'-grpc-addr[gRPC address used by watch]:address:' \
+		'-ns[namespace of the cache target]:namespace:' \
+		'-api-key[API key]:key:' \
+		'-token[bearer token]:token:' \
+		'1:command:(%s)' \
+		'*::arg:_files'
+}
+compdef _cachectl cachectl
+`
+
+const fishCompletion = `# cachectl fish completion, load with: cachectl completion fish | source
+complete -c cachectl -f
+complete -c cachectl -n '__fish_use_subcommand' -a '%s'
+complete -c cachectl -o addr -d 'Server URL'
+complete -c cachectl -o target -xa 'cache redis inmemory' -d 'Routes to use'
+complete -c cachectl -o o -xa 'table json' -d 'Output format'
+complete -c cachectl -o timeout -d 'Per-request timeout'
+complete -c cachectl -o grpc-addr -d 'gRPC address used by watch'
+complete -c cachectl -o ns -d 'Namespace of the cache target'
+complete -c cachectl -o api-key -d 'API key'
+complete -c cachectl -o token -d 'Bearer token'
+complete -c cachectl -n '__fish_seen_subcommand_from completion' -xa 'bash zsh fish'
+complete -c cachectl -n '__fish_seen_subcommand_from import export' -F
+`
+
+func completion(out io.Writer, args []string) error {
+	if len(args) != 1 {
+		return usageError("usage: completion bash|zsh|fish")
+	}
+	words := strings.Join(commands, " ")
+	switch args[0] {
+	case "bash":
+		fmt.Fprintf(out, bashCompletion, words)
+	case "zsh":
+		fmt.Fprintf(out, zshCompletion, words)
+	case "fish":
+		fmt.Fprintf(out, fishCompletion, words)
+	default:
+		return usageError("usage: completion bash|zsh|fish")
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package cachectl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"unified/client"
+)
+
+// target is one of the route groups, values are strings on /redis and /inmemory
+type target interface {
+	Get(ctx context.Context, key string) (interface{}, error)
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	GetAll(ctx context.Context) (map[string]interface{}, error)
+	DeleteAll(ctx context.Context) error
+}
+
+func newTarget(name string, c *client.Client, cache *client.CacheAPI) (target, error) {
+	switch name {
+	case "cache":
+		return cacheTarget{cache}, nil
+	case "redis":
+		return redisTarget{c.Redis()}, nil
+	case "inmemory":
+		return inMemoryTarget{c.InMemory()}, nil
+	}
+	return nil, fmt.Errorf("unknown target %q, want cache, redis or inmemory", name)
+}
+
+type cacheTarget struct {
+	api *client.CacheAPI
+}
+
+func (t cacheTarget) Get(ctx context.Context, key string) (interface{}, error) {
+	return t.api.Get(ctx, key)
+}
+
+func (t cacheTarget) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return t.api.Set(ctx, key, value, ttl)
+}
+
+func (t cacheTarget) Delete(ctx context.Context, key string) error {
+	return t.api.Delete(ctx, key)
+}
+
+func (t cacheTarget) GetAll(ctx context.Context) (map[string]interface{}, error) {
+	return t.api.GetAll(ctx)
+}
+
+func (t cacheTarget) DeleteAll(ctx context.Context) error {
+	return t.api.DeleteAll(ctx)
+}
+
+type redisTarget struct {
+	api *client.RedisAPI
+}
+
+func (t redisTarget) Get(ctx context.Context, key string) (interface{}, error) {
+	return t.api.Get(ctx, key)
+}
+
+func (t redisTarget) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return t.api.Set(ctx, key, stringValue(value), ttl)
+}
+
+func (t redisTarget) Delete(ctx context.Context, key string) error {
+	return t.api.Delete(ctx, key)
+}
+
+func (t redisTarget) GetAll(ctx context.Context) (map[string]interface{}, error) {
+	values, err := t.api.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		all[key] = value
+	}
+	return all, nil
+}
+
+func (t redisTarget) DeleteAll(ctx context.Context) error {
+	return t.api.DeleteAll(ctx)
+}
+
+type inMemoryTarget struct {
+	api *client.InMemoryAPI
+}
+
+func (t inMemoryTarget) Get(ctx context.Context, key string) (interface{}, error) {
+	return t.api.Get(ctx, key)
+}
+
+func (t inMemoryTarget) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return t.api.Set(ctx, key, stringValue(value), ttl)
+}
+
+func (t inMemoryTarget) Delete(ctx context.Context, key string) error {
+	deleted, err := t.api.Delete(ctx, key)
+	if err == nil && !deleted {
+		return client.ErrNotFound
+	}
+	return err
+}
+
+func (t inMemoryTarget) GetAll(ctx context.Context) (map[string]interface{}, error) {
+	return t.api.GetAll(ctx)
+}
+
+func (t inMemoryTarget) DeleteAll(ctx context.Context) error {
+	_, err := t.api.DeleteAll(ctx) //nothing to delete is fine
+	return err
+}
+
+// stringValue is the JSON text of non-string values, for routes that only store strings
+func stringValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
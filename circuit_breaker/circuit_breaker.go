@@ -0,0 +1,138 @@
+package circuit_breaker
+
+import (
+	"sync"
+	"time"
+)
+
+type BreakerState int
+
+const (
+	StateClosed   BreakerState = iota //redis healthy, all calls pass
+	StateOpen                         //redis failing, calls short-circuit until backoff elapses
+	StateHalfOpen                     //backoff elapsed, a single probe call is allowed
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Circuit breaker guarding calls to Redis, from the MultiCache Redis tier and the Redis rate limiter
+type CircuitBreaker struct {
+	threshold   int           //consecutive failures before opening
+	backoff     time.Duration //initial open duration
+	maxBackoff  time.Duration //cap for exponential backoff
+	state       BreakerState
+	failures    int
+	openFor     time.Duration //current backoff, doubles on each failed probe
+	openedAt    time.Time
+	probing     bool //half-open probe in flight
+	mutex       sync.Mutex
+	onRecovered func() //called after the breaker closes again
+}
+
+func NewCircuitBreaker(threshold int, backoff time.Duration, maxBackoff time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	if maxBackoff < backoff {
+		maxBackoff = backoff
+	}
+	return &CircuitBreaker{
+		threshold:  threshold,
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+		openFor:    backoff,
+	}
+}
+
+// OnRecovered sets a function called after the breaker closes again
+func (cb *CircuitBreaker) OnRecovered(onRecovered func()) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.onRecovered = onRecovered
+}
+
+// Allow reports whether a call to Redis may proceed
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	switch cb.state {
+	case StateOpen:
+		if time.Since(cb.openedAt) < cb.openFor {
+			return false
+		}
+		cb.state = StateHalfOpen //backoff elapsed, let one probe through
+		cb.probing = true
+		return true
+	case StateHalfOpen:
+		if cb.probing {
+			return false
+		}
+		cb.probing = true
+		return true
+	}
+	return true
+}
+
+func (cb *CircuitBreaker) Success() {
+	cb.mutex.Lock()
+	recovered := cb.state != StateClosed
+	cb.state = StateClosed
+	cb.failures = 0
+	cb.probing = false
+	cb.openFor = cb.backoff
+	onRecovered := cb.onRecovered
+	cb.mutex.Unlock()
+
+	if recovered && onRecovered != nil {
+		onRecovered()
+	}
+}
+
+func (cb *CircuitBreaker) Failure() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	switch cb.state {
+	case StateHalfOpen: //probe failed, reopen with longer backoff
+		cb.openFor *= 2
+		if cb.openFor > cb.maxBackoff {
+			cb.openFor = cb.maxBackoff
+		}
+		cb.trip()
+	case StateClosed:
+		cb.failures++
+		if cb.failures >= cb.threshold {
+			cb.trip()
+		}
+	}
+}
+
+// Cancel releases a half-open probe that never reached Redis
+func (cb *CircuitBreaker) Cancel() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.probing = false
+}
+
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	return cb.state
+}
+
+func (cb *CircuitBreaker) trip() {
+	cb.state = StateOpen
+	cb.openedAt = time.Now()
+	cb.probing = false
+}
@@ -0,0 +1,251 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// CacheAPI mirrors api_handler.SetupUnifiedRoutes
+type CacheAPI struct {
+	c      *Client
+	prefix string //"/ns/<name>" for a namespace, empty for the root keyspace
+}
+
+// Set stores value for ttl, a ttl of 0 never expires
+func (a *CacheAPI) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return a.SetWithTags(ctx, key, value, ttl)
+}
+
+// SetWithTags is Set, attaching tags that InvalidateTag deletes the key by
+func (a *CacheAPI) SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error {
+	body := struct {
+		Key   string      `json:"key"`
+		Value interface{} `json:"value"`
+		TTL   int         `json:"ttl"`
+		Tags  []string    `json:"tags,omitempty"`
+	}{key, value, ttlSeconds(ttl), tags}
+	return a.c.do(ctx, http.MethodPost, a.prefix+"/cache", body, nil)
+}
+
+// InvalidateTag deletes every key set with tag, returns the deleted keys
+func (a *CacheAPI) InvalidateTag(ctx context.Context, tag string) ([]string, error) {
+	var resp struct {
+		Keys []string `json:"keys"`
+	}
+	if err := a.c.do(ctx, http.MethodDelete, keyPath(a.prefix+"/cache/tags/", tag), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+// Get returns ErrNotFound for missing keys
+func (a *CacheAPI) Get(ctx context.Context, key string) (interface{}, error) {
+	var resp struct {
+		Value interface{} `json:"value"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, keyPath(a.prefix+"/cache/", key), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Value, nil
+}
+
+// TTL returns the remaining time to live, negative when the key never expires
+func (a *CacheAPI) TTL(ctx context.Context, key string) (time.Duration, error) {
+	var resp struct {
+		TTL int64 `json:"ttl"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, keyPath(a.prefix+"/cache/", key)+"/ttl", nil, &resp); err != nil {
+		return 0, err
+	}
+	return time.Duration(resp.TTL) * time.Second, nil
+}
+
+// Expire resets the ttl of key without rewriting its value, ErrNotFound for missing keys
+func (a *CacheAPI) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.New("client: ttl must be positive, use Persist or Delete")
+	}
+	return a.setTTL(ctx, key, ttlSeconds(ttl))
+}
+
+// Persist removes the ttl of key so it never expires, ErrNotFound for missing keys
+func (a *CacheAPI) Persist(ctx context.Context, key string) error {
+	return a.setTTL(ctx, key, 0)
+}
+
+func (a *CacheAPI) setTTL(ctx context.Context, key string, seconds int) error {
+	body := struct {
+		TTL int `json:"ttl"`
+	}{seconds}
+	return a.c.do(ctx, http.MethodPut, keyPath(a.prefix+"/cache/", key)+"/ttl", body, nil)
+}
+
+// Stats returns the /_stats document
+func (a *CacheAPI) Stats(ctx context.Context) (map[string]interface{}, error) {
+	var stats map[string]interface{}
+	if err := a.c.do(ctx, http.MethodGet, a.prefix+"/_stats", nil, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (a *CacheAPI) GetAll(ctx context.Context) (map[string]interface{}, error) {
+	var resp struct {
+		Values map[string]interface{} `json:"values"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, a.prefix+"/cache", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Values, nil
+}
+
+// Scan returns a page of items matching the glob and the next cursor, "0" when done. Start with "0"
+func (a *CacheAPI) Scan(ctx context.Context, cursor string, match string, count int) (map[string]interface{}, string, error) {
+	var resp struct {
+		Values map[string]interface{} `json:"values"`
+		Cursor string                 `json:"cursor"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, a.prefix+"/cache?"+scanQuery(cursor, match, count), nil, &resp); err != nil {
+		return nil, "", err
+	}
+	return resp.Values, resp.Cursor, nil
+}
+
+func (a *CacheAPI) Delete(ctx context.Context, key string) error {
+	return a.c.do(ctx, http.MethodDelete, keyPath(a.prefix+"/cache/", key), nil, nil)
+}
+
+func (a *CacheAPI) DeleteAll(ctx context.Context) error {
+	return a.c.do(ctx, http.MethodDelete, a.prefix+"/cache", nil, nil)
+}
+
+// RedisAPI mirrors api_handler.SetupRedisRoutes, values are strings
+type RedisAPI struct {
+	c *Client
+}
+
+func (a *RedisAPI) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	body := struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+		TTL   int    `json:"ttl"`
+	}{key, value, ttlSeconds(ttl)}
+	return a.c.do(ctx, http.MethodPost, "/redis/", body, nil)
+}
+
+// Get returns ErrNotFound for missing keys
+func (a *RedisAPI) Get(ctx context.Context, key string) (string, error) {
+	var resp struct {
+		Value string `json:"value"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, keyPath("/redis/", key), nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.Value, nil
+}
+
+func (a *RedisAPI) GetAll(ctx context.Context) (map[string]string, error) {
+	values := make(map[string]string)
+	if err := a.c.do(ctx, http.MethodGet, "/redis/", nil, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Scan returns a page of items matching the glob and the next cursor, "0" when done. Start with "0"
+func (a *RedisAPI) Scan(ctx context.Context, cursor string, match string, count int) (map[string]string, string, error) {
+	var resp struct {
+		Values map[string]string `json:"values"`
+		Cursor string            `json:"cursor"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, "/redis/?"+scanQuery(cursor, match, count), nil, &resp); err != nil {
+		return nil, "", err
+	}
+	return resp.Values, resp.Cursor, nil
+}
+
+func (a *RedisAPI) Delete(ctx context.Context, key string) error {
+	return a.c.do(ctx, http.MethodDelete, keyPath("/redis/", key), nil, nil)
+}
+
+func (a *RedisAPI) DeleteAll(ctx context.Context) error {
+	return a.c.do(ctx, http.MethodDelete, "/redis/", nil, nil)
+}
+
+// InMemoryAPI mirrors api_handler.SetupInMemoryRoutes, deletes report whether anything was removed
+type InMemoryAPI struct {
+	c *Client
+}
+
+func (a *InMemoryAPI) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	body := struct {
+		Key        string `json:"key"`
+		Value      string `json:"value"`
+		Expiration int    `json:"expiration"`
+	}{key, value, ttlSeconds(ttl)}
+	return a.c.do(ctx, http.MethodPost, "/inmemory", body, nil)
+}
+
+// Get returns ErrNotFound for missing keys
+func (a *InMemoryAPI) Get(ctx context.Context, key string) (interface{}, error) {
+	var resp struct {
+		Value interface{} `json:"value"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, keyPath("/inmemory/", key), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Value, nil
+}
+
+func (a *InMemoryAPI) GetAll(ctx context.Context) (map[string]interface{}, error) {
+	var resp struct {
+		Items map[string]interface{} `json:"items"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, "/inmemory", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Items, nil
+}
+
+// Scan returns a page of items matching the glob and the next cursor, "0" when done. Start with "0"
+func (a *InMemoryAPI) Scan(ctx context.Context, cursor string, match string, count int) (map[string]interface{}, string, error) {
+	var resp struct {
+		Items  map[string]interface{} `json:"items"`
+		Cursor string                 `json:"cursor"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, "/inmemory?"+scanQuery(cursor, match, count), nil, &resp); err != nil {
+		return nil, "", err
+	}
+	return resp.Items, resp.Cursor, nil
+}
+
+func (a *InMemoryAPI) Delete(ctx context.Context, key string) (bool, error) {
+	return found(a.c.do(ctx, http.MethodDelete, keyPath("/inmemory/", key), nil, nil))
+}
+
+func (a *InMemoryAPI) DeleteAll(ctx context.Context) (bool, error) {
+	return found(a.c.do(ctx, http.MethodDelete, "/inmemory", nil, nil))
+}
+
+// found turns a 404 into false
+func found(err error) (bool, error) {
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func scanQuery(cursor string, match string, count int) string {
+	query := url.Values{"cursor": {cursor}}
+	if match != "" {
+		query.Set("match", match)
+	}
+	if count > 0 {
+		query.Set("count", strconv.Itoa(count))
+	}
+	return query.Encode()
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls the cache HTTP API. One Client per server: the unified routes are on :8080,
+// the redis and in-memory routes on :8081
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retries    int           //extra attempts on network errors and 502/503/504
+	backoff    time.Duration //wait before the first retry, doubled after each
+	apiKey     string
+	token      string
+}
+
+// New returns a client with a pooled transport and the given per-request timeout
+func New(baseURL string, timeout time.Duration) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 32 //keep connections to the one cache server warm
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Transport: transport, Timeout: timeout},
+		retries:    2,
+		backoff:    100 * time.Millisecond,
+	}
+}
+
+// SetRetries configures retries of failed requests, 0 disables them
+func (c *Client) SetRetries(retries int, backoff time.Duration) {
+	c.retries = retries
+	c.backoff = backoff
+}
+
+// SetHTTPClient replaces the underlying http.Client, e.g. to add TLS or tracing
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+}
+
+// SetAPIKey authenticates requests with an API key from the server's -auth-file
+func (c *Client) SetAPIKey(key string) {
+	c.apiKey = key
+}
+
+// SetToken authenticates requests with a bearer token signed by auth.SignToken
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// Cache is the unified API under /cache
+func (c *Client) Cache() *CacheAPI {
+	return &CacheAPI{c: c}
+}
+
+// Namespace is the unified API of one tenant under /ns/:namespace
+func (c *Client) Namespace(name string) *CacheAPI {
+	return &CacheAPI{c: c, prefix: "/ns/" + url.PathEscape(name)}
+}
+
+// Redis is the Redis-only API under /redis/
+func (c *Client) Redis() *RedisAPI {
+	return &RedisAPI{c}
+}
+
+// InMemory is the in-memory-only API under /inmemory
+func (c *Client) InMemory() *InMemoryAPI {
+	return &InMemoryAPI{c}
+}
+
+// do sends a request and decodes a 2xx JSON body into out, retrying transient failures.
+// All cache operations are idempotent, so every method is retried
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := c.attempt(ctx, method, path, payload, out)
+		if err == nil || attempt >= c.retries || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method string, path string, payload []byte, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp.StatusCode, resp.Header, data)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+func keyPath(prefix string, key string) string {
+	return prefix + url.PathEscape(key)
+}
+
+// ttlSeconds rounds up, the API takes whole seconds
+func ttlSeconds(ttl time.Duration) int {
+	return int((ttl + time.Second - 1) / time.Second)
+}
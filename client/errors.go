@@ -0,0 +1,70 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("client: key not found")
+	ErrBadRequest   = errors.New("client: bad request")
+	ErrUnavailable  = errors.New("client: cache unavailable")
+	ErrUnauthorized = errors.New("client: missing or invalid credentials")
+	ErrForbidden    = errors.New("client: not allowed for these credentials")
+	ErrRateLimited  = errors.New("client: rate limit exceeded")
+)
+
+// APIError is a non-2xx response, it matches ErrNotFound, ErrBadRequest, ErrUnavailable, ErrUnauthorized, ErrForbidden or ErrRateLimited with errors.Is
+type APIError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration //from the Retry-After header of rate limited responses
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("client: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusServiceUnavailable:
+		return ErrUnavailable
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// The routes report errors under "error", "status" or "message"
+func newAPIError(statusCode int, header http.Header, body []byte) *APIError {
+	var fields struct {
+		Error   string `json:"error"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	json.Unmarshal(body, &fields)
+	message := fields.Error
+	if message == "" {
+		message = fields.Status
+	}
+	if message == "" {
+		message = fields.Message
+	}
+	if message == "" {
+		message = string(body)
+	}
+	seconds, _ := strconv.Atoi(header.Get("Retry-After"))
+	return &APIError{StatusCode: statusCode, Message: message, RetryAfter: time.Duration(seconds) * time.Second}
+}
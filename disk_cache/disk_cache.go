@@ -0,0 +1,466 @@
+package disk_cache
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"unified/in_memory"
+)
+
+// Record layout: crc32 | op | expiration | key length | value length | key | value
+const headerSize = 4 + 1 + 8 + 4 + 4
+
+const (
+	opSet    byte = 1
+	opDelete byte = 2
+)
+
+const segmentPrefix, segmentSuffix = "segment-", ".log"
+
+var (
+	errCorrupt     = errors.New("corrupt record")
+	ErrNegativeTTL = errors.New("ttl cannot be negative")
+)
+
+// Location of the latest value of a key
+type indexEntry struct {
+	segment    int
+	offset     int64
+	size       int64
+	expiration int64 //unix time like in_memory.CacheItem, 0 never expires
+}
+
+func (e indexEntry) expired(now int64) bool {
+	return e.expiration != 0 && e.expiration < now
+}
+
+// Log-structured disk store: writes append to the active segment, an in-memory index points at the latest record per key
+type DiskCache struct {
+	dir         string
+	maxSegment  int64 //roll to a new segment past this size
+	index       map[string]indexEntry
+	segments    map[int]*os.File
+	active      int
+	activeSize  int64
+	totalSize   int64 //bytes across all segments
+	deadSize    int64 //bytes of overwritten, deleted and tombstone records
+	mutex       sync.RWMutex
+	err         error //last I/O error, see Err
+	stopJanitor chan struct{}
+}
+
+func NewDiskCache(dir string, maxSegment int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	dc := &DiskCache{
+		dir:         dir,
+		maxSegment:  maxSegment,
+		index:       make(map[string]indexEntry),
+		segments:    make(map[int]*os.File),
+		stopJanitor: make(chan struct{}),
+	}
+	if err := dc.load(); err != nil {
+		dc.closeSegments()
+		return nil, err
+	}
+	go dc.startCompactionRoutine()
+	return dc, nil
+}
+
+// load rebuilds the index by scanning every segment in order
+func (dc *DiskCache) load() error {
+	ids, err := dc.segmentIDs()
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		file, err := os.OpenFile(dc.segmentPath(id), os.O_RDWR, 0644)
+		if err != nil {
+			return err
+		}
+		dc.segments[id] = file
+		size, err := dc.scan(id, file)
+		if err != nil {
+			if i != len(ids)-1 {
+				return fmt.Errorf("disk cache: segment %d: %w", id, err)
+			}
+			if err := file.Truncate(size); err != nil { //torn write at the tail of the last segment
+				return err
+			}
+		}
+		dc.active, dc.activeSize = id, size
+	}
+	if len(ids) == 0 {
+		return dc.roll()
+	}
+	return nil
+}
+
+func (dc *DiskCache) scan(id int, file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	var offset int64
+	header := make([]byte, headerSize)
+	for offset < info.Size() {
+		if _, err := file.ReadAt(header, offset); err != nil {
+			return offset, errCorrupt
+		}
+		op, expiration, keyLen, valueLen := decodeHeader(header)
+		size := int64(headerSize) + int64(keyLen) + int64(valueLen)
+		if offset+size > info.Size() {
+			return offset, errCorrupt
+		}
+		record := make([]byte, size)
+		if _, err := file.ReadAt(record, offset); err != nil {
+			return offset, errCorrupt
+		}
+		if crc32.ChecksumIEEE(record[4:]) != binary.BigEndian.Uint32(record[:4]) {
+			return offset, errCorrupt
+		}
+		key := string(record[headerSize : headerSize+int(keyLen)])
+		dc.apply(op, key, indexEntry{segment: id, offset: offset, size: size, expiration: expiration})
+		offset += size
+	}
+	return offset, nil
+}
+
+// apply updates the index and space accounting for one record
+func (dc *DiskCache) apply(op byte, key string, entry indexEntry) {
+	dc.totalSize += entry.size
+	if old, ok := dc.index[key]; ok {
+		dc.deadSize += old.size
+	}
+	switch op {
+	case opSet:
+		dc.index[key] = entry
+	case opDelete:
+		dc.deadSize += entry.size
+		delete(dc.index, key)
+	}
+}
+
+// Set writes key to the active segment, a marshal or write error leaves the previous value in place
+func (dc *DiskCache) Set(key string, value interface{}, expiration time.Duration) error {
+	if expiration < 0 {
+		return ErrNegativeTTL
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var expirationTime int64 //0 for keys that never expire
+	if expiration > 0 {
+		expirationTime = time.Now().Unix() + int64(expiration.Seconds())
+	}
+
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	entry, err := dc.append(opSet, key, data, expirationTime)
+	if err != nil {
+		dc.err = err
+		return err
+	}
+	dc.apply(opSet, key, entry)
+	return nil
+}
+
+func (dc *DiskCache) Get(key string) (interface{}, bool) {
+	dc.mutex.RLock()
+	entry, ok := dc.index[key]
+	if !ok || entry.expired(time.Now().Unix()) {
+		dc.mutex.RUnlock()
+		return nil, false
+	}
+	value, err := dc.read(entry)
+	dc.mutex.RUnlock()
+	if err != nil {
+		dc.setErr(err)
+		return nil, false
+	}
+	return value, true
+}
+
+// Remaining time to live, in_memory.NoExpiry for persistent keys, false if the key is missing or expired
+func (dc *DiskCache) TTL(key string) (time.Duration, bool) {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	entry, ok := dc.index[key]
+	if !ok || entry.expired(time.Now().Unix()) {
+		return 0, false
+	}
+	if entry.expiration == 0 {
+		return in_memory.NoExpiry, true
+	}
+	return time.Until(time.Unix(entry.expiration, 0)), true
+}
+
+func (dc *DiskCache) GetAll() map[string]interface{} {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	result := make(map[string]interface{})
+	now := time.Now().Unix()
+	for key, entry := range dc.index {
+		if entry.expired(now) {
+			continue
+		}
+		value, err := dc.read(entry)
+		if err != nil {
+			continue //reported through Err on the next Get
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// Len is the number of live keys
+func (dc *DiskCache) Len() int {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	n := 0
+	now := time.Now().Unix()
+	for _, entry := range dc.index {
+		if !entry.expired(now) {
+			n++
+		}
+	}
+	return n
+}
+
+func (dc *DiskCache) Delete(key string) bool {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	entry, ok := dc.index[key]
+	if !ok {
+		return false
+	}
+	tombstone, err := dc.append(opDelete, key, nil, 0) //so a restart does not resurrect the key
+	if err != nil {
+		dc.err = err
+		return false
+	}
+	dc.apply(opDelete, key, tombstone)
+	return !entry.expired(time.Now().Unix())
+}
+
+func (dc *DiskCache) DeleteAll() bool {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	hadKeys := len(dc.index) > 0
+	dc.closeSegments()
+	for id := range dc.segments {
+		os.Remove(dc.segmentPath(id))
+	}
+	dc.segments = make(map[int]*os.File)
+	dc.index = make(map[string]indexEntry)
+	dc.totalSize, dc.deadSize = 0, 0
+	if err := dc.roll(); err != nil {
+		dc.err = err
+		return false
+	}
+	return hadKeys
+}
+
+// Err returns the last I/O error, including those of reads and deletes, which have no error result
+func (dc *DiskCache) Err() error {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+	return dc.err
+}
+
+func (dc *DiskCache) Close() error {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	select {
+	case <-dc.stopJanitor:
+	default:
+		close(dc.stopJanitor)
+	}
+	if file, ok := dc.segments[dc.active]; ok {
+		if err := file.Sync(); err != nil {
+			return err
+		}
+	}
+	return dc.closeSegments()
+}
+
+// Compact rewrites live records into a fresh segment and removes the old ones
+func (dc *DiskCache) Compact() error {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	old, oldIndex := dc.segments, dc.index
+	oldActive, oldActiveSize, oldTotal, oldDead := dc.active, dc.activeSize, dc.totalSize, dc.deadSize
+	rollback := func(err error) error {
+		for id, file := range dc.segments {
+			file.Close()
+			os.Remove(dc.segmentPath(id))
+		}
+		dc.segments, dc.index = old, oldIndex
+		dc.active, dc.activeSize, dc.totalSize, dc.deadSize = oldActive, oldActiveSize, oldTotal, oldDead
+		return err
+	}
+
+	dc.segments = make(map[int]*os.File)
+	dc.index = make(map[string]indexEntry)
+	dc.totalSize, dc.deadSize = 0, 0
+	if err := dc.roll(); err != nil {
+		return rollback(err)
+	}
+	now := time.Now().Unix()
+	for key, entry := range oldIndex {
+		if entry.expired(now) {
+			continue
+		}
+		record := make([]byte, entry.size)
+		if _, err := old[entry.segment].ReadAt(record, entry.offset); err != nil {
+			return rollback(err)
+		}
+		_, _, keyLen, _ := decodeHeader(record[:headerSize])
+		newEntry, err := dc.append(opSet, key, record[headerSize+int(keyLen):], entry.expiration)
+		if err != nil {
+			return rollback(err)
+		}
+		dc.apply(opSet, key, newEntry)
+	}
+	if err := dc.segments[dc.active].Sync(); err != nil {
+		return rollback(err)
+	}
+	for id, file := range old {
+		file.Close()
+		os.Remove(dc.segmentPath(id))
+	}
+	return nil
+}
+
+// startCompactionRoutine compacts once most of the store is dead records
+func (dc *DiskCache) startCompactionRoutine() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			dc.mutex.RLock()
+			wasteful := dc.totalSize > dc.maxSegment && dc.deadSize*2 > dc.totalSize
+			dc.mutex.RUnlock()
+			if wasteful {
+				if err := dc.Compact(); err != nil {
+					dc.setErr(err)
+				}
+			}
+		case <-dc.stopJanitor:
+			return
+		}
+	}
+}
+
+// append writes one record to the active segment, rolling first if it is full
+func (dc *DiskCache) append(op byte, key string, value []byte, expiration int64) (indexEntry, error) {
+	if dc.activeSize >= dc.maxSegment {
+		if err := dc.roll(); err != nil {
+			return indexEntry{}, err
+		}
+	}
+	record := make([]byte, headerSize+len(key)+len(value))
+	record[4] = op
+	binary.BigEndian.PutUint64(record[5:], uint64(expiration))
+	binary.BigEndian.PutUint32(record[13:], uint32(len(key)))
+	binary.BigEndian.PutUint32(record[17:], uint32(len(value)))
+	copy(record[headerSize:], key)
+	copy(record[headerSize+len(key):], value)
+	binary.BigEndian.PutUint32(record[:4], crc32.ChecksumIEEE(record[4:]))
+
+	offset := dc.activeSize
+	if _, err := dc.segments[dc.active].WriteAt(record, offset); err != nil {
+		return indexEntry{}, err
+	}
+	dc.activeSize += int64(len(record))
+	return indexEntry{segment: dc.active, offset: offset, size: int64(len(record)), expiration: expiration}, nil
+}
+
+func (dc *DiskCache) read(entry indexEntry) (interface{}, error) {
+	record := make([]byte, entry.size)
+	if _, err := dc.segments[entry.segment].ReadAt(record, entry.offset); err != nil {
+		return nil, err
+	}
+	_, _, keyLen, _ := decodeHeader(record[:headerSize])
+	var value interface{}
+	if err := json.Unmarshal(record[headerSize+int(keyLen):], &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// roll starts a new active segment
+func (dc *DiskCache) roll() error {
+	id := dc.active + 1
+	file, err := os.OpenFile(dc.segmentPath(id), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if previous, ok := dc.segments[dc.active]; ok {
+		previous.Sync()
+	}
+	dc.segments[id] = file
+	dc.active, dc.activeSize = id, 0
+	return nil
+}
+
+func (dc *DiskCache) segmentIDs() ([]int, error) {
+	names, err := filepath.Glob(filepath.Join(dc.dir, segmentPrefix+"*"+segmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, name := range names {
+		var id int
+		base := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), segmentPrefix), segmentSuffix)
+		if _, err := fmt.Sscanf(base, "%d", &id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (dc *DiskCache) segmentPath(id int) string {
+	return filepath.Join(dc.dir, fmt.Sprintf("%s%06d%s", segmentPrefix, id, segmentSuffix))
+}
+
+func (dc *DiskCache) closeSegments() error {
+	var firstErr error
+	for _, file := range dc.segments {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (dc *DiskCache) setErr(err error) {
+	dc.mutex.Lock()
+	dc.err = err
+	dc.mutex.Unlock()
+}
+
+func decodeHeader(header []byte) (op byte, expiration int64, keyLen uint32, valueLen uint32) {
+	return header[4], int64(binary.BigEndian.Uint64(header[5:])), binary.BigEndian.Uint32(header[13:]), binary.BigEndian.Uint32(header[17:])
+}
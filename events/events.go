@@ -0,0 +1,146 @@
+package events
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache changes
+const (
+	OpSet    = "set"
+	OpDelete = "delete"
+	OpFlush  = "flush"  //every key removed
+	OpEvict  = "evict"  //dropped to make room
+	OpExpire = "expire" //ttl ran out
+)
+
+type Event struct {
+	Op     string      `json:"op"`
+	Key    string      `json:"key,omitempty"`
+	Value  interface{} `json:"value,omitempty"`
+	Remote bool        `json:"remote,omitempty"` //invalidation from another instance, value unknown
+	Time   time.Time   `json:"time"`
+}
+
+// Bus fans events out to subscribers. Publish never blocks: subscribers that fall
+// more than their buffer behind miss events
+type Bus struct {
+	subscribers map[*Subscription]struct{}
+	forwards    []forward
+	closed      bool
+	mutex       sync.RWMutex
+}
+
+// forward republishes some ops on another bus
+type forward struct {
+	to  *Bus
+	ops map[string]bool
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Publish delivers event to matching subscribers, safe to call with caller locks held
+func (b *Bus) Publish(event Event) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if len(b.subscribers) == 0 && len(b.forwards) == 0 {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for sub := range b.subscribers {
+		if !sub.matches(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default: //slow subscriber
+			atomic.AddInt64(&sub.dropped, 1)
+		}
+	}
+	for _, f := range b.forwards {
+		if f.ops[event.Op] {
+			f.to.Publish(event)
+		}
+	}
+}
+
+// Forward republishes events with the given ops on another bus
+func (b *Bus) Forward(to *Bus, ops ...string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	f := forward{to: to, ops: make(map[string]bool, len(ops))}
+	for _, op := range ops {
+		f.ops[op] = true
+	}
+	b.forwards = append(b.forwards, f)
+}
+
+// Subscribe to events for keys starting with prefix, empty for all. Flushes are always delivered
+func (b *Bus) Subscribe(prefix string, buffer int) *Subscription {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	sub := &Subscription{bus: b, prefix: prefix, ch: make(chan Event, buffer)}
+	if b.closed {
+		sub.closed = true
+		close(sub.ch)
+		return sub
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Close ends every subscription and those made later, so streams of events finish at shutdown
+func (b *Bus) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		sub.closed = true
+		close(sub.ch)
+	}
+	b.subscribers = make(map[*Subscription]struct{})
+}
+
+type Subscription struct {
+	bus     *Bus
+	prefix  string
+	ch      chan Event
+	dropped int64
+	closed  bool
+}
+
+// Events is closed by Close
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Dropped counts events missed because the subscriber was too slow
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+func (s *Subscription) Close() {
+	s.bus.mutex.Lock()
+	defer s.bus.mutex.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(s.bus.subscribers, s)
+	close(s.ch)
+}
+
+func (s *Subscription) matches(event Event) bool {
+	return event.Op == OpFlush || strings.HasPrefix(event.Key, s.prefix)
+}
@@ -0,0 +1,73 @@
+package grpc_api
+
+import (
+	"context"
+	"net"
+
+	"unified/auth"
+	"unified/grpc_api/cachepb"
+	"unified/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// UnaryAudit records every mutating call with auditor once it returns. Chain it before UnaryAuth so
+// rejected calls are recorded too
+func UnaryAudit(auditor *logging.Auditor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		actions := AuditActions(req)
+		if len(actions) == 0 {
+			return handler(ctx, req)
+		}
+		caller := &callerSlot{}
+		resp, err := handler(context.WithValue(ctx, callerKey{}, caller), req)
+
+		var client net.Addr
+		if p, ok := peer.FromContext(ctx); ok {
+			client = p.Addr
+		}
+		for _, action := range actions {
+			auditor.Record(ctx, logging.Call{Protocol: "grpc", Command: info.FullMethod, Who: caller.name, Client: client, Action: action}, err)
+		}
+		return resp, err
+	}
+}
+
+// callerSlot receives the principal UnaryAuth authenticates, which UnaryAudit cannot see in its context
+type callerSlot struct {
+	name string
+}
+
+type callerKey struct{}
+
+func setCaller(ctx context.Context, principal *auth.Principal) {
+	if caller, ok := ctx.Value(callerKey{}).(*callerSlot); ok {
+		caller.name = principal.Name
+	}
+}
+
+// AuditActions lists the mutations of a Cache service request, one per key, none for reads
+func AuditActions(req interface{}) []logging.Action {
+	switch r := req.(type) {
+	case *cachepb.SetRequest:
+		return []logging.Action{{Name: "set", Key: r.Key}}
+	case *cachepb.BatchSetRequest:
+		actions := make([]logging.Action, len(r.Items))
+		for i, item := range r.Items {
+			actions[i] = logging.Action{Name: "set", Key: item.Key}
+		}
+		return actions
+	case *cachepb.DeleteRequest:
+		return []logging.Action{{Name: "delete", Key: r.Key}}
+	case *cachepb.BatchDeleteRequest:
+		actions := make([]logging.Action, len(r.Keys))
+		for i, key := range r.Keys {
+			actions[i] = logging.Action{Name: "delete", Key: key}
+		}
+		return actions
+	case *cachepb.DeleteAllRequest:
+		return []logging.Action{{Name: "flush"}}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package grpc_api
+
+import (
+	"context"
+	"net/http"
+
+	"unified/auth"
+	"unified/grpc_api/cachepb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryAuth authenticates every call with the credentials HTTP requests use, sent as
+// authorization or x-api-key metadata, and checks the caller's role and key prefixes
+func UnaryAuth(authenticator auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		principal, err := authenticate(ctx, authenticator)
+		if err != nil {
+			return nil, err
+		}
+		setCaller(ctx, principal)
+		if err := allow(principal, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuth is UnaryAuth for streams, the request is checked once the handler receives it
+func StreamAuth(authenticator auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		principal, err := authenticate(stream.Context(), authenticator)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &checkedStream{ServerStream: stream, principal: principal})
+	}
+}
+
+type checkedStream struct {
+	grpc.ServerStream
+	principal *auth.Principal
+}
+
+func (s *checkedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return allow(s.principal, m)
+}
+
+// authenticate hands the call's metadata to authenticator as the headers of an HTTP request
+func authenticate(ctx context.Context, authenticator auth.Authenticator) (*auth.Principal, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	r := &http.Request{Header: make(http.Header)}
+	for _, name := range []string{"Authorization", auth.APIKeyHeader} {
+		for _, value := range md.Get(name) {
+			r.Header.Add(name, value)
+		}
+	}
+	principal, err := authenticator.Authenticate(r)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return principal, nil
+}
+
+func allow(principal *auth.Principal, req interface{}) error {
+	if !principal.Allows(Access(req)) {
+		return status.Error(codes.PermissionDenied, "forbidden for "+principal.Name)
+	}
+	return nil
+}
+
+// Access classifies the requests of the Cache service like api_handler.Access classifies routes
+func Access(req interface{}) auth.Request {
+	switch r := req.(type) {
+	case *cachepb.GetRequest:
+		return auth.Request{Role: auth.RoleRead, Keys: []string{r.Key}}
+	case *cachepb.BatchGetRequest:
+		return auth.Request{Role: auth.RoleRead, Keys: r.Keys}
+	case *cachepb.GetAllRequest:
+		return auth.Request{Role: auth.RoleRead, AllKeys: true}
+	case *cachepb.WatchRequest:
+		return auth.Request{Role: auth.RoleRead, AllKeys: true, Prefix: r.Prefix}
+	case *cachepb.SetRequest:
+		return auth.Request{Role: auth.RoleWrite, Keys: []string{r.Key}}
+	case *cachepb.BatchSetRequest:
+		keys := make([]string, len(r.Items))
+		for i, item := range r.Items {
+			keys[i] = item.Key
+		}
+		return auth.Request{Role: auth.RoleWrite, Keys: keys}
+	case *cachepb.DeleteRequest:
+		return auth.Request{Role: auth.RoleWrite, Keys: []string{r.Key}}
+	case *cachepb.BatchDeleteRequest:
+		return auth.Request{Role: auth.RoleWrite, Keys: r.Keys}
+	}
+	return auth.Request{Role: auth.RoleAdmin, AllKeys: true} //delete all and anything added later
+}
@@ -0,0 +1,4 @@
+// Package cachepb holds the protobuf definition of the cache gRPC service and its generated Go stubs
+package cachepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative cache.proto
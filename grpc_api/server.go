@@ -0,0 +1,218 @@
+package grpc_api
+
+import (
+	"context"
+	"errors"
+
+	"unified/events"
+	"unified/grpc_api/cachepb"
+	"unified/multicache"
+	"unified/redis_cache"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Buffered events per watcher before slow watchers start missing changes
+const watchBuffer = 256
+
+// Server implements cachepb.CacheServer on top of the unified cache
+type Server struct {
+	cachepb.UnimplementedCacheServer
+	multiCache *multicache.MultiCache
+}
+
+// NewServer returns a gRPC server exposing the unified cache, the counterpart of SetupUnifiedRoutes
+func NewServer(multiCache *multicache.MultiCache, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(opts...)
+	cachepb.RegisterCacheServer(s, &Server{multiCache: multiCache})
+	return s
+}
+
+func (s *Server) Get(ctx context.Context, req *cachepb.GetRequest) (*cachepb.GetResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+	value, found, err := s.get(ctx, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, status.Error(codes.NotFound, "key not found")
+	}
+	return &cachepb.GetResponse{Key: req.Key, Value: value}, nil
+}
+
+func (s *Server) Set(ctx context.Context, req *cachepb.SetRequest) (*cachepb.SetResponse, error) {
+	if err := s.set(ctx, req); err != nil {
+		return nil, err
+	}
+	return &cachepb.SetResponse{}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *cachepb.DeleteRequest) (*cachepb.DeleteResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+	if err := s.multiCache.DeleteContext(ctx, req.Key); err != nil {
+		return nil, toStatus(err)
+	}
+	return &cachepb.DeleteResponse{}, nil
+}
+
+func (s *Server) GetAll(ctx context.Context, req *cachepb.GetAllRequest) (*cachepb.GetAllResponse, error) {
+	values, err := s.multiCache.GetAllContext(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &cachepb.GetAllResponse{Values: make(map[string]*structpb.Value, len(values))}
+	for key, value := range values {
+		v, err := structpb.NewValue(value)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "key %q: %v", key, err)
+		}
+		resp.Values[key] = v
+	}
+	return resp, nil
+}
+
+func (s *Server) DeleteAll(ctx context.Context, req *cachepb.DeleteAllRequest) (*cachepb.DeleteAllResponse, error) {
+	if err := s.multiCache.DeleteAllContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	return &cachepb.DeleteAllResponse{}, nil
+}
+
+func (s *Server) BatchGet(ctx context.Context, req *cachepb.BatchGetRequest) (*cachepb.BatchGetResponse, error) {
+	resp := &cachepb.BatchGetResponse{Values: make(map[string]*structpb.Value, len(req.Keys))}
+	for _, key := range req.Keys {
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		value, found, err := s.get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			resp.Missing = append(resp.Missing, key)
+			continue
+		}
+		resp.Values[key] = value
+	}
+	return resp, nil
+}
+
+func (s *Server) BatchSet(ctx context.Context, req *cachepb.BatchSetRequest) (*cachepb.BatchSetResponse, error) {
+	var stored int32
+	for _, item := range req.Items {
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		if err := s.set(ctx, item); err != nil {
+			return nil, err
+		}
+		stored++
+	}
+	return &cachepb.BatchSetResponse{Stored: stored}, nil
+}
+
+func (s *Server) BatchDelete(ctx context.Context, req *cachepb.BatchDeleteRequest) (*cachepb.BatchDeleteResponse, error) {
+	for _, key := range req.Keys {
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		if err := s.multiCache.DeleteContext(ctx, key); err != nil {
+			return nil, toStatus(err)
+		}
+	}
+	return &cachepb.BatchDeleteResponse{}, nil
+}
+
+func (s *Server) Watch(req *cachepb.WatchRequest, stream cachepb.Cache_WatchServer) error {
+	sub := s.multiCache.Events().Subscribe(req.Prefix, watchBuffer)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-sub.Events():
+			if !ok {
+				return nil
+			}
+			msg, err := toWatchEvent(event)
+			if err != nil {
+				return err
+			}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) get(ctx context.Context, key string) (*structpb.Value, bool, error) {
+	value, err := s.multiCache.GetContext(ctx, key)
+	if errors.Is(err, redis.Nil) || (err == nil && value == nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, toStatus(err)
+	}
+	v, err := structpb.NewValue(value)
+	if err != nil {
+		return nil, false, status.Errorf(codes.Internal, "key %q: %v", key, err)
+	}
+	return v, true, nil
+}
+
+func (s *Server) set(ctx context.Context, req *cachepb.SetRequest) error {
+	if req.Key == "" {
+		return status.Error(codes.InvalidArgument, "key is required")
+	}
+	if req.Ttl.AsDuration() < 0 { //0 or unset never expires, like ttl 0 on /cache
+		return status.Errorf(codes.InvalidArgument, "key %q: ttl must not be negative", req.Key)
+	}
+	if err := s.multiCache.SetContext(ctx, req.Key, req.Value.AsInterface(), req.Ttl.AsDuration()); err != nil {
+		return toStatus(err)
+	}
+	return nil
+}
+
+func toWatchEvent(event events.Event) (*cachepb.WatchEvent, error) {
+	msg := &cachepb.WatchEvent{Key: event.Key, Remote: event.Remote}
+	switch event.Op {
+	case events.OpSet:
+		msg.Op = cachepb.WatchEvent_OP_SET
+	case events.OpDelete:
+		msg.Op = cachepb.WatchEvent_OP_DELETE
+	case events.OpFlush:
+		msg.Op = cachepb.WatchEvent_OP_FLUSH
+	case events.OpEvict:
+		msg.Op = cachepb.WatchEvent_OP_EVICT
+	case events.OpExpire:
+		msg.Op = cachepb.WatchEvent_OP_EXPIRE
+	}
+	if event.Value != nil {
+		v, err := structpb.NewValue(event.Value)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "key %q: %v", event.Key, err)
+		}
+		msg.Value = v
+	}
+	return msg, nil
+}
+
+// toStatus maps cache errors onto gRPC codes
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, multicache.ErrRedisUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, redis_cache.ErrEmptyKey), errors.Is(err, redis_cache.ErrNegativeTTL):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
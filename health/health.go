@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrStarting = errors.New("starting") //until MarkStarted, e.g. while warming up
+	ErrStopping = errors.New("stopping") //after MarkStopping, while draining
+)
+
+// Time each check gets before it counts as failed
+const checkTimeout = time.Second
+
+// Check reports whether a dependency is usable
+type Check func(ctx context.Context) error
+
+// Readiness decides whether the service should receive traffic: once started, while every check passes
+type Readiness struct {
+	started  bool
+	stopping bool
+	checks   map[string]Check
+	since    time.Time
+	mutex    sync.Mutex
+}
+
+func NewReadiness() *Readiness {
+	return &Readiness{checks: make(map[string]Check), since: time.Now()}
+}
+
+// AddCheck registers a dependency under name, replacing an earlier check of that name
+func (r *Readiness) AddCheck(name string, check Check) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.checks[name] = check
+}
+
+// MarkStarted ends startup, call it after warm-up
+func (r *Readiness) MarkStarted() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.started = true
+}
+
+// MarkStopping fails readiness for good, call it when shutdown begins so traffic moves elsewhere
+func (r *Readiness) MarkStopping() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.stopping = true
+}
+
+// Uptime since NewReadiness
+func (r *Readiness) Uptime() time.Duration {
+	return time.Since(r.since)
+}
+
+// Check runs every check concurrently, results are "ok" or the error under each name and under startup,
+// plus shutdown once stopping
+func (r *Readiness) Check(ctx context.Context) (bool, map[string]string) {
+	r.mutex.Lock()
+	started, stopping := r.started, r.stopping
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			errs[i] = check(ctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	ready := true
+	results := map[string]string{"startup": "ok"}
+	if !started {
+		ready = false
+		results["startup"] = ErrStarting.Error()
+	}
+	if stopping {
+		ready = false
+		results["shutdown"] = ErrStopping.Error()
+	}
+	for i, name := range names {
+		results[name] = "ok"
+		if errs[i] != nil {
+			ready = false
+			results[name] = errs[i].Error()
+		}
+	}
+	return ready, results
+}
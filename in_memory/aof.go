@@ -0,0 +1,336 @@
+package in_memory
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// When the operation log is flushed to disk
+type FsyncPolicy int
+
+const (
+	FsyncAlways   FsyncPolicy = iota //after every write, slowest and safest
+	FsyncEverySec                    //once per second, may lose the last second on crash
+	FsyncNever                       //left to the operating system
+)
+
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	switch s {
+	case "always":
+		return FsyncAlways, nil
+	case "everysec":
+		return FsyncEverySec, nil
+	case "never", "no":
+		return FsyncNever, nil
+	}
+	return 0, fmt.Errorf("unknown fsync policy %q", s)
+}
+
+// Logged operations
+const (
+	logSet    = "set"
+	logDelete = "del"
+	logFlush  = "flush"
+)
+
+// One line of the operation log
+type logRecord struct {
+	Op         string      `json:"op"`
+	Key        string      `json:"key,omitempty"`
+	Value      interface{} `json:"value,omitempty"`
+	Expiration int64       `json:"exp,omitempty"` //absolute unix time like CacheItem, omitted when it never expires
+	Tags       []string    `json:"tags,omitempty"`
+}
+
+// Logs below this size are never compacted
+const minCompactSize = 64 * 1024
+
+// Append-only log of cache writes, replayed on startup
+type appendLog struct {
+	path     string
+	policy   FsyncPolicy
+	file     *os.File
+	size     int64
+	baseSize int64    //size right after the last compaction
+	err      error    //first write error, reported by CloseAppendLog
+	tail     [][]byte //lines appended while a compaction rewrites the log, nil otherwise
+	mutex    sync.Mutex
+	done     chan struct{}
+}
+
+// Replay the log at path into the cache, then record every further write to it
+func (c *LRUCache) OpenAppendLog(path string, policy FsyncPolicy, compactEvery time.Duration) (int, error) {
+	replayed, size, err := c.replayLog(path)
+	if err != nil && !os.IsNotExist(err) {
+		return replayed, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return replayed, err
+	}
+
+	aof := &appendLog{
+		path:     path,
+		policy:   policy,
+		file:     file,
+		size:     size,
+		baseSize: size,
+		done:     make(chan struct{}),
+	}
+	c.mutex.Lock()
+	c.aof = aof
+	c.mutex.Unlock()
+	go c.runAppendLog(aof, compactEvery)
+	return replayed, nil
+}
+
+// Flush and close the log, later writes are no longer recorded
+func (c *LRUCache) CloseAppendLog() error {
+	c.mutex.Lock()
+	aof := c.aof
+	c.aof = nil
+	c.mutex.Unlock()
+
+	if aof == nil {
+		return nil
+	}
+	close(aof.done)
+	aof.mutex.Lock()
+	defer aof.mutex.Unlock()
+	if err := aof.file.Sync(); err != nil && aof.err == nil {
+		aof.err = err
+	}
+	if err := aof.file.Close(); err != nil && aof.err == nil {
+		aof.err = err
+	}
+	return aof.err
+}
+
+// appendLog records one write, called with the cache mutex held so log order matches cache order
+func (c *LRUCache) appendLog(rec logRecord) {
+	if c.aof == nil {
+		return
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return //value cannot be persisted, keep serving from memory
+	}
+	line = append(line, '\n')
+
+	aof := c.aof
+	aof.mutex.Lock()
+	defer aof.mutex.Unlock()
+	n, err := aof.file.Write(line)
+	aof.size += int64(n)
+	if aof.tail != nil { //compacting, the rewritten log needs this line too
+		aof.tail = append(aof.tail, line)
+	}
+	if err == nil && aof.policy == FsyncAlways {
+		err = aof.file.Sync()
+	}
+	if err != nil && aof.err == nil {
+		aof.err = err
+	}
+}
+
+// runAppendLog fsyncs every second and compacts the log in the background
+func (c *LRUCache) runAppendLog(aof *appendLog, compactEvery time.Duration) {
+	fsync := time.NewTicker(time.Second)
+	defer fsync.Stop()
+	var compact <-chan time.Time
+	if compactEvery > 0 {
+		ticker := time.NewTicker(compactEvery)
+		defer ticker.Stop()
+		compact = ticker.C
+	}
+
+	for {
+		select {
+		case <-fsync.C:
+			if aof.policy != FsyncEverySec {
+				continue
+			}
+			aof.mutex.Lock()
+			if err := aof.file.Sync(); err != nil && aof.err == nil {
+				aof.err = err
+			}
+			aof.mutex.Unlock()
+		case <-compact:
+			aof.mutex.Lock()
+			grown := aof.size >= minCompactSize && aof.size >= 2*aof.baseSize
+			aof.mutex.Unlock()
+			if grown {
+				c.CompactAppendLog()
+			}
+		case <-aof.done:
+			return
+		}
+	}
+}
+
+// Rewrite the log as the minimal set of writes that rebuilds the current contents. The cache is only
+// locked to copy its items: writes meanwhile go to the old log and to a tail appended to the new one
+// before the files are swapped
+func (c *LRUCache) CompactAppendLog() error {
+	aof, records := c.compactStart()
+	if aof == nil {
+		return nil
+	}
+	tmp, err := c.compactWrite(aof, records)
+	if err != nil {
+		aof.mutex.Lock()
+		aof.tail = nil
+		aof.mutex.Unlock()
+		return err
+	}
+	defer os.Remove(tmp.Name()) //no-op once renamed
+
+	aof.mutex.Lock()
+	defer aof.mutex.Unlock()
+	tail := aof.tail
+	aof.tail = nil
+	select {
+	case <-aof.done: //closed meanwhile, keep the old log
+		tmp.Close()
+		return nil
+	default:
+	}
+	for _, line := range tail {
+		if _, err := tmp.Write(line); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if len(tail) > 0 {
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := os.Rename(tmp.Name(), aof.path); err != nil {
+		tmp.Close()
+		return err
+	}
+	aof.file.Close()
+	aof.file = tmp //positioned at the end, later writes append
+	if info, err := tmp.Stat(); err == nil {
+		aof.size = info.Size()
+	}
+	aof.baseSize = aof.size
+	return nil
+}
+
+// compactStart copies the live items, least recent first so replay restores the order, and starts
+// collecting the tail. Nil without a log or while another compaction runs
+func (c *LRUCache) compactStart() (*appendLog, []logRecord) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	aof := c.aof
+	if aof == nil {
+		return nil, nil
+	}
+	aof.mutex.Lock()
+	defer aof.mutex.Unlock()
+	if aof.tail != nil {
+		return nil, nil
+	}
+	aof.tail = [][]byte{}
+
+	records := make([]logRecord, 0, c.order.Len())
+	now := time.Now().Unix()
+	for el := c.order.Back(); el != nil; el = el.Prev() {
+		item := el.Value.(*CacheItem)
+		if isExpired(item.expiration, now) {
+			continue
+		}
+		records = append(records, logRecord{Op: logSet, Key: item.key, Value: item.value, Expiration: item.expiration, Tags: item.tags})
+	}
+	return aof, records
+}
+
+// compactWrite writes and fsyncs records to a temporary file next to the log, holding no lock
+func (c *LRUCache) compactWrite(aof *appendLog, records []logRecord) (*os.File, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(aof.path), filepath.Base(aof.path)+".tmp*")
+	if err != nil {
+		return nil, err
+	}
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, rec := range records {
+		if err = encoder.Encode(rec); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// replayLog applies every record in the log, truncating a torn final line
+func (c *LRUCache) replayLog(path string) (int, int64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	replayed := 0
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 { //crashed mid-write, drop the partial record
+				return replayed, offset, file.Truncate(offset)
+			}
+			return replayed, offset, nil
+		}
+		if err != nil {
+			return replayed, offset, err
+		}
+		var rec logRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return replayed, offset, fmt.Errorf("append log: bad record at offset %d: %w", offset, err)
+		}
+		c.replay(rec)
+		replayed++
+		offset += int64(len(line))
+	}
+}
+
+func (c *LRUCache) replay(rec logRecord) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch rec.Op {
+	case logSet:
+		if !isExpired(rec.Expiration, time.Now().Unix()) {
+			c.set(rec.Key, rec.Value, rec.Expiration, rec.Tags)
+		} else {
+			c.deletekey(rec.Key) //expired while down
+		}
+	case logDelete:
+		c.deletekey(rec.Key)
+	case logFlush:
+		c.items = make(map[string]*list.Element)
+		c.order.Init()
+		c.tags = make(map[string]map[string]struct{})
+	}
+}
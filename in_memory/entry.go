@@ -0,0 +1,95 @@
+package in_memory
+
+import "time"
+
+// Exported view of a cache item, used to warm up and persist the cache
+type Entry struct {
+	Key   string        `json:"key"`
+	Value interface{}   `json:"value"`
+	TTL   time.Duration `json:"ttl"` //remaining time to live, NoExpiry if it never expires
+	Tags  []string      `json:"tags,omitempty"`
+}
+
+// Entries in recency order, most recently used first
+func (c *LRUCache) Entries() []Entry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.entries()
+}
+
+func (c *LRUCache) entries() []Entry {
+	now := time.Now()
+	entries := make([]Entry, 0, c.order.Len())
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		item := el.Value.(*CacheItem)
+		ttl := NoExpiry
+		if item.expiration != 0 {
+			ttl = time.Unix(item.expiration, 0).Sub(now)
+			if ttl <= 0 {
+				continue
+			}
+		}
+		entries = append(entries, Entry{Key: item.key, Value: item.value, TTL: ttl, Tags: item.tags})
+	}
+	return entries
+}
+
+// Load entries given most recently used first, keeping their recency order. Between StartLoading and
+// FinishLoading, keys written since StartLoading are skipped
+func (c *LRUCache) Load(entries []Entry) int {
+	loaded := 0
+	for i := len(entries) - 1; i >= 0; i-- { //least recent first, so the most recent ends up in front
+		ttl := entries[i].TTL
+		if ttl == NoExpiry {
+			ttl = 0
+		} else if ttl <= 0 {
+			continue
+		}
+		if c.load(entries[i], ttl) {
+			loaded++
+		}
+	}
+	return loaded
+}
+
+// load stores one entry unless the guard skips it, locking per entry so requests are served meanwhile
+func (c *LRUCache) load(entry Entry, ttl time.Duration) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.loading != nil {
+		if _, written := c.loading.written[entry.Key]; written || c.loading.flushed {
+			return false
+		}
+	}
+	c.store(entry.Key, entry.Value, ttl, entry.Tags)
+	return true
+}
+
+// Writes seen since StartLoading, which loaded entries must not replace
+type loadGuard struct {
+	written map[string]struct{} //keys set, deleted, expired or persisted
+	flushed bool                //DeleteAll ran, every loaded entry is older
+}
+
+// StartLoading makes Load skip keys that are set, deleted or given a new ttl from now on, and every key
+// once the cache is flushed, so warming up while already serving never replaces newer writes
+func (c *LRUCache) StartLoading() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.loading = &loadGuard{written: make(map[string]struct{})}
+}
+
+// FinishLoading stops tracking writes, Load replaces keys again
+func (c *LRUCache) FinishLoading() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.loading = nil
+}
+
+// written records a write for the load guard, mutex must be held
+func (c *LRUCache) written(key string) {
+	if c.loading != nil {
+		c.loading.written[key] = struct{}{}
+	}
+}
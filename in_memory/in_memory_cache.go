@@ -2,23 +2,62 @@ package in_memory
 
 import (
 	"container/list"
+	"context"
+	"log/slog"
 	"sync"
 	"time"
+
+	"unified/events"
 )
 
 type CacheItem struct {
 	key        string
 	value      interface{}
-	expiration int64
+	expiration int64 //unix time, 0 never expires
+	tags       []string
+}
+
+// Remaining ttl reported for keys that never expire, matches redis
+const NoExpiry time.Duration = -1
+
+func isExpired(expiration int64, now int64) bool {
+	return expiration != 0 && expiration < now
 }
 
 type LRUCache struct {
-	capacity int
-	ttl      int64
-	items    map[string]*list.Element //HashMap for kvp
-	order    *list.List               //DLL for LRU order
-	mutex    sync.Mutex
-	evictCh  chan string //manual key eviction
+	capacity     int
+	ttl          int64
+	items        map[string]*list.Element       //HashMap for kvp
+	order        *list.List                     //DLL for LRU order
+	tags         map[string]map[string]struct{} //tag index, keys per tag
+	mutex        sync.Mutex
+	evictCh      chan string   //manual key eviction
+	stop         chan struct{} //closed by Close to stop the janitor
+	closeOnce    sync.Once
+	negCapacity  int                      //negative cache, disabled when 0
+	negTTL       time.Duration            //lifetime of negative entries
+	negItems     map[string]*list.Element //known-missing keys
+	negOrder     *list.List               //LRU order of known-missing keys
+	hits         int64
+	misses       int64
+	negativeHits int64
+	evictions    int64
+	expirations  int64
+	aof          *appendLog //optional operation log
+	events       *events.Bus
+	janitor      func(elapsed time.Duration) //observes expiry sweeps
+	loading      *loadGuard                  //writes Load must not replace, nil unless loading
+}
+
+type Stats struct {
+	Items           int   `json:"items"`
+	Capacity        int   `json:"capacity"`
+	Hits            int64 `json:"hits"`
+	Misses          int64 `json:"misses"`
+	Evictions       int64 `json:"evictions"`
+	Expirations     int64 `json:"expirations"`
+	NegativeEntries int   `json:"negative_entries"`
+	NegativeHits    int64 `json:"negative_hits"`
 }
 
 func NewLRUCache(capacity int, ttl int64) *LRUCache {
@@ -27,7 +66,10 @@ func NewLRUCache(capacity int, ttl int64) *LRUCache {
 		ttl:      ttl,
 		items:    make(map[string]*list.Element),
 		order:    list.New(),
+		tags:     make(map[string]map[string]struct{}),
 		evictCh:  make(chan string, capacity),
+		stop:     make(chan struct{}),
+		events:   events.NewBus(),
 	}
 	go c.startEvictionRoutine()
 	return c
@@ -40,41 +82,102 @@ func (c *LRUCache) startEvictionRoutine() {
 	for {
 		select {
 		case <-ticker.C: //period cleanup based on cache ttl
-			c.evictExpired()
+			c.runJanitor()
 		case key := <-c.evictCh:
-			c.deletekey(key) //manual eviction
+			c.expireKey(key) //manual eviction
+		case <-c.stop:
+			return
 		}
 	}
 }
 
+// Close stops the janitor and flushes and closes the append-only log, the cache still serves afterwards
+func (c *LRUCache) Close() error {
+	c.closeOnce.Do(func() { close(c.stop) })
+	return c.CloseAppendLog()
+}
+
+// debug logs a cache operation by key, values are left out. Free while debug logging is off
+func debug(msg string, attrs ...slog.Attr) {
+	slog.LogAttrs(context.Background(), slog.LevelDebug, msg, attrs...)
+}
+
+// OnJanitorRun registers fn to receive the duration of every periodic expiry sweep
+func (c *LRUCache) OnJanitorRun(fn func(elapsed time.Duration)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.janitor = fn
+}
+
+func (c *LRUCache) runJanitor() {
+	start := time.Now()
+	c.evictExpired()
+	c.mutex.Lock()
+	observe := c.janitor
+	c.mutex.Unlock()
+	if observe != nil {
+		observe(time.Since(start))
+	}
+}
+
 func (c *LRUCache) evictExpired() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
 	now := time.Now().Unix()
 	for key, el := range c.items {
-		if el.Value.(*CacheItem).expiration < now {
-			//fmt.Printf("Evicting expired key: %s\n", key)
+		if isExpired(el.Value.(*CacheItem).expiration, now) {
+			debug("evicting expired key", slog.String("key", key))
 			delete(c.items, key)
 			c.order.Remove(el)
+			c.untag(el.Value.(*CacheItem))
+			c.expirations++
+			c.events.Publish(events.Event{Op: events.OpExpire, Key: key, Value: el.Value.(*CacheItem).value})
+		}
+	}
+	for key, el := range c.negItems {
+		if time.Now().After(el.Value.(*negativeItem).expiresAt) {
+			c.deleteMissing(key)
 		}
 	}
 }
 
+// Set stores value for expiration, an expiration of 0 never expires
 func (c *LRUCache) Set(key string, value interface{}, expiration time.Duration) {
-	if expiration == 0 {
+	c.SetWithTags(key, value, expiration)
+}
+
+// SetWithTags is Set, attaching tags that InvalidateTag deletes the key by. Replaces earlier tags of key
+func (c *LRUCache) SetWithTags(key string, value interface{}, expiration time.Duration, tags ...string) {
+	if expiration < 0 {
 		return
 	}
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	expirationTime := time.Now().Unix() + int64(expiration.Seconds()) //unix time for easier computation
+	c.written(key)
+	c.store(key, value, expiration, tags)
+}
+
+// store sets key and records it in the append-only log and events, mutex must be held
+func (c *LRUCache) store(key string, value interface{}, expiration time.Duration, tags []string) {
+	expirationTime := expiresAt(expiration)
+	c.set(key, value, expirationTime, tags)
+	c.appendLog(logRecord{Op: logSet, Key: key, Value: value, Expiration: expirationTime, Tags: tags})
+	c.events.Publish(events.Event{Op: events.OpSet, Key: key, Value: value})
+}
+
+func (c *LRUCache) set(key string, value interface{}, expirationTime int64, tags []string) {
+	c.deleteMissing(key) //key exists now
 
 	if el, ok := c.items[key]; ok {
 		//if exists, update existing
 		c.order.MoveToFront(el)
+		c.untag(el.Value.(*CacheItem))
 		el.Value.(*CacheItem).value = value
 		el.Value.(*CacheItem).expiration = expirationTime
-		//fmt.Printf("Updated key: %s, value: %v, expiration: %d\n", key, value, expirationTime)
+		el.Value.(*CacheItem).tags = tags
+		c.tag(el.Value.(*CacheItem))
+		debug("updated key", slog.String("key", key), slog.Int64("expiration", expirationTime))
 		return
 	}
 
@@ -86,10 +189,12 @@ func (c *LRUCache) Set(key string, value interface{}, expiration time.Duration)
 		key:        key,
 		value:      value,
 		expiration: expirationTime,
+		tags:       tags,
 	}
 	el := c.order.PushFront(item) //LRU order
 	c.items[key] = el
-	//fmt.Printf("Set key: %s, value: %v, expiration: %d\n", key, value, expirationTime)
+	c.tag(item)
+	debug("set key", slog.String("key", key), slog.Int64("expiration", expirationTime))
 }
 
 func (c *LRUCache) Get(key string) (interface{}, bool) {
@@ -98,22 +203,93 @@ func (c *LRUCache) Get(key string) (interface{}, bool) {
 
 	el, ok := c.items[key]
 	if !ok {
-		//fmt.Printf("Get key: %s not found\n", key)
+		debug("get key not found", slog.String("key", key))
+		c.misses++
 		return nil, false
 	}
 
 	now := time.Now().Unix()
-	if el.Value.(*CacheItem).expiration < now {
-		//fmt.Printf("Get key: %s expired\n", key)
+	if isExpired(el.Value.(*CacheItem).expiration, now) {
+		debug("get key expired", slog.String("key", key))
 		c.deletekey(key)
+		c.misses++
+		c.expirations++
+		c.events.Publish(events.Event{Op: events.OpExpire, Key: key, Value: el.Value.(*CacheItem).value})
 		return nil, false
 	}
 
+	c.hits++
 	c.order.MoveToFront(el)
-	//fmt.Printf("Get key: %s, value: %v\n", key, el.Value.(*CacheItem).value)
+	debug("get key", slog.String("key", key))
 	return el.Value.(*CacheItem).value, true
 }
 
+// Remaining time to live, NoExpiry for persistent keys, false if the key is missing or expired
+func (c *LRUCache) TTL(key string) (time.Duration, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return 0, false
+	}
+	if el.Value.(*CacheItem).expiration == 0 {
+		return NoExpiry, true
+	}
+	remaining := time.Until(time.Unix(el.Value.(*CacheItem).expiration, 0))
+	if remaining < 0 {
+		return 0, false
+	}
+	return remaining, true
+}
+
+// Expire resets the ttl of key without rewriting its value, a ttl of 0 or less deletes it
+func (c *LRUCache) Expire(key string, ttl time.Duration) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.items[key]
+	if !ok || isExpired(el.Value.(*CacheItem).expiration, time.Now().Unix()) {
+		return false
+	}
+	c.written(key)
+	if ttl <= 0 {
+		c.deletekey(key)
+		c.appendLog(logRecord{Op: logDelete, Key: key})
+		c.events.Publish(events.Event{Op: events.OpDelete, Key: key})
+		return true
+	}
+	c.expire(el.Value.(*CacheItem), expiresAt(ttl))
+	return true
+}
+
+// Persist removes the ttl of key so it never expires
+func (c *LRUCache) Persist(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.items[key]
+	if !ok || isExpired(el.Value.(*CacheItem).expiration, time.Now().Unix()) {
+		return false
+	}
+	c.written(key)
+	c.expire(el.Value.(*CacheItem), 0)
+	return true
+}
+
+func (c *LRUCache) expire(item *CacheItem, expirationTime int64) {
+	item.expiration = expirationTime
+	c.appendLog(logRecord{Op: logSet, Key: item.key, Value: item.value, Expiration: expirationTime, Tags: item.tags})
+}
+
+// expiresAt converts a ttl to unix time for easier computation, 0 stays 0 (never expires)
+func expiresAt(ttl time.Duration) int64 {
+	if ttl == 0 {
+		return 0
+	}
+	return time.Now().Unix() + int64(ttl.Seconds())
+}
+
 func (c *LRUCache) GetAll() map[string]interface{} {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -121,10 +297,15 @@ func (c *LRUCache) GetAll() map[string]interface{} {
 	result := make(map[string]interface{})
 	now := time.Now().Unix()
 	for key, el := range c.items {
-		if el.Value.(*CacheItem).expiration >= now {
+		if !isExpired(el.Value.(*CacheItem).expiration, now) {
 			result[key] = el.Value.(*CacheItem).value
 		} else {
-			go func(k string) { c.evictCh <- k }(key) //startEvictionRoutine
+			go func(k string) { //startEvictionRoutine
+				select {
+				case c.evictCh <- k:
+				case <-c.stop:
+				}
+			}(key)
 		}
 	}
 	return result
@@ -134,13 +315,18 @@ func (c *LRUCache) Delete(key string) bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	c.deleteMissing(key)
+	c.written(key) //deleted before its older copy loads
 	if el, ok := c.items[key]; ok {
 		delete(c.items, key)
 		c.order.Remove(el)
-		//fmt.Printf("Deleted key: %s\n", key)
+		c.untag(el.Value.(*CacheItem))
+		c.appendLog(logRecord{Op: logDelete, Key: key})
+		c.events.Publish(events.Event{Op: events.OpDelete, Key: key})
+		debug("deleted key", slog.String("key", key))
 		return true
 	} else {
-		//fmt.Println("Key is not found")
+		debug("delete key not found", slog.String("key", key))
 		return false
 	}
 }
@@ -149,30 +335,79 @@ func (c *LRUCache) DeleteAll() bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	if c.negOrder != nil {
+		c.negItems = make(map[string]*list.Element)
+		c.negOrder.Init()
+	}
+	if c.loading != nil {
+		c.loading.flushed = true
+	}
 	if len(c.items) == 0 {
-		//fmt.Println("No keys found")
+		debug("delete all found no keys")
 		return false
 	}
 
 	c.items = make(map[string]*list.Element)
 	c.order.Init() //delete list
-	//fmt.Println("Deleted all keys")
+	c.tags = make(map[string]map[string]struct{})
+	c.appendLog(logRecord{Op: logFlush})
+	c.events.Publish(events.Event{Op: events.OpFlush})
+	debug("deleted all keys")
 	return true
 }
 
+func (c *LRUCache) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return Stats{
+		Items:           len(c.items),
+		Capacity:        c.capacity,
+		Hits:            c.hits,
+		Misses:          c.misses,
+		Evictions:       c.evictions,
+		Expirations:     c.expirations,
+		NegativeEntries: len(c.negItems),
+		NegativeHits:    c.negativeHits,
+	}
+}
+
 func (c *LRUCache) evict() {
 	el := c.order.Back() //access the LRU element
 	if el != nil {
 		c.order.Remove(el)
 		item := el.Value.(*CacheItem)
 		delete(c.items, item.key)
-		//fmt.Printf("Evicted key: %s\n", item.key)
+		c.untag(item)
+		c.evictions++
+		c.events.Publish(events.Event{Op: events.OpEvict, Key: item.key, Value: item.value})
+		debug("evicted key", slog.String("key", item.key))
 	}
 }
 
+// expireKey drops key if it is still expired, it may have been set again since it was queued
+func (c *LRUCache) expireKey(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.items[key]
+	if !ok || !isExpired(el.Value.(*CacheItem).expiration, time.Now().Unix()) {
+		return
+	}
+	c.deletekey(key)
+	c.expirations++
+	c.events.Publish(events.Event{Op: events.OpExpire, Key: key, Value: el.Value.(*CacheItem).value})
+}
+
+// Events carries set, delete, flush, evict and expire events of this cache
+func (c *LRUCache) Events() *events.Bus {
+	return c.events
+}
+
 func (c *LRUCache) deletekey(key string) {
 	if el, ok := c.items[key]; ok {
 		delete(c.items, key) //map delete builtin
 		c.order.Remove(el)
+		c.untag(el.Value.(*CacheItem))
 	}
 }
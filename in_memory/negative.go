@@ -0,0 +1,74 @@
+package in_memory
+
+import (
+	"container/list"
+	"time"
+)
+
+// Negative entry, remembers that a key is known to be missing from the backing store
+type negativeItem struct {
+	key       string
+	expiresAt time.Time
+}
+
+// Keep known-missing keys in their own LRU list, capped at capacity, so they never evict real values
+func (c *LRUCache) EnableNegativeCache(capacity int, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.negCapacity = capacity
+	c.negTTL = ttl
+	c.negItems = make(map[string]*list.Element)
+	c.negOrder = list.New()
+}
+
+// Record key as missing, cleared by a later Set or Delete
+func (c *LRUCache) SetMissing(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.negCapacity <= 0 {
+		return
+	}
+	if _, ok := c.items[key]; ok { //a real value was set meanwhile
+		return
+	}
+	expiresAt := time.Now().Add(c.negTTL)
+	if el, ok := c.negItems[key]; ok {
+		c.negOrder.MoveToFront(el)
+		el.Value.(*negativeItem).expiresAt = expiresAt
+		return
+	}
+	if c.negOrder.Len() >= c.negCapacity {
+		if el := c.negOrder.Back(); el != nil {
+			c.negOrder.Remove(el)
+			delete(c.negItems, el.Value.(*negativeItem).key)
+		}
+	}
+	c.negItems[key] = c.negOrder.PushFront(&negativeItem{key: key, expiresAt: expiresAt})
+}
+
+// Reports whether key is cached as missing
+func (c *LRUCache) IsMissing(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.negItems[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(el.Value.(*negativeItem).expiresAt) {
+		c.deleteMissing(key)
+		return false
+	}
+	c.negOrder.MoveToFront(el)
+	c.negativeHits++
+	return true
+}
+
+func (c *LRUCache) deleteMissing(key string) {
+	if el, ok := c.negItems[key]; ok {
+		delete(c.negItems, key)
+		c.negOrder.Remove(el)
+	}
+}
@@ -0,0 +1,201 @@
+package in_memory
+
+import (
+	"container/heap"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid scan cursor")
+
+// ScanStart is the cursor that begins a scan and is returned when it is complete, as in Redis SCAN
+const ScanStart = "0"
+
+// Scan returns up to count unexpired items matching the glob pattern (empty matches all) and the
+// cursor of the next page. Keys are visited in sorted order, the cursor being the last key returned,
+// so keys present for the whole scan are returned exactly once
+func (c *LRUCache) Scan(cursor string, match string, count int) (map[string]interface{}, string, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	if count <= 0 {
+		count = 10
+	}
+
+	resume := cursor != "" && cursor != ScanStart
+
+	c.mutex.Lock()
+	now := time.Now().Unix()
+	smallest := make(keyHeap, 0, min(count, len(c.items))+1) //the count+1 smallest keys after the cursor, the extra one tells a page follows
+	for key, el := range c.items {
+		if resume && key <= after {
+			continue
+		}
+		if len(smallest) > count && key >= smallest[0] {
+			continue
+		}
+		if isExpired(el.Value.(*CacheItem).expiration, now) || (match != "" && !MatchGlob(match, key)) {
+			continue
+		}
+		heap.Push(&smallest, key)
+		if len(smallest)-1 > count {
+			heap.Pop(&smallest)
+		}
+	}
+	keys := []string(smallest)
+	sort.Strings(keys)
+	more := len(keys) > count
+	if more {
+		keys = keys[:count]
+	}
+	values := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		values[key] = c.items[key].Value.(*CacheItem).value
+	}
+	c.mutex.Unlock()
+
+	if !more {
+		return values, ScanStart, nil
+	}
+	return values, encodeCursor(keys[len(keys)-1]), nil
+}
+
+// keyHeap is a max-heap of keys, bounding Scan to the smallest ones without sorting the whole cache
+type keyHeap []string
+
+func (h keyHeap) Len() int           { return len(h) }
+func (h keyHeap) Less(i, j int) bool { return h[i] > h[j] }
+func (h keyHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *keyHeap) Push(x interface{}) {
+	*h = append(*h, x.(string))
+}
+
+func (h *keyHeap) Pop() interface{} {
+	old := *h
+	key := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return key
+}
+
+// In-memory cursors are "k" and the base64 of the last key, so they never look like Redis cursors
+func encodeCursor(key string) string {
+	return "k" + base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	if cursor == "" || cursor == ScanStart {
+		return "", nil
+	}
+	if !IsCursor(cursor) {
+		return "", ErrInvalidCursor
+	}
+	key, err := base64.RawURLEncoding.DecodeString(cursor[1:])
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+	return string(key), nil
+}
+
+// IsCursor reports whether cursor continues an in-memory scan
+func IsCursor(cursor string) bool {
+	return strings.HasPrefix(cursor, "k")
+}
+
+// GlobPrefix returns a pattern matching keys that start with prefix
+func GlobPrefix(prefix string) string {
+	return EscapeGlob(prefix) + "*"
+}
+
+// EscapeGlob quotes the glob special characters in s, for MatchGlob and Redis SCAN MATCH alike
+func EscapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// MatchGlob reports whether key matches a Redis style glob: * ? [abc] [^a-z] and \ escapes
+func MatchGlob(pattern string, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if MatchGlob(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 { //unterminated class matches literally
+				if key[0] != '[' {
+					return false
+				}
+				key, pattern = key[1:], pattern[1:]
+				continue
+			}
+			class := pattern[1 : end+1]
+			negate := strings.HasPrefix(class, "^")
+			if negate {
+				class = class[1:]
+			}
+			if matchClass(class, key[0]) == negate {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[end+2:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(key) == 0 || key[0] != pattern[0] {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(key) == 0
+}
+
+func matchClass(class string, c byte) bool {
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			if class[i] <= c && c <= class[i+2] {
+				return true
+			}
+			i += 2
+			continue
+		}
+		if class[i] == c {
+			return true
+		}
+	}
+	return false
+}
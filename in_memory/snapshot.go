@@ -0,0 +1,137 @@
+package in_memory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Snapshot format: a JSON header line followed by one Entry per line, most recently used first
+const SnapshotVersion = 1
+
+type snapshotHeader struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"` //ttls are relative to this
+	Count   int       `json:"count"`
+}
+
+// Snapshot writes keys, values, remaining ttls and recency order to w
+func (c *LRUCache) Snapshot(w io.Writer) error {
+	entries := c.Entries()
+	writer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+	header := snapshotHeader{Version: SnapshotVersion, Created: time.Now(), Count: len(entries)}
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// Restore loads a snapshot on top of the current contents, returns the number of keys loaded
+func (c *LRUCache) Restore(r io.Reader) (int, error) {
+	entries, err := ReadSnapshot(r)
+	if err != nil {
+		return 0, err
+	}
+	return c.Load(entries), nil
+}
+
+// ReadSnapshot decodes a snapshot, ttls are shortened by the time since it was taken
+func ReadSnapshot(r io.Reader) ([]Entry, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) //allow large values
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("snapshot: missing header")
+	}
+	var header snapshotHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("snapshot: bad header: %w", err)
+	}
+	if header.Version != SnapshotVersion {
+		return nil, fmt.Errorf("snapshot: unsupported version %d", header.Version)
+	}
+
+	elapsed := time.Since(header.Created)
+	entries := make([]Entry, 0, header.Count)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("snapshot: bad entry: %w", err)
+		}
+		if entry.TTL == NoExpiry {
+			entries = append(entries, entry)
+			continue
+		}
+		entry.TTL -= elapsed
+		if entry.TTL > 0 {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SaveSnapshot writes a snapshot to path, replacing it atomically
+func (c *LRUCache) SaveSnapshot(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //no-op once renamed
+
+	if err := c.Snapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *LRUCache) LoadSnapshot(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return c.Restore(file)
+}
+
+// Save a snapshot every interval until the returned stop function is called
+func (c *LRUCache) StartSnapshots(path string, interval time.Duration, onError func(error)) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.SaveSnapshot(path); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
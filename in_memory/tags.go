@@ -0,0 +1,60 @@
+package in_memory
+
+import (
+	"sort"
+
+	"unified/events"
+)
+
+// InvalidateTag deletes every key set with tag, returns the deleted keys
+func (c *LRUCache) InvalidateTag(tag string) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	keys := c.taggedKeys(tag)
+	for _, key := range keys {
+		c.deletekey(key) //also drops key from its other tags
+		c.written(key)
+		c.appendLog(logRecord{Op: logDelete, Key: key})
+		c.events.Publish(events.Event{Op: events.OpDelete, Key: key})
+	}
+	return keys
+}
+
+// Keys currently tagged with tag
+func (c *LRUCache) TaggedKeys(tag string) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.taggedKeys(tag)
+}
+
+func (c *LRUCache) taggedKeys(tag string) []string {
+	keys := make([]string, 0, len(c.tags[tag]))
+	for key := range c.tags[tag] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// tag adds item to the index of each of its tags, called with the mutex held
+func (c *LRUCache) tag(item *CacheItem) {
+	for _, tag := range item.tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		keys[item.key] = struct{}{}
+	}
+}
+
+// untag removes item from the index, dropping tags left without keys
+func (c *LRUCache) untag(item *CacheItem) {
+	for _, tag := range item.tags {
+		delete(c.tags[tag], item.key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
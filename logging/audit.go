@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net"
+
+	"unified/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Action is a mutating operation recorded in the audit log
+type Action struct {
+	Name string //set, expire, delete, flush, invalidate_tag or reconcile
+	Key  string //the key, or the tag of invalidate_tag, empty for operations on the whole cache
+}
+
+// Audit logs who ran each operation classify reports as mutating, with its key and outcome.
+// Use it before auth.Middleware so rejected attempts are recorded too
+func Audit(logger *slog.Logger, classify func(*gin.Context) (Action, bool)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		action, ok := classify(c)
+		if !ok {
+			c.Next()
+			return
+		}
+		c.Next()
+
+		who := "anonymous"
+		if principal := auth.PrincipalOf(c); principal != nil {
+			who = principal.Name
+		}
+		logger.LogAttrs(c.Request.Context(), slog.LevelInfo, "audit",
+			slog.String("request_id", RequestID(c)),
+			slog.String("who", who),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("action", action.Name),
+			slog.String("key", action.Key),
+			slog.String("namespace", c.Param("namespace")),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", c.Writer.Status()),
+		)
+	}
+}
+
+// Call is a mutating command of the gRPC, Redis or memcached protocol
+type Call struct {
+	Protocol string   //grpc, resp or memcache
+	Command  string   //the gRPC method or the protocol command, such as SET or flush_all
+	Who      string   //the authenticated caller, empty for anonymous
+	Client   net.Addr //the connection's remote address
+	Action   Action
+}
+
+// Auditor records the mutating calls of the non-HTTP protocols in the audit log Audit writes.
+// A nil Auditor records nothing
+type Auditor struct {
+	logger *slog.Logger
+}
+
+func NewAuditor(logger *slog.Logger) *Auditor {
+	return &Auditor{logger: logger}
+}
+
+// Record logs call, err is its outcome
+func (a *Auditor) Record(ctx context.Context, call Call, err error) {
+	if a == nil {
+		return
+	}
+	who := call.Who
+	if who == "" {
+		who = "anonymous"
+	}
+	clientIP := ""
+	if call.Client != nil {
+		clientIP = call.Client.String()
+		if host, _, splitErr := net.SplitHostPort(clientIP); splitErr == nil {
+			clientIP = host
+		}
+	}
+	outcome := ""
+	if err != nil {
+		outcome = err.Error()
+	}
+	a.logger.LogAttrs(ctx, slog.LevelInfo, "audit",
+		slog.String("protocol", call.Protocol),
+		slog.String("who", who),
+		slog.String("client_ip", clientIP),
+		slog.String("action", call.Action.Name),
+		slog.String("key", call.Action.Key),
+		slog.String("command", call.Command),
+		slog.String("error", outcome),
+	)
+}
@@ -1,44 +1,479 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 	api "unified/api_handler"
+	"unified/auth"
+	"unified/cache_store"
+	"unified/disk_cache"
+	"unified/grpc_api"
+	"unified/health"
 	"unified/in_memory"
+	"unified/logging"
+	"unified/memcache_server"
+	"unified/metrics"
 	"unified/multicache"
+	"unified/namespace"
+	"unified/ratelimit"
 	"unified/redis_cache"
+	"unified/resp_server"
+	"unified/tracing"
+	"unified/warmup"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"google.golang.org/grpc"
 )
 
 func main() {
 	//set max capacity
 	var maxCacheCapacity int
+	var invalidationChannel string
+	var failureThreshold, maxQueuedWrites, negativeCapacity, warmupKeys, namespaceCapacity, maxNamespaces, respMaxBulk int
+	var negativeTTL time.Duration
+	var writePolicy, reconcileMode, reconcileAuthority, warmupSource, snapshotFile, aofFile, aofFsync, diskDir, diskTier, respAddr, respBackend, memcacheAddr, memcacheBackend, grpcAddr, authFile, rateLimits, rateLimitIP, trustedProxies, traceExporter, traceEndpoint, logLevel, logFormat, auditFile string
+	var diskSegmentSize int64
+	var flushRedis, restore, rateLimitRedis bool
+	var snapshotInterval, aofCompactInterval, shutdownDelay, shutdownTimeout time.Duration
 	flag.IntVar(&maxCacheCapacity, "cache-capacity", 3, "Maximum capacity of the cache")
+	flag.StringVar(&invalidationChannel, "invalidation-channel", "cache_invalidation", "Redis channel for cross-instance invalidation, empty to disable")
+	flag.IntVar(&failureThreshold, "redis-failure-threshold", 5, "Consecutive Redis failures before serving from memory only")
+	flag.StringVar(&writePolicy, "redis-write-policy", "drop", "Redis writes while unavailable: drop or queue")
+	flag.IntVar(&maxQueuedWrites, "redis-max-queued", 1000, "Maximum Redis writes queued while unavailable")
+	flag.StringVar(&reconcileMode, "reconcile", "off", "GetAll tier divergence handling: off, report or repair")
+	flag.StringVar(&reconcileAuthority, "reconcile-authority", "redis", "Authoritative tier when repairing: redis or memory")
+	flag.IntVar(&negativeCapacity, "negative-cache-capacity", 0, "Known-missing keys kept in memory, 0 to disable")
+	flag.DurationVar(&negativeTTL, "negative-cache-ttl", 5*time.Second, "How long a key stays known-missing")
+	flag.BoolVar(&flushRedis, "flush-redis", true, "Flush Redis on startup, skipped when warming up from Redis")
+	flag.StringVar(&warmupSource, "warmup", "", "Preload the in-memory cache at startup, /readyz fails until done: redis or snapshot")
+	flag.IntVar(&warmupKeys, "warmup-keys", 0, "Most recently used keys to preload, 0 for the cache capacity")
+	flag.StringVar(&snapshotFile, "snapshot-file", "cache.snapshot", "In-memory cache snapshot file")
+	flag.DurationVar(&snapshotInterval, "snapshot-interval", 0, "Save a snapshot this often, 0 to disable")
+	flag.BoolVar(&restore, "restore", false, "Restore the whole snapshot on startup")
+	flag.StringVar(&aofFile, "aof-file", "", "Append-only log of in-memory cache writes, empty to disable")
+	flag.StringVar(&aofFsync, "aof-fsync", "everysec", "Append-only log fsync policy: always, everysec or never")
+	flag.DurationVar(&aofCompactInterval, "aof-compact-interval", time.Minute, "How often to check whether the append-only log needs compaction")
+	flag.StringVar(&diskDir, "disk-dir", "", "Directory of the on-disk cache tier, empty to disable")
+	flag.StringVar(&diskTier, "disk-tier", "l3", "Place the disk tier behind Redis (l3) or instead of Redis (l2)")
+	flag.Int64Var(&diskSegmentSize, "disk-segment-size", 64<<20, "Disk tier segment size in bytes")
+	flag.StringVar(&respAddr, "resp-addr", "", "Serve the Redis protocol on this address (e.g. :6380), empty to disable; not authenticated, bind to a trusted interface")
+	flag.StringVar(&respBackend, "resp-backend", "multi", "Cache served over the Redis protocol: multi or memory")
+	flag.IntVar(&respMaxBulk, "resp-max-bulk", resp_server.DefaultMaxBulk, "Largest key or value accepted over the Redis protocol in bytes")
+	flag.StringVar(&memcacheAddr, "memcache-addr", "", "Serve the memcached protocol on this address (e.g. :11211), empty to disable; not authenticated, bind to a trusted interface")
+	flag.StringVar(&memcacheBackend, "memcache-backend", "multi", "Cache served over the memcached protocol: multi or memory")
+	flag.StringVar(&grpcAddr, "grpc-addr", "", "Serve the gRPC API on this address (e.g. :9090), empty to disable")
+	flag.IntVar(&namespaceCapacity, "namespace-capacity", 0, "Capacity of each namespace under /ns, 0 for -cache-capacity")
+	flag.IntVar(&maxNamespaces, "max-namespaces", 100, "Maximum number of namespaces, 0 for no limit")
+	flag.StringVar(&authFile, "auth-file", "", "JSON file of API keys and the token secret for the HTTP and gRPC APIs, empty to serve without authentication; the Redis and memcached protocols are never authenticated")
+	flag.StringVar(&rateLimits, "rate-limit", "", "Token buckets per route group and caller, e.g. cache=100:200,*=50:100 (rate per second:burst), empty to disable")
+	flag.StringVar(&rateLimitIP, "rate-limit-ip", "", "Token bucket per client IP and route group checked before authentication, e.g. 50:100, empty to disable")
+	flag.BoolVar(&rateLimitRedis, "rate-limit-redis", false, "Share rate limit buckets across instances through Redis")
+	flag.StringVar(&trustedProxies, "trusted-proxies", "", "Comma-separated proxy IPs or CIDRs whose X-Forwarded-For gives the client IP, empty to use the connection address")
+	flag.StringVar(&traceExporter, "trace-exporter", "", "Export OpenTelemetry spans: stdout or otlp, empty to disable tracing")
+	flag.StringVar(&logLevel, "log-level", "info", "Log level: debug (also logs in-memory cache operations), info, warn or error")
+	flag.StringVar(&logFormat, "log-format", "text", "Log format: text or json")
+	flag.StringVar(&auditFile, "audit-log", "", "Append a JSON audit record of every mutating HTTP request and gRPC, Redis or memcached protocol command to this file, empty to disable")
+	flag.DurationVar(&shutdownDelay, "shutdown-delay", 5*time.Second, "How long /readyz fails on SIGTERM or SIGINT before the servers stop accepting, so load balancers stop sending traffic")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 15*time.Second, "How long to drain in-flight requests on SIGTERM or SIGINT before closing connections")
+	flag.StringVar(&traceEndpoint, "trace-endpoint", "", "OTLP/HTTP collector host:port, empty for OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318")
 	flag.Parse()
+
+	//structured logs, the standard log package writes through the same handler
+	logger, err := logging.New(os.Stderr, logLevel, logFormat)
+	if err != nil {
+		log.Fatalf("Invalid logging flags: %v", err)
+	}
+	slog.SetDefault(logger)
 	//initiate redis and in-memory
 	inMemoryCache := in_memory.NewLRUCache(maxCacheCapacity, 60)
-	redisCache := redis_cache.NewCache("localhost:6379", "", 0, maxCacheCapacity)
+	if negativeCapacity > 0 {
+		inMemoryCache.EnableNegativeCache(negativeCapacity, negativeTTL)
+	}
+	if aofFile != "" {
+		policy, err := in_memory.ParseFsyncPolicy(aofFsync)
+		if err != nil {
+			log.Fatalf("Invalid -aof-fsync: %v", err)
+		}
+		replayed, err := inMemoryCache.OpenAppendLog(aofFile, policy, aofCompactInterval)
+		if err != nil {
+			log.Fatalf("Failed to open append-only log: %v", err)
+		}
+		log.Printf("Replayed %d operations from %s", replayed, aofFile)
+	}
+	//spans of HTTP requests, MultiCache tiers and redis commands
+	shutdownTracing := func(context.Context) error { return nil }
+	if traceExporter != "" {
+		shutdownTracing, err = tracing.Setup(traceExporter, traceEndpoint)
+		if err != nil {
+			log.Fatalf("Failed to set up tracing: %v", err)
+		}
+	}
+	//prometheus metrics served on /metrics
+	serviceMetrics := metrics.New()
+	serviceMetrics.ObserveJanitor("", inMemoryCache)
+	var redisCache *redis_cache.RedisCache
+	switch {
+	case diskDir != "" && diskTier == "l2": //disk replaces redis
+		if warmupSource == "redis" {
+			log.Fatalf("-warmup=redis needs Redis, but -disk-tier=l2 runs without it")
+		}
+	case flushRedis && warmupSource != "redis":
+		redisCache = redis_cache.NewCache("localhost:6379", "", 0, maxCacheCapacity)
+	default:
+		redisCache = redis_cache.Connect("localhost:6379", "", 0, maxCacheCapacity)
+	}
+	if redisCache != nil {
+		serviceMetrics.InstrumentRedis(redisCache.Client) //namespaces share the client
+		tracing.InstrumentRedis(redisCache.Client)
+	}
+	//ready once warmed up and while redis answers
+	readiness := health.NewReadiness()
+	if redisCache != nil {
+		if err := redisCache.Ping(); err != nil {
+			log.Printf("Redis is unreachable, serving degraded until it answers: %v", err)
+		}
+		readiness.AddCheck("redis", func(ctx context.Context) error {
+			return redisCache.WithContext(ctx).Ping()
+		})
+	}
 	multiCache := multicache.NewMultiCache(inMemoryCache, redisCache)
+	var diskCache *disk_cache.DiskCache
+	if diskDir != "" {
+		diskCache, err = disk_cache.NewDiskCache(diskDir, diskSegmentSize)
+		if err != nil {
+			log.Fatalf("Failed to open disk tier: %v", err)
+		}
+		multiCache.AddBackend(diskCache)
+	}
+	authority := multicache.TierRedis
+	if reconcileAuthority == "memory" {
+		authority = multicache.TierMemory
+	}
+	//same policies for the root cache and every namespace
+	configure := func(mc *multicache.MultiCache, channel string) {
+		mc.ConfigureBreaker(failureThreshold, time.Second, 30*time.Second)
+		if writePolicy == "queue" {
+			mc.SetWritePolicy(multicache.WriteQueue, maxQueuedWrites)
+		}
+		switch reconcileMode {
+		case "report":
+			mc.SetReconcileMode(multicache.ReconcileReport, authority)
+		case "repair":
+			mc.SetReconcileMode(multicache.ReconcileRepair, authority)
+		}
+		if invalidationChannel != "" && redisCache != nil {
+			if err := mc.EnableInvalidation(channel); err != nil {
+				log.Printf("Cross-instance invalidation disabled on %s: %v", channel, err)
+			}
+		}
+	}
+	configure(multiCache, invalidationChannel)
+	//tenants under /ns/:namespace, each with its own capacity
+	if namespaceCapacity <= 0 {
+		namespaceCapacity = maxCacheCapacity
+	}
+	registry := namespace.NewRegistry(namespaceCapacity, redisCache)
+	registry.SetMax(maxNamespaces)
+	registry.OnCreate(func(ns *namespace.Namespace) {
+		if negativeCapacity > 0 {
+			ns.Memory.EnableNegativeCache(negativeCapacity, negativeTTL)
+		}
+		configure(ns.Cache, invalidationChannel+":"+ns.Name)
+		serviceMetrics.ObserveJanitor(ns.Name, ns.Memory)
+	})
+	serviceMetrics.CollectCaches(func() map[string]multicache.Stats {
+		stats := registry.Stats()
+		stats[""] = multiCache.Stats()
+		return stats
+	})
+	//trace and time requests first so rejected ones are measured too, then authenticate both HTTP servers.
+	//the server span is continued from traceparent headers
+	middleware := []gin.HandlerFunc{otelgin.Middleware(tracing.ServiceName), serviceMetrics.Middleware(), logging.Middleware(logger)}
+	newLimiter := func(rule ratelimit.Rule) ratelimit.Limiter {
+		if rateLimitRedis && redisCache != nil {
+			return ratelimit.NewRedis(redisCache.Client, rule)
+		}
+		return ratelimit.NewLocal(rule)
+	}
+	//per client IP before audit and authentication, so floods of bad credentials are throttled too
+	if rateLimitIP != "" {
+		rule, err := ratelimit.ParseRule(rateLimitIP)
+		if err != nil {
+			log.Fatalf("Invalid -rate-limit-ip: %v", err)
+		}
+		middleware = append(middleware, ratelimit.Middleware(map[string]ratelimit.Limiter{"*": newLimiter(rule)}, ratelimit.ClientIP))
+	}
+	var auditLog *os.File
+	var auditor *logging.Auditor //records the gRPC, Redis and memcached protocol mutations, nil without -audit-log
+	if auditFile != "" {
+		auditLog, err = os.OpenFile(auditFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			log.Fatalf("Failed to open -audit-log: %v", err)
+		}
+		auditLogger, _ := logging.New(auditLog, "info", "json")
+		middleware = append(middleware, logging.Audit(auditLogger, api.AuditAction)) //before auth to record rejected attempts
+		auditor = logging.NewAuditor(auditLogger)
+	}
+	var authenticator auth.Authenticator
+	if authFile != "" {
+		authenticator, err = auth.LoadConfig(authFile)
+		if err != nil {
+			log.Fatalf("Failed to load -auth-file: %v", err)
+		}
+		middleware = append(middleware, auth.Middleware(authenticator, api.Access))
+	}
+	//rate limit after authentication so buckets follow the caller rather than the IP
+	if rateLimits != "" {
+		rules, err := ratelimit.ParseRules(rateLimits)
+		if err != nil {
+			log.Fatalf("Invalid -rate-limit: %v", err)
+		}
+		limiters := make(map[string]ratelimit.Limiter)
+		for group, rule := range rules {
+			limiters[group] = newLimiter(rule)
+		}
+		middleware = append(middleware, ratelimit.Middleware(limiters, ratelimit.Identity))
+	}
 	//setup unified api
-	r1 := gin.Default()
+	r1 := gin.New()
+	r1.Use(gin.Recovery())
+	r1.Use(middleware...)
 	api.SetupInMemoryRoutes(r1, inMemoryCache)
-	api.SetupRedisRoutes(r1, redisCache)
-	r := api.SetupUnifiedRoutes(multiCache)
+	if redisCache != nil {
+		api.SetupRedisRoutes(r1, redisCache)
+	}
+	api.SetupProbeRoutes(r1, readiness, multiCache)
+	r := api.SetupUnifiedRoutes(multiCache, middleware...)
+	api.SetupProbeRoutes(r, readiness, multiCache)
+	api.SetupNamespaceRoutes(r, registry)
+	r.GET("/metrics", gin.WrapH(serviceMetrics.Handler()))
+	//client IPs for rate limits and audit records come from X-Forwarded-For only behind these proxies
+	var proxies []string
+	for _, proxy := range strings.Split(trustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	for _, engine := range []*gin.Engine{r, r1} {
+		if err := engine.SetTrustedProxies(proxies); err != nil {
+			log.Fatalf("Invalid -trusted-proxies: %v", err)
+		}
+	}
+
+	//serve until SIGTERM or SIGINT, caught from now on so a signal during warm-up still shuts down gracefully
+	stopped, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
+	//warm-up and restore below run while serving, they must not replace writes made meanwhile
+	inMemoryCache.StartLoading()
 	// Run servers concurrently
-	go func() {
-		if err := r.Run(":8080"); err != nil {
-			log.Fatalf("Failed to run server on :8080: %v", err)
+	servers := []*http.Server{{Addr: ":8080", Handler: r}, {Addr: ":8081", Handler: r1}}
+	for _, server := range servers {
+		go func(server *http.Server) {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("Failed to run server on %s: %v", server.Addr, err)
+			}
+		}(server)
+	}
+
+	var grpcServer *grpc.Server
+	if grpcAddr != "" {
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", grpcAddr, err)
+		}
+		var unary []grpc.UnaryServerInterceptor
+		var stream []grpc.StreamServerInterceptor
+		if auditor != nil {
+			unary = append(unary, grpc_api.UnaryAudit(auditor)) //before auth to record rejected calls
 		}
-	}()
+		if authenticator != nil { //same credentials and rules as the HTTP API
+			unary = append(unary, grpc_api.UnaryAuth(authenticator))
+			stream = append(stream, grpc_api.StreamAuth(authenticator))
+		}
+		options := []grpc.ServerOption{grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...)}
+		grpcServer = grpc_api.NewServer(multiCache, options...)
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatalf("Failed to run gRPC server on %s: %v", grpcAddr, err)
+			}
+		}()
+	}
 
-	go func() {
-		if err := r1.Run(":8081"); err != nil {
-			log.Fatalf("Failed to run server on :8081: %v", err)
+	var respServer *resp_server.Server
+	if respAddr != "" {
+		store := cache_store.MultiCacheStore(multiCache)
+		if respBackend == "memory" {
+			store = cache_store.InMemoryStore(inMemoryCache)
 		}
-	}()
+		respServer = resp_server.NewServer(store, 0) //SET without EX never expires, like redis
+		respServer.SetMaxBulkSize(respMaxBulk)
+		respServer.SetAuditor(auditor)
+		go func() {
+			if err := respServer.ListenAndServe(respAddr); err != nil && !errors.Is(err, resp_server.ErrServerClosed) {
+				log.Fatalf("Failed to run RESP server on %s: %v", respAddr, err)
+			}
+		}()
+	}
 
-	// Block forever
-	select {}
+	var memcacheServer *memcache_server.Server
+	if memcacheAddr != "" {
+		store := cache_store.MultiCacheStore(multiCache)
+		if memcacheBackend == "memory" {
+			store = cache_store.InMemoryStore(inMemoryCache)
+		}
+		memcacheServer = memcache_server.NewServer(store, 0) //exptime 0 never expires, like memcached
+		memcacheServer.SetAuditor(auditor)
+		go func() {
+			if err := memcacheServer.ListenAndServe(memcacheAddr); err != nil && !errors.Is(err, memcache_server.ErrServerClosed) {
+				log.Fatalf("Failed to run memcached server on %s: %v", memcacheAddr, err)
+			}
+		}()
+	}
+
+	//warm up the in-memory cache while /readyz reports starting
+	if warmupKeys <= 0 {
+		warmupKeys = maxCacheCapacity
+	}
+	progress := func(done int, total int) {
+		log.Printf("Warm-up: %d/%d keys", done, total)
+	}
+	var warmed int
+	switch warmupSource {
+	case "redis":
+		warmed, err = warmup.FromRedis(inMemoryCache, redisCache, warmupKeys, progress)
+	case "snapshot":
+		warmed, err = warmup.FromSnapshot(inMemoryCache, snapshotFile, warmupKeys, progress)
+	}
+	if err != nil {
+		log.Printf("Warm-up failed, starting cold: %v", err)
+	} else if warmupSource != "" {
+		log.Printf("Warm-up loaded %d keys from %s", warmed, warmupSource)
+	}
+	if restore {
+		restored, err := inMemoryCache.LoadSnapshot(snapshotFile)
+		if err != nil {
+			log.Printf("Snapshot restore failed, starting empty: %v", err)
+		} else {
+			log.Printf("Restored %d keys from %s", restored, snapshotFile)
+		}
+	}
+	inMemoryCache.FinishLoading()
+	stopSnapshots := func() {}
+	if snapshotInterval > 0 {
+		stopSnapshots = inMemoryCache.StartSnapshots(snapshotFile, snapshotInterval, func(err error) {
+			log.Printf("Snapshot failed: %v", err)
+		})
+	}
+	if stopped.Err() == nil {
+		readiness.MarkStarted()
+		log.Printf("Ready")
+	}
+
+	<-stopped.Done()
+	stop() //a second signal kills the process
+	readiness.MarkStopping()
+	log.Printf("Shutting down in %s, then draining requests for up to %s", shutdownDelay, shutdownTimeout)
+	time.Sleep(shutdownDelay) //load balancers see /readyz fail and stop routing here
+	drain, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	//end event streams, which would otherwise hold the HTTP and gRPC servers until the timeout
+	multiCache.Events().Close()
+	inMemoryCache.Events().Close()
+	registry.CloseEvents()
+	//stop accepting and let in-flight requests finish, connections left at the timeout are closed
+	var wg sync.WaitGroup
+	if respServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := respServer.Shutdown(drain); err != nil {
+				log.Printf("RESP server did not drain: %v", err)
+			}
+		}()
+	}
+	if memcacheServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := memcacheServer.Shutdown(drain); err != nil {
+				log.Printf("Memcached server did not drain: %v", err)
+			}
+		}()
+	}
+	for _, server := range servers {
+		wg.Add(1)
+		go func(server *http.Server) {
+			defer wg.Done()
+			if err := server.Shutdown(drain); err != nil {
+				log.Printf("Server on %s did not drain: %v", server.Addr, err)
+				server.Close()
+			}
+		}(server)
+	}
+	if grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			drained := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(drained)
+			}()
+			select {
+			case <-drained:
+			case <-drain.Done():
+				log.Printf("gRPC server did not drain: %v", drain.Err())
+				grpcServer.Stop()
+			}
+		}()
+	}
+	wg.Wait()
+
+	//flush queued redis writes and a last snapshot, then release the tiers
+	stopSnapshots()
+	if err := multiCache.Close(); err != nil {
+		log.Printf("Closing the cache: %v", err)
+	}
+	if err := registry.Close(); err != nil {
+		log.Printf("Closing namespaces: %v", err)
+	}
+	if snapshotInterval > 0 {
+		if err := inMemoryCache.SaveSnapshot(snapshotFile); err != nil {
+			log.Printf("Final snapshot failed: %v", err)
+		}
+	}
+	if err := inMemoryCache.Close(); err != nil { //stops the janitor, syncs the append-only log
+		log.Printf("Closing the append-only log: %v", err)
+	}
+	if diskCache != nil {
+		if err := diskCache.Close(); err != nil {
+			log.Printf("Closing the disk tier: %v", err)
+		}
+	}
+	if redisCache != nil {
+		redisCache.Client.Close() //namespaces share the client
+	}
+	flush, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flush); err != nil {
+		log.Printf("Flushing spans: %v", err)
+	}
+	if auditLog != nil {
+		auditLog.Close()
+	}
+	log.Printf("Stopped")
 }
@@ -0,0 +1,258 @@
+package memcache_server
+
+import (
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"unified/cache_store"
+)
+
+// Outcome of a storage command
+type result int
+
+const (
+	stored result = iota
+	notStored
+	exists //cas token mismatch
+	notFound
+)
+
+// Storage command semantics
+type storeMode int
+
+const (
+	modeSet storeMode = iota
+	modeAdd
+	modeReplace
+	modeAppend
+	modePrepend
+)
+
+var errNonNumeric = errors.New("cannot increment or decrement non-numeric value")
+
+// Memcached exptime: 0 never expires, up to 30 days is relative, anything larger is a unix time
+const maxRelativeExptime = 60 * 60 * 24 * 30
+
+// ttl for exptime, expired is true when the item should be dropped right away
+func expiration(exptime int64, defaultTTL time.Duration) (ttl time.Duration, expired bool) {
+	switch {
+	case exptime == 0:
+		return defaultTTL, false
+	case exptime < 0:
+		return 0, true
+	case exptime > maxRelativeExptime:
+		ttl = time.Until(time.Unix(exptime, 0))
+		return ttl, ttl <= 0
+	}
+	return time.Duration(exptime) * time.Second, false
+}
+
+// What the protocol keeps per item beside the value
+type itemMeta struct {
+	flags uint32
+	cas   uint64 //changes on every write, 0 means "no token" in the protocol and is never used
+	sum   uint64 //hash of the value cas belongs to
+}
+
+func checksum(data string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(data))
+	return h.Sum64()
+}
+
+// lookup reads an item with its flags and cas token
+func (s *Server) lookup(key string) (string, itemMeta, bool, error) {
+	s.storeMutex.Lock()
+	defer s.storeMutex.Unlock()
+	return s.lookupLocked(key)
+}
+
+// lookupLocked is lookup for callers holding storeMutex, it forgets the meta of vanished keys
+func (s *Server) lookupLocked(key string) (string, itemMeta, bool, error) {
+	value, ok, err := s.store.Get(key)
+	if err != nil {
+		return "", itemMeta{}, false, err
+	}
+	if !ok {
+		s.items.Delete(key) //evicted or expired meanwhile
+		return "", itemMeta{}, false, nil
+	}
+	data := cache_store.EncodeValue(value)
+	cached, _ := s.items.Get(key)
+	meta, known := cached.(itemMeta)
+	if !known || meta.sum != checksum(data) { //written through another API or replica
+		meta = itemMeta{flags: meta.flags, cas: s.nextCas(), sum: checksum(data)}
+		s.items.Set(key, meta, 0)
+	}
+	return data, meta, true, nil
+}
+
+// remember records a write through this server under a new cas token, storeMutex must be held
+func (s *Server) remember(key string, data string, flags uint32, ttl time.Duration) {
+	s.items.Set(key, itemMeta{flags: flags, cas: s.nextCas(), sum: checksum(data)}, ttl)
+}
+
+func (s *Server) nextCas() uint64 {
+	s.lastCas++
+	return s.lastCas
+}
+
+// remaining ttl of key, the default when it has none or never expires
+func (s *Server) remaining(key string) (time.Duration, error) {
+	ttl, ok, err := s.store.TTL(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok || ttl <= 0 {
+		return s.defaultTTL, nil
+	}
+	return ttl, nil
+}
+
+// write stores data according to mode, a non-zero cas must match the current item
+func (s *Server) write(mode storeMode, key string, data string, flags uint32, exptime int64, cas uint64) (result, error) {
+	s.storeMutex.Lock()
+	defer s.storeMutex.Unlock()
+
+	ttl, expired := expiration(exptime, s.defaultTTL)
+	if mode != modeSet || cas != 0 {
+		current, meta, ok, err := s.lookupLocked(key)
+		if err != nil {
+			return notStored, err
+		}
+		if cas != 0 {
+			if !ok {
+				return notFound, nil
+			}
+			if meta.cas != cas {
+				return exists, nil
+			}
+		}
+		switch mode {
+		case modeAdd:
+			if ok {
+				return notStored, nil
+			}
+		case modeReplace, modeAppend, modePrepend:
+			if !ok {
+				return notStored, nil
+			}
+		}
+		if mode == modeAppend || mode == modePrepend { //keeps the item's flags and expiry
+			if mode == modeAppend {
+				data = current + data
+			} else {
+				data = data + current
+			}
+			flags = meta.flags
+			if ttl, err = s.remaining(key); err != nil {
+				return notStored, err
+			}
+			expired = false
+		}
+	}
+
+	if expired { //an exptime in the past stores and expires immediately
+		s.items.Delete(key)
+		_, err := s.store.Delete(key)
+		return stored, err
+	}
+	if err := s.store.Set(key, data, ttl); err != nil {
+		return notStored, err
+	}
+	s.remember(key, data, flags, ttl)
+	return stored, nil
+}
+
+// remove deletes key, a non-zero cas must match the current item
+func (s *Server) remove(key string, cas uint64) (result, error) {
+	s.storeMutex.Lock()
+	defer s.storeMutex.Unlock()
+
+	if cas != 0 {
+		_, meta, ok, err := s.lookupLocked(key)
+		if err != nil {
+			return notFound, err
+		}
+		if !ok {
+			return notFound, nil
+		}
+		if meta.cas != cas {
+			return exists, nil
+		}
+	}
+	s.items.Delete(key)
+	ok, err := s.store.Delete(key)
+	if err != nil || !ok {
+		return notFound, err
+	}
+	return stored, nil
+}
+
+// increment adds or subtracts delta, wrapping at 64 bits on incr and stopping at 0 on decr
+func (s *Server) increment(key string, delta uint64, incr bool) (uint64, result, error) {
+	s.storeMutex.Lock()
+	defer s.storeMutex.Unlock()
+
+	current, meta, ok, err := s.lookupLocked(key)
+	if err != nil {
+		return 0, notStored, err
+	}
+	if !ok {
+		return 0, notFound, nil
+	}
+	n, err := strconv.ParseUint(current, 10, 64)
+	if err != nil {
+		return 0, notStored, errNonNumeric
+	}
+	switch {
+	case incr:
+		n += delta
+	case delta > n:
+		n = 0
+	default:
+		n -= delta
+	}
+	ttl, err := s.remaining(key)
+	if err != nil {
+		return 0, notStored, err
+	}
+	data := strconv.FormatUint(n, 10)
+	if err := s.store.Set(key, data, ttl); err != nil {
+		return 0, notStored, err
+	}
+	s.remember(key, data, meta.flags, ttl)
+	return n, stored, nil
+}
+
+// touch sets a new expiry without changing the value
+func (s *Server) touch(key string, exptime int64) (result, error) {
+	s.storeMutex.Lock()
+	defer s.storeMutex.Unlock()
+
+	current, meta, ok, err := s.lookupLocked(key)
+	if err != nil || !ok {
+		return notFound, err
+	}
+	ttl, expired := expiration(exptime, s.defaultTTL)
+	if expired {
+		s.items.Delete(key)
+		_, err := s.store.Delete(key)
+		return stored, err
+	}
+	if err := s.store.Set(key, current, ttl); err != nil {
+		return notStored, err
+	}
+	s.items.Set(key, meta, ttl) //same value, same cas
+	return stored, nil
+}
+
+func (s *Server) flush() error {
+	s.storeMutex.Lock()
+	defer s.storeMutex.Unlock()
+
+	s.items.DeleteAll()
+	return s.store.DeleteAll()
+}
@@ -0,0 +1,237 @@
+package memcache_server
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"unified/logging"
+)
+
+// Meta command flags: a single letter, optionally followed by a token, e.g. T30 or Oabc
+type metaFlag struct {
+	name  byte
+	token string
+}
+
+func parseMetaFlags(fields []string) ([]metaFlag, bool) {
+	flags := make([]metaFlag, 0, len(fields))
+	for _, field := range fields {
+		if field == "" {
+			continue
+		}
+		c := field[0]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return nil, false
+		}
+		flags = append(flags, metaFlag{name: c, token: field[1:]})
+	}
+	return flags, true
+}
+
+// returnFlags renders the flags echoed back to the client, such as k and O
+func returnFlags(flags []metaFlag, key string, ret func(name byte) (string, bool)) string {
+	var b strings.Builder
+	for _, flag := range flags {
+		switch flag.name {
+		case 'k':
+			b.WriteString(" k" + key)
+		case 'O':
+			b.WriteString(" O" + flag.token)
+		default:
+			if value, ok := ret(flag.name); ok {
+				b.WriteString(" " + string(flag.name) + value)
+			}
+		}
+	}
+	return b.String()
+}
+
+func hasFlag(flags []metaFlag, name byte) bool {
+	for _, flag := range flags {
+		if flag.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mg <key> <flags>*
+func (s *Server) metaGet(sess *session, fields []string) bool {
+	if len(fields) < 2 || !validKey(fields[1]) {
+		clientError(sess, "bad command line format")
+		return true
+	}
+	key := fields[1]
+	flags, ok := parseMetaFlags(fields[2:])
+	if !ok {
+		clientError(sess, "invalid flag")
+		return true
+	}
+	data, meta, found, err := s.lookup(key)
+	if err != nil {
+		serverError(sess, err)
+		return true
+	}
+	if !found {
+		if !hasFlag(flags, 'q') {
+			sess.out.WriteString("EN\r\n")
+		}
+		return true
+	}
+
+	ret := returnFlags(flags, key, func(name byte) (string, bool) {
+		switch name {
+		case 'c':
+			return strconv.FormatUint(meta.cas, 10), true
+		case 'f':
+			return strconv.FormatUint(uint64(meta.flags), 10), true
+		case 's':
+			return strconv.Itoa(len(data)), true
+		case 't':
+			ttl, ok, err := s.store.TTL(key)
+			if err != nil || !ok || ttl < 0 {
+				return "-1", true
+			}
+			return strconv.FormatInt(int64((ttl+time.Second/2)/time.Second), 10), true
+		}
+		return "", false
+	})
+	if hasFlag(flags, 'v') {
+		sess.out.WriteString("VA " + strconv.Itoa(len(data)) + ret + "\r\n" + data + "\r\n")
+		return true
+	}
+	sess.out.WriteString("HD" + ret + "\r\n")
+	return true
+}
+
+// ms <key> <datalen> <flags>* followed by the data block
+func (s *Server) metaSet(sess *session, fields []string) bool {
+	if len(fields) < 3 || !validKey(fields[1]) {
+		clientError(sess, "bad command line format")
+		return false
+	}
+	key := fields[1]
+	size, err := strconv.Atoi(fields[2])
+	if err != nil || size < 0 {
+		clientError(sess, "bad data chunk")
+		return false
+	}
+	if size > maxItemSize {
+		clientError(sess, "object too large for cache")
+		return false
+	}
+	flags, ok := parseMetaFlags(fields[3:])
+	if !ok {
+		clientError(sess, "invalid flag")
+		return false
+	}
+	data, ok := readData(sess, size)
+	if !ok {
+		return false
+	}
+
+	mode := modeSet
+	var clientFlags uint64
+	var exptime int64
+	var cas uint64
+	for _, flag := range flags {
+		var err error
+		switch flag.name {
+		case 'T':
+			exptime, err = strconv.ParseInt(flag.token, 10, 64)
+		case 'F':
+			clientFlags, err = strconv.ParseUint(flag.token, 10, 32)
+		case 'C':
+			cas, err = strconv.ParseUint(flag.token, 10, 64)
+		case 'M':
+			switch strings.ToUpper(flag.token) {
+			case "S":
+				mode = modeSet
+			case "E":
+				mode = modeAdd
+			case "R":
+				mode = modeReplace
+			case "A":
+				mode = modeAppend
+			case "P":
+				mode = modePrepend
+			default:
+				clientError(sess, "invalid mode for ms")
+				return true
+			}
+		}
+		if err != nil {
+			clientError(sess, "bad token in command line format")
+			return true
+		}
+	}
+
+	res, err := s.write(mode, key, data, uint32(clientFlags), exptime, cas)
+	s.audit(sess, fields[0], logging.Action{Name: "set", Key: key}, err)
+	if err != nil {
+		serverError(sess, err)
+		return true
+	}
+	if res == stored && hasFlag(flags, 'q') {
+		return true
+	}
+	ret := returnFlags(flags, key, func(name byte) (string, bool) {
+		if name == 'c' && res == stored {
+			_, meta, ok, err := s.lookup(key)
+			if err == nil && ok {
+				return strconv.FormatUint(meta.cas, 10), true
+			}
+		}
+		return "", false
+	})
+	sess.out.WriteString(metaStatus(res) + ret + "\r\n")
+	return true
+}
+
+// md <key> <flags>*
+func (s *Server) metaDelete(sess *session, fields []string) bool {
+	if len(fields) < 2 || !validKey(fields[1]) {
+		clientError(sess, "bad command line format")
+		return true
+	}
+	key := fields[1]
+	flags, ok := parseMetaFlags(fields[2:])
+	if !ok {
+		clientError(sess, "invalid flag")
+		return true
+	}
+	var cas uint64
+	for _, flag := range flags {
+		if flag.name == 'C' {
+			var ok bool
+			if cas, ok = parseUint(flag.token); !ok {
+				clientError(sess, "bad token in command line format")
+				return true
+			}
+		}
+	}
+	res, err := s.remove(key, cas)
+	s.audit(sess, fields[0], logging.Action{Name: "delete", Key: key}, err)
+	if err != nil {
+		serverError(sess, err)
+		return true
+	}
+	if res == stored && hasFlag(flags, 'q') {
+		return true
+	}
+	sess.out.WriteString(metaStatus(res) + returnFlags(flags, key, func(byte) (string, bool) { return "", false }) + "\r\n")
+	return true
+}
+
+func metaStatus(res result) string {
+	switch res {
+	case stored:
+		return "HD"
+	case exists:
+		return "EX"
+	case notFound:
+		return "NF"
+	}
+	return "NS"
+}
@@ -0,0 +1,321 @@
+package memcache_server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"unified/cache_store"
+	"unified/in_memory"
+	"unified/logging"
+)
+
+var ErrServerClosed = errors.New("memcache_server: server closed")
+
+const (
+	maxKeyLength = 250
+	maxLineSize  = 64 * 1024
+	maxItemSize  = 1024 * 1024 //memcached default item size limit
+	maxItemMeta  = 64 * 1024   //items whose flags and cas token are kept, least recently used first forgotten
+)
+
+// Server speaks the memcached text and meta protocols on top of a cache_store.Store
+type Server struct {
+	store      cache_store.Store
+	defaultTTL time.Duration       //used for exptime 0, 0 never expires
+	items      *in_memory.LRUCache //itemMeta per key, expiring with the item. A forgotten item reads flags 0 and a new cas token
+	lastCas    uint64
+	storeMutex sync.Mutex //serializes read-modify-write commands such as cas and incr
+	auditor    *logging.Auditor
+	listener   net.Listener
+	conns      map[net.Conn]bool //true while running a command
+	closed     bool
+	mutex      sync.Mutex
+}
+
+func NewServer(store cache_store.Store, defaultTTL time.Duration) *Server {
+	return &Server{
+		store:      store,
+		defaultTTL: defaultTTL,
+		items:      in_memory.NewLRUCache(maxItemMeta, 60),
+		conns:      make(map[net.Conn]bool),
+	}
+}
+
+// SetAuditor records every storage, delete, incr, touch and flush command with auditor. Configure before serving
+func (s *Server) SetAuditor(auditor *logging.Auditor) {
+	s.auditor = auditor
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections until Close is called
+func (s *Server) Serve(listener net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mutex.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			closed := s.closed
+			s.mutex.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// How often Shutdown checks for connections that went idle
+const shutdownPoll = 50 * time.Millisecond
+
+// Shutdown stops accepting, closes idle connections and lets busy ones send the replies of the commands
+// they read before closing them. When ctx is done first the remaining connections are dropped as by Close
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.mutex.Unlock()
+
+	ticker := time.NewTicker(shutdownPoll)
+	defer ticker.Stop()
+	for !s.closeIdle() {
+		select {
+		case <-ctx.Done():
+			s.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	s.items.Close() //stops the janitor
+	return err
+}
+
+// closeIdle closes the connections waiting for a command, true once none are left
+func (s *Server) closeIdle() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for conn, busy := range s.conns {
+		if !busy {
+			conn.Close()
+			delete(s.conns, conn)
+		}
+	}
+	return len(s.conns) == 0
+}
+
+// Close stops the listener and drops open connections
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.items.Close() //stops the janitor
+	return err
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = false
+	return true
+}
+
+// setBusy marks conn as running a command, or idle once its replies are flushed. False when the
+// connection must end: Shutdown closed it while idle, or shutdown began and its replies are out
+func (s *Server) setBusy(conn net.Conn, busy bool) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, open := s.conns[conn]; !open || (!busy && s.closed) {
+		return false
+	}
+	s.conns[conn] = busy
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.conns, conn)
+}
+
+// Per-connection state
+type session struct {
+	client net.Addr
+	in     *bufio.Reader
+	out    *bufio.Writer
+	quit   bool
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.untrack(conn)
+	defer conn.Close()
+
+	sess := &session{
+		client: conn.RemoteAddr(),
+		in:     bufio.NewReaderSize(conn, maxLineSize),
+		out:    bufio.NewWriter(conn),
+	}
+	for !sess.quit {
+		line, err := sess.in.ReadSlice('\n')
+		if err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				sess.out.WriteString("CLIENT_ERROR line too long\r\n")
+				sess.out.Flush()
+			}
+			return
+		}
+		fields := splitFields(line)
+		if !s.setBusy(conn, true) {
+			return
+		}
+		if len(fields) > 0 && !s.dispatch(sess, fields) {
+			sess.out.Flush()
+			return
+		}
+		if sess.in.Buffered() == 0 { //flush once per pipelined batch
+			if err := sess.out.Flush(); err != nil {
+				return
+			}
+			if !s.setBusy(conn, false) {
+				return
+			}
+		}
+	}
+	sess.out.Flush()
+}
+
+// splitFields copies the space separated words of a command line, the buffer is reused by the reader
+func splitFields(line []byte) []string {
+	var fields []string
+	start := -1
+	for i, b := range line {
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+			if start >= 0 {
+				fields = append(fields, string(line[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, string(line[start:]))
+	}
+	return fields
+}
+
+// dispatch runs one command, false drops the connection after a protocol error
+func (s *Server) dispatch(sess *session, fields []string) bool {
+	switch fields[0] {
+	case "get", "gets":
+		return s.cmdGet(sess, fields)
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return s.cmdStorage(sess, fields)
+	case "delete":
+		return s.cmdDelete(sess, fields)
+	case "incr", "decr":
+		return s.cmdArith(sess, fields)
+	case "touch":
+		return s.cmdTouch(sess, fields)
+	case "flush_all":
+		return s.cmdFlushAll(sess, fields)
+	case "mg":
+		return s.metaGet(sess, fields)
+	case "ms":
+		return s.metaSet(sess, fields)
+	case "md":
+		return s.metaDelete(sess, fields)
+	case "mn":
+		sess.out.WriteString("MN\r\n")
+	case "version":
+		sess.out.WriteString("VERSION 1.6.0-unified\r\n")
+	case "verbosity":
+		if !noreply(fields) {
+			sess.out.WriteString("OK\r\n")
+		}
+	case "quit":
+		sess.quit = true
+	default:
+		sess.out.WriteString("ERROR\r\n")
+	}
+	return true
+}
+
+// audit records the outcome of a command that reached the store
+func (s *Server) audit(sess *session, command string, action logging.Action, err error) {
+	s.auditor.Record(context.Background(), logging.Call{Protocol: "memcache", Command: command, Client: sess.client, Action: action}, err)
+}
+
+func noreply(fields []string) bool {
+	return len(fields) > 0 && fields[len(fields)-1] == "noreply"
+}
+
+func validKey(key string) bool {
+	return len(key) > 0 && len(key) <= maxKeyLength
+}
+
+// readData reads a data block of size bytes and its trailing CRLF
+func readData(sess *session, size int) (string, bool) {
+	buf := make([]byte, size+2)
+	if _, err := io.ReadFull(sess.in, buf); err != nil {
+		return "", false
+	}
+	if buf[size] != '\r' || buf[size+1] != '\n' {
+		sess.out.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return "", false
+	}
+	return string(buf[:size]), true
+}
+
+func clientError(sess *session, msg string) {
+	sess.out.WriteString("CLIENT_ERROR " + msg + "\r\n")
+}
+
+func serverError(sess *session, err error) {
+	sess.out.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+}
+
+func parseUint(s string) (uint64, bool) {
+	n, err := strconv.ParseUint(s, 10, 64)
+	return n, err == nil
+}
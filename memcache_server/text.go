@@ -0,0 +1,206 @@
+package memcache_server
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"unified/logging"
+)
+
+// get|gets <key>*
+func (s *Server) cmdGet(sess *session, fields []string) bool {
+	if len(fields) < 2 {
+		sess.out.WriteString("ERROR\r\n")
+		return true
+	}
+	withCas := fields[0] == "gets"
+	for _, key := range fields[1:] {
+		if !validKey(key) {
+			clientError(sess, "bad command line format")
+			return true
+		}
+	}
+	for _, key := range fields[1:] {
+		data, meta, ok, err := s.lookup(key)
+		if err != nil {
+			serverError(sess, err)
+			return true
+		}
+		if !ok {
+			continue
+		}
+		sess.out.WriteString("VALUE " + key + " " + strconv.FormatUint(uint64(meta.flags), 10) + " " + strconv.Itoa(len(data)))
+		if withCas {
+			sess.out.WriteString(" " + strconv.FormatUint(meta.cas, 10))
+		}
+		sess.out.WriteString("\r\n" + data + "\r\n")
+	}
+	sess.out.WriteString("END\r\n")
+	return true
+}
+
+// <command> <key> <flags> <exptime> <bytes> [<cas unique>] [noreply] followed by the data block
+func (s *Server) cmdStorage(sess *session, fields []string) bool {
+	argc := 5
+	if fields[0] == "cas" {
+		argc = 6
+	}
+	if len(fields) < argc || len(fields) > argc+1 {
+		sess.out.WriteString("ERROR\r\n")
+		return true
+	}
+	flags, err1 := strconv.ParseUint(fields[2], 10, 32)
+	exptime, err2 := strconv.ParseInt(fields[3], 10, 64)
+	size, err3 := strconv.Atoi(fields[4])
+	if err1 != nil || err2 != nil || err3 != nil || size < 0 || !validKey(fields[1]) {
+		clientError(sess, "bad command line format")
+		return false //the data block cannot be skipped reliably
+	}
+	if size > maxItemSize {
+		clientError(sess, "object too large for cache")
+		return false
+	}
+	var cas uint64
+	if fields[0] == "cas" {
+		var ok bool
+		if cas, ok = parseUint(fields[5]); !ok {
+			clientError(sess, "bad command line format")
+			return false
+		}
+	}
+	data, ok := readData(sess, size)
+	if !ok {
+		return false
+	}
+
+	mode := map[string]storeMode{
+		"set": modeSet, "cas": modeSet, "add": modeAdd, "replace": modeReplace, "append": modeAppend, "prepend": modePrepend,
+	}[fields[0]]
+	res, err := s.write(mode, fields[1], data, uint32(flags), exptime, cas)
+	s.audit(sess, fields[0], logging.Action{Name: "set", Key: fields[1]}, err)
+	if noreply(fields) {
+		return true
+	}
+	switch {
+	case err != nil:
+		serverError(sess, err)
+	case res == stored:
+		sess.out.WriteString("STORED\r\n")
+	case res == exists:
+		sess.out.WriteString("EXISTS\r\n")
+	case res == notFound:
+		sess.out.WriteString("NOT_FOUND\r\n")
+	default:
+		sess.out.WriteString("NOT_STORED\r\n")
+	}
+	return true
+}
+
+// delete <key> [noreply]
+func (s *Server) cmdDelete(sess *session, fields []string) bool {
+	if len(fields) < 2 || len(fields) > 3 || (len(fields) == 3 && !noreply(fields)) {
+		sess.out.WriteString("ERROR\r\n")
+		return true
+	}
+	res, err := s.remove(fields[1], 0)
+	s.audit(sess, fields[0], logging.Action{Name: "delete", Key: fields[1]}, err)
+	if noreply(fields) {
+		return true
+	}
+	switch {
+	case err != nil:
+		serverError(sess, err)
+	case res == stored:
+		sess.out.WriteString("DELETED\r\n")
+	default:
+		sess.out.WriteString("NOT_FOUND\r\n")
+	}
+	return true
+}
+
+// incr|decr <key> <value> [noreply]
+func (s *Server) cmdArith(sess *session, fields []string) bool {
+	if len(fields) < 3 || len(fields) > 4 {
+		sess.out.WriteString("ERROR\r\n")
+		return true
+	}
+	delta, ok := parseUint(fields[2])
+	if !ok {
+		clientError(sess, "invalid numeric delta argument")
+		return true
+	}
+	n, res, err := s.increment(fields[1], delta, fields[0] == "incr")
+	s.audit(sess, fields[0], logging.Action{Name: "set", Key: fields[1]}, err)
+	if noreply(fields) {
+		return true
+	}
+	switch {
+	case errors.Is(err, errNonNumeric):
+		clientError(sess, err.Error())
+	case err != nil:
+		serverError(sess, err)
+	case res == notFound:
+		sess.out.WriteString("NOT_FOUND\r\n")
+	default:
+		sess.out.WriteString(strconv.FormatUint(n, 10) + "\r\n")
+	}
+	return true
+}
+
+// touch <key> <exptime> [noreply]
+func (s *Server) cmdTouch(sess *session, fields []string) bool {
+	if len(fields) < 3 || len(fields) > 4 {
+		sess.out.WriteString("ERROR\r\n")
+		return true
+	}
+	exptime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		clientError(sess, "invalid exptime argument")
+		return true
+	}
+	res, err := s.touch(fields[1], exptime)
+	s.audit(sess, fields[0], logging.Action{Name: "expire", Key: fields[1]}, err)
+	if noreply(fields) {
+		return true
+	}
+	switch {
+	case err != nil:
+		serverError(sess, err)
+	case res == stored:
+		sess.out.WriteString("TOUCHED\r\n")
+	default:
+		sess.out.WriteString("NOT_FOUND\r\n")
+	}
+	return true
+}
+
+// flush_all [delay] [noreply]
+func (s *Server) cmdFlushAll(sess *session, fields []string) bool {
+	var delay int64
+	if len(fields) >= 2 && fields[1] != "noreply" {
+		var err error
+		if delay, err = strconv.ParseInt(fields[1], 10, 64); err != nil || delay < 0 {
+			clientError(sess, "bad command line format")
+			return true
+		}
+	}
+	var err error
+	if delay > 0 {
+		time.AfterFunc(time.Duration(delay)*time.Second, func() {
+			s.audit(sess, fields[0], logging.Action{Name: "flush"}, s.flush())
+		})
+	} else {
+		err = s.flush()
+		s.audit(sess, fields[0], logging.Action{Name: "flush"}, err)
+	}
+	if noreply(fields) {
+		return true
+	}
+	if err != nil {
+		serverError(sess, err)
+		return true
+	}
+	sess.out.WriteString("OK\r\n")
+	return true
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"sort"
+
+	"unified/circuit_breaker"
+	"unified/multicache"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cacheHits      = prometheus.NewDesc("cache_hits_total", "Lookups answered by a tier.", []string{"namespace", "tier"}, nil)
+	cacheMisses    = prometheus.NewDesc("cache_misses_total", "Lookups a tier could not answer.", []string{"namespace", "tier"}, nil)
+	cacheEvictions = prometheus.NewDesc("cache_evictions_total", "Keys evicted for capacity.", []string{"namespace", "tier"}, nil)
+	cacheExpired   = prometheus.NewDesc("cache_expirations_total", "Keys removed from memory after their ttl.", []string{"namespace"}, nil)
+	cacheItems     = prometheus.NewDesc("cache_items", "Keys held by a tier.", []string{"namespace", "tier"}, nil)
+	cacheCapacity  = prometheus.NewDesc("cache_capacity", "Capacity of the in-memory tier.", []string{"namespace"}, nil)
+	queuedWrites   = prometheus.NewDesc("cache_redis_queued_writes", "Redis writes queued while Redis is unavailable.", []string{"namespace"}, nil)
+	droppedWrites  = prometheus.NewDesc("cache_redis_dropped_writes_total", "Redis writes dropped while Redis was unavailable.", []string{"namespace"}, nil)
+	redisAvailable = prometheus.NewDesc("cache_redis_available", "1 while the Redis circuit breaker is closed.", []string{"namespace"}, nil)
+)
+
+// cacheCollector turns multicache.Stats into metrics when scraped
+type cacheCollector struct {
+	stats func() map[string]multicache.Stats
+	label func(namespace string) string
+}
+
+// sampleKey identifies a series, namespaces past maxNamespaceLabels share theirs
+type sampleKey struct {
+	desc      *prometheus.Desc
+	namespace string
+	tier      string //empty for series without a tier label
+}
+
+func (k sampleKey) labels() []string {
+	if k.tier == "" {
+		return []string{k.namespace}
+	}
+	return []string{k.namespace, k.tier}
+}
+
+func (cc *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{cacheHits, cacheMisses, cacheEvictions, cacheExpired, cacheItems, cacheCapacity, queuedWrites, droppedWrites, redisAvailable} {
+		ch <- desc
+	}
+}
+
+// Collect sums the stats of namespaces sharing a label, redis counts as available only if it is for all of them
+func (cc *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	all := cc.stats()
+	names := make([]string, 0, len(all))
+	for ns := range all {
+		names = append(names, ns)
+	}
+	//labels go to namespaces in the order this instance first saw them, mostly at creation through ObserveJanitor,
+	//so past maxNamespaceLabels two instances may label different namespaces. Sorting only orders the ones first seen here
+	sort.Strings(names)
+
+	counters := make(map[sampleKey]float64)
+	gauges := make(map[sampleKey]float64)
+	available := make(map[string]float64)
+	for _, name := range names {
+		stats, ns := all[name], cc.label(name)
+		memory := stats.Memory
+		counters[sampleKey{cacheHits, ns, "memory"}] += float64(memory.Hits)
+		counters[sampleKey{cacheMisses, ns, "memory"}] += float64(memory.Misses)
+		counters[sampleKey{cacheEvictions, ns, "memory"}] += float64(memory.Evictions)
+		counters[sampleKey{cacheExpired, ns, ""}] += float64(memory.Expirations)
+		gauges[sampleKey{cacheItems, ns, "memory"}] += float64(memory.Items)
+		gauges[sampleKey{cacheCapacity, ns, ""}] += float64(memory.Capacity)
+		for _, tier := range stats.Tiers {
+			counters[sampleKey{cacheHits, ns, tier.Tier}] += float64(tier.Hits)
+			counters[sampleKey{cacheMisses, ns, tier.Tier}] += float64(tier.Misses)
+			counters[sampleKey{cacheEvictions, ns, tier.Tier}] += float64(tier.Evictions)
+			if tier.Items >= 0 {
+				gauges[sampleKey{cacheItems, ns, tier.Tier}] += float64(tier.Items)
+			}
+		}
+		if stats.Redis.Redis == "disabled" {
+			continue
+		}
+		up := 0.0
+		if stats.Redis.Redis == circuit_breaker.StateClosed.String() {
+			up = 1
+		}
+		if current, ok := available[ns]; !ok || up < current {
+			available[ns] = up
+		}
+		gauges[sampleKey{queuedWrites, ns, ""}] += float64(stats.Redis.QueuedWrites)
+		counters[sampleKey{droppedWrites, ns, ""}] += float64(stats.Redis.DroppedWrites)
+	}
+	for key, value := range counters {
+		ch <- prometheus.MustNewConstMetric(key.desc, prometheus.CounterValue, value, key.labels()...)
+	}
+	for key, value := range gauges {
+		ch <- prometheus.MustNewConstMetric(key.desc, prometheus.GaugeValue, value, key.labels()...)
+	}
+	for ns, value := range available {
+		ch <- prometheus.MustNewConstMetric(redisAvailable, prometheus.GaugeValue, value, ns)
+	}
+}
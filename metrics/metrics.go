@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"unified/in_memory"
+	"unified/multicache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespaces labelled by name, the ones seen after them share otherNamespaces so the series stay bounded.
+// Namespace names cannot contain parentheses
+const (
+	maxNamespaceLabels = 50
+	otherNamespaces    = "(other)"
+)
+
+// Metrics is a prometheus registry of the service, exposed by Handler
+type Metrics struct {
+	registry      *prometheus.Registry
+	requests      *prometheus.HistogramVec
+	redisDuration *prometheus.HistogramVec
+	redisErrors   *prometheus.CounterVec
+	janitor       *prometheus.HistogramVec
+	labels        map[string]string //namespace label of each namespace seen
+	named         int               //namespaces labelled by name
+	labelsMutex   sync.Mutex
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route, method and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		redisDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "redis_command_duration_seconds",
+			Help:    "Redis round-trip latency by command, pipelines as pipeline.",
+			Buckets: []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"command"}),
+		redisErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "redis_errors_total",
+			Help: "Failed Redis commands, missing keys excluded.",
+		}, []string{"command"}),
+		janitor: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cache_janitor_duration_seconds",
+			Help:    "Duration of the in-memory cache expiry sweeps.",
+			Buckets: []float64{.00001, .0001, .001, .01, .1, 1},
+		}, []string{"namespace"}),
+		labels: make(map[string]string),
+	}
+	m.registry.MustRegister(m.requests, m.redisDuration, m.redisErrors, m.janitor,
+		collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return m
+}
+
+// Handler serves the metrics in the prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware times requests by route pattern, unrouted requests are recorded as unmatched
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requests.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveJanitor records the expiry sweeps of cache, namespace is empty for the root cache
+func (m *Metrics) ObserveJanitor(namespace string, cache *in_memory.LRUCache) {
+	observer := m.janitor.WithLabelValues(m.namespaceLabel(namespace))
+	cache.OnJanitorRun(func(elapsed time.Duration) {
+		observer.Observe(elapsed.Seconds())
+	})
+}
+
+// CollectCaches reports the stats of every cache at scrape time, keyed by namespace with the root cache under ""
+func (m *Metrics) CollectCaches(stats func() map[string]multicache.Stats) {
+	m.registry.MustRegister(&cacheCollector{stats: stats, label: m.namespaceLabel})
+}
+
+// namespaceLabel is the name of the root cache and the first maxNamespaceLabels namespaces seen, otherNamespaces after
+func (m *Metrics) namespaceLabel(namespace string) string {
+	m.labelsMutex.Lock()
+	defer m.labelsMutex.Unlock()
+	if label, ok := m.labels[namespace]; ok {
+		return label
+	}
+	label := namespace
+	if namespace != "" {
+		if m.named < maxNamespaceLabels {
+			m.named++
+		} else {
+			label = otherNamespaces
+		}
+	}
+	m.labels[namespace] = label
+	return label
+}
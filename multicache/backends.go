@@ -0,0 +1,61 @@
+package multicache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"unified/in_memory"
+)
+
+var ErrNoRedis = errors.New("redis tier not configured")
+
+// Backend is an extra tier behind the in-memory cache, such as disk_cache.DiskCache.
+// It is L2 when MultiCache has no redis tier and L3 behind redis otherwise
+type Backend interface {
+	Set(key string, value interface{}, expiration time.Duration) error //a failed write fails the MultiCache write
+	Get(key string) (interface{}, bool)
+	GetAll() map[string]interface{}
+	Delete(key string) bool
+	DeleteAll() bool
+	TTL(key string) (time.Duration, bool)
+}
+
+// Add a tier below the existing ones, configure before serving traffic
+func (mc *MultiCache) AddBackend(backend Backend) {
+	mc.backends = append(mc.backends, backend)
+	mc.backendLookups = append(mc.backendLookups, &tierCounter{})
+}
+
+// fromBackends looks the key up tier by tier and promotes a hit into memory
+func (mc *MultiCache) fromBackends(ctx context.Context, key string) (interface{}, bool) {
+	for i, backend := range mc.backends {
+		_, span := tierSpan(ctx, mc.backendTier(i), "get")
+		value, ok := backend.Get(key)
+		span.End()
+		mc.backendLookups[i].count(ok)
+		if !ok {
+			continue
+		}
+		if ttl, ok := backend.TTL(key); ok && ttl == in_memory.NoExpiry {
+			mc.inMemoryCache.Set(key, value, 0)
+		} else if ok && ttl > 0 {
+			mc.inMemoryCache.Set(key, value, ttl)
+		}
+		return value, true
+	}
+	return nil, false
+}
+
+// allFromBackends merges every backend, higher tiers win
+func (mc *MultiCache) allFromBackends(ctx context.Context) map[string]interface{} {
+	values := make(map[string]interface{})
+	for i := len(mc.backends) - 1; i >= 0; i-- {
+		_, span := tierSpan(ctx, mc.backendTier(i), "getall")
+		for key, value := range mc.backends[i].GetAll() {
+			values[key] = value
+		}
+		span.End()
+	}
+	return values
+}
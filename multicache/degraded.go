@@ -0,0 +1,259 @@
+package multicache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"unified/circuit_breaker"
+	"unified/redis_cache"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrRedisUnavailable = errors.New("redis unavailable")
+
+// What happens to Redis writes while the breaker is open
+type WritePolicy int
+
+const (
+	WriteDrop  WritePolicy = iota //discard, Redis catches up through later writes
+	WriteQueue                    //buffer and replay once Redis recovers
+)
+
+type pendingWrite struct {
+	op        string
+	key       string
+	value     interface{}
+	expiresAt time.Time //zero never expires
+	tags      []string
+}
+
+type Health struct {
+	Status        string `json:"status"` //ok or degraded
+	Redis         string `json:"redis"`  //breaker state
+	QueuedWrites  int    `json:"queued_writes"`
+	DroppedWrites int    `json:"dropped_writes"`
+}
+
+func (mc *MultiCache) ConfigureBreaker(threshold int, backoff time.Duration, maxBackoff time.Duration) {
+	breaker := circuit_breaker.NewCircuitBreaker(threshold, backoff, maxBackoff)
+	breaker.OnRecovered(mc.startReplay)
+	mc.breaker = breaker
+}
+
+func (mc *MultiCache) SetWritePolicy(policy WritePolicy, maxQueued int) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.writePolicy = policy
+	mc.maxQueued = maxQueued
+}
+
+func (mc *MultiCache) Health() Health {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if mc.redisCache == nil {
+		return Health{Status: "ok", Redis: "disabled"}
+	}
+	state := mc.breaker.State()
+	status := "ok"
+	if state != circuit_breaker.StateClosed || len(mc.pending) > 0 || mc.replayDone != nil { //redis is missing queued writes
+		status = "degraded"
+	}
+	return Health{
+		Status:        status,
+		Redis:         state.String(),
+		QueuedWrites:  len(mc.pending),
+		DroppedWrites: mc.dropped,
+	}
+}
+
+// record feeds a Redis result into the breaker, true if Redis itself failed
+func (mc *MultiCache) record(err error) bool {
+	switch {
+	case err == nil || errors.Is(err, redis.Nil): //missing key is a healthy reply
+		mc.breaker.Success()
+		return false
+	case errors.Is(err, redis_cache.ErrEmptyKey) || errors.Is(err, redis_cache.ErrNegativeTTL) || errors.Is(err, redis_cache.ErrInvalidCursor):
+		mc.breaker.Cancel() //rejected before reaching Redis
+		return false
+	}
+	mc.breaker.Failure()
+	return true
+}
+
+// allowWrite reports whether w may go straight to redis. While earlier writes are queued or replaying
+// it joins the queue instead, so redis applies writes in the order they were made
+func (mc *MultiCache) allowWrite(w pendingWrite) bool {
+	mc.mutex.Lock()
+	behind := mc.replayDone != nil || len(mc.pending) > 0
+	if behind {
+		mc.enqueue(w)
+	}
+	mc.mutex.Unlock()
+	if behind {
+		mc.startReplay()
+		return false
+	}
+	if !mc.breaker.Allow() {
+		mc.deferWrite(w)
+		return false
+	}
+	return true
+}
+
+// writesQueued reports whether redis is missing writes that memory already has
+func (mc *MultiCache) writesQueued() bool {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	return mc.replayDone != nil || len(mc.pending) > 0
+}
+
+func (mc *MultiCache) deferWrite(w pendingWrite) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if mc.writePolicy == WriteDrop {
+		mc.dropped++
+		return
+	}
+	mc.enqueue(w)
+}
+
+// enqueue appends w to the pending writes, mc.mutex must be held
+func (mc *MultiCache) enqueue(w pendingWrite) {
+	if w.op == opFlush { //flush supersedes everything queued before it
+		mc.pending = mc.pending[:0]
+	}
+	if mc.maxQueued > 0 && len(mc.pending) >= mc.maxQueued {
+		mc.pending = mc.pending[1:] //drop oldest
+		mc.dropped++
+	}
+	mc.pending = append(mc.pending, w)
+}
+
+// Close flushes writes queued while redis was unavailable and stops listening for invalidations.
+// Writes redis still refuses are lost and reported
+func (mc *MultiCache) Close() error {
+	var err error
+	if mc.redisCache != nil {
+		for { //wait for a running replay, then retry what it left once more
+			done, owned := mc.claimReplay(true)
+			if done == nil {
+				break
+			}
+			if owned {
+				mc.replayWrites(done)
+				break
+			}
+			<-done
+		}
+		mc.mutex.Lock()
+		if lost := len(mc.pending); lost > 0 {
+			err = fmt.Errorf("%d queued redis writes lost: %w", lost, ErrRedisUnavailable)
+		}
+		mc.mutex.Unlock()
+	}
+	return errors.Join(err, mc.DisableInvalidation())
+}
+
+// startReplay drains queued writes in the background once the breaker lets a call through,
+// the first replayed write is the breaker's probe
+func (mc *MultiCache) startReplay() {
+	if done, owned := mc.claimReplay(false); owned {
+		go mc.replayWrites(done)
+	}
+}
+
+// claimReplay returns the channel closed when the running replay finishes, owned if the caller has to run it.
+// Nil when nothing is queued or the breaker is open, force ignores the breaker
+func (mc *MultiCache) claimReplay(force bool) (chan struct{}, bool) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if mc.replayDone != nil {
+		return mc.replayDone, false
+	}
+	if len(mc.pending) == 0 || (!force && !mc.breaker.Allow()) {
+		return nil, false
+	}
+	mc.replayDone = make(chan struct{})
+	return mc.replayDone, true
+}
+
+// replayWrites applies queued writes oldest first, including writes queued behind them meanwhile,
+// until the queue is empty or redis fails again
+func (mc *MultiCache) replayWrites(done chan struct{}) {
+	probed := false
+	defer func() {
+		if !probed { //every write expired, release the probe the claim took
+			mc.breaker.Cancel()
+		}
+		mc.mutex.Lock()
+		mc.replayDone = nil
+		mc.mutex.Unlock()
+		close(done)
+	}()
+
+	for {
+		mc.mutex.Lock()
+		if len(mc.pending) == 0 {
+			mc.mutex.Unlock()
+			return
+		}
+		w := mc.pending[0]
+		mc.pending = mc.pending[1:]
+		mc.mutex.Unlock()
+
+		var err error
+		var keys []string
+		switch w.op {
+		case opSet:
+			remaining, ok := untilExpiry(w.expiresAt)
+			if !ok { //expired while Redis was down
+				continue
+			}
+			err = mc.redisCache.SetWithTags(w.key, w.value, remaining, w.tags...)
+		case opExpire:
+			if w.expiresAt.IsZero() {
+				_, err = mc.redisCache.Persist(w.key)
+			} else {
+				_, err = mc.redisCache.Expire(w.key, time.Until(w.expiresAt)) //deletes if expired meanwhile
+			}
+		case opDelete:
+			err = mc.redisCache.Delete(w.key)
+		case opFlush:
+			err = mc.redisCache.DeleteAll()
+		case opInvalidateTag:
+			keys, err = mc.redisCache.InvalidateTag(w.key)
+		}
+		probed = true
+		if mc.record(err) {
+			mc.mutex.Lock()
+			mc.pending = append([]pendingWrite{w}, mc.pending...) //retried first on the next recovery
+			mc.mutex.Unlock()
+			return
+		}
+		if err == nil {
+			switch w.op {
+			case opFlush:
+				mc.publishInvalidation(opFlush, "")
+			case opInvalidateTag: //keys set on other replicas or before a restart
+				mc.dropTagged(keys)
+				mc.publishDeletes(keys)
+			default:
+				mc.publishInvalidation(opDelete, w.key)
+			}
+		}
+	}
+}
+
+// untilExpiry is the ttl left before expiresAt, 0 if it never expires and false once expired
+func untilExpiry(expiresAt time.Time) (time.Duration, bool) {
+	if expiresAt.IsZero() {
+		return 0, true
+	}
+	remaining := time.Until(expiresAt)
+	return remaining, remaining > 0
+}
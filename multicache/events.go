@@ -0,0 +1,8 @@
+package multicache
+
+import "unified/events"
+
+// Events carries set, delete and flush events of the unified cache, plus in-memory evictions and expirations
+func (mc *MultiCache) Events() *events.Bus {
+	return mc.events
+}
@@ -0,0 +1,101 @@
+package multicache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+
+	"unified/events"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache operations, used in invalidation messages and queued writes
+const (
+	opSet           = "set"
+	opDelete        = "delete"
+	opFlush         = "flush"
+	opExpire        = "expire"         //queued only, ttl changes invalidate as deletes
+	opInvalidateTag = "invalidate_tag" //queued only, key holds the tag
+)
+
+type invalidationMessage struct {
+	Origin string `json:"origin"` //instance that performed the write
+	Op     string `json:"op"`
+	Key    string `json:"key,omitempty"`
+}
+
+// Subscribe to the channel and evict keys written by other instances from in-memory cache
+func (mc *MultiCache) EnableInvalidation(channel string) error {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if mc.redisCache == nil {
+		return ErrNoRedis
+	}
+	if mc.pubsub != nil {
+		return nil
+	}
+	pubsub, err := mc.redisCache.Subscribe(channel)
+	if err != nil {
+		return err
+	}
+	mc.channel = channel
+	mc.pubsub = pubsub
+	go mc.listenInvalidation(pubsub)
+	return nil
+}
+
+func (mc *MultiCache) DisableInvalidation() error {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if mc.pubsub == nil {
+		return nil
+	}
+	err := mc.pubsub.Close() //closes the message channel and stops the listener
+	mc.pubsub = nil
+	mc.channel = ""
+	return err
+}
+
+func (mc *MultiCache) listenInvalidation(pubsub *redis.PubSub) {
+	for msg := range pubsub.Channel() {
+		var m invalidationMessage
+		if err := json.Unmarshal([]byte(msg.Payload), &m); err != nil {
+			continue
+		}
+		if m.Origin == mc.instanceID { //own write, local cache already up to date
+			continue
+		}
+		switch m.Op {
+		case opDelete:
+			mc.inMemoryCache.Delete(m.Key)
+			mc.events.Publish(events.Event{Op: events.OpDelete, Key: m.Key, Remote: true})
+		case opFlush:
+			mc.inMemoryCache.DeleteAll()
+			mc.events.Publish(events.Event{Op: events.OpFlush, Remote: true})
+		}
+	}
+}
+
+func (mc *MultiCache) publishInvalidation(op string, key string) error {
+	mc.mutex.Lock()
+	channel := mc.channel
+	mc.mutex.Unlock()
+
+	if channel == "" {
+		return nil
+	}
+	payload, err := json.Marshal(invalidationMessage{Origin: mc.instanceID, Op: op, Key: key})
+	if err != nil {
+		return err
+	}
+	return mc.redisCache.Publish(channel, payload)
+}
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
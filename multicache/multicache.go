@@ -1,38 +1,163 @@
 package multicache
 
 import (
-	"reflect"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
+	"unified/circuit_breaker"
+	"unified/events"
 	"unified/in_memory"
 	"unified/redis_cache"
+	"unified/tracing"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type MultiCache struct {
-	inMemoryCache *in_memory.LRUCache
-	redisCache    *redis_cache.RedisCache
+	inMemoryCache  *in_memory.LRUCache
+	redisCache     *redis_cache.RedisCache         //optional, nil runs without redis
+	backends       []Backend                       //tiers behind redis
+	instanceID     string                          //identifies this replica in invalidation messages
+	channel        string                          //invalidation channel, empty when disabled
+	pubsub         *redis.PubSub                   //invalidation subscription
+	breaker        *circuit_breaker.CircuitBreaker //guards the redis tier
+	writePolicy    WritePolicy
+	maxQueued      int
+	pending        []pendingWrite //redis writes deferred while the breaker is open
+	replayDone     chan struct{}  //closed when the running replay of pending finishes, nil when none runs
+	dropped        int
+	reconcileMode  ReconcileMode
+	authority      Tier
+	divergences    []Divergence //found by the last reconciling GetAll
+	events         *events.Bus
+	redisLookups   tierCounter
+	backendLookups []*tierCounter //parallel to backends
+	redisSize      cachedSize
+	mutex          sync.Mutex
 }
 
 func NewMultiCache(inMemoryCache *in_memory.LRUCache, redisCache *redis_cache.RedisCache) *MultiCache {
-	return &MultiCache{
+	mc := &MultiCache{
 		inMemoryCache: inMemoryCache,
 		redisCache:    redisCache,
+		instanceID:    newInstanceID(),
+		writePolicy:   WriteDrop,
+		events:        events.NewBus(),
 	}
+	inMemoryCache.Events().Forward(mc.events, events.OpEvict, events.OpExpire)
+	mc.ConfigureBreaker(5, time.Second, 30*time.Second)
+	return mc
 }
 
 func (mc *MultiCache) Set(key string, value interface{}, ttl time.Duration) error {
-	// Set in both caches
-	err := mc.redisCache.Set(key, value, ttl)
-	mc.inMemoryCache.Set(key, value, ttl)
+	return mc.SetWithTags(key, value, ttl)
+}
+
+// SetWithTags is Set, attaching tags that InvalidateTag deletes the key by
+func (mc *MultiCache) SetWithTags(key string, value interface{}, ttl time.Duration, tags ...string) error {
+	return mc.SetContext(context.Background(), key, value, ttl, tags...)
+}
+
+// SetContext is SetWithTags, tracing each tier it writes under ctx
+func (mc *MultiCache) SetContext(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error {
+	ctx, span := tracing.Start(ctx, "MultiCache.Set", attribute.String("cache.key", key))
+	err := mc.set(ctx, key, value, ttl, tags)
+	endSpan(span, err)
+	return err
+}
+
+func (mc *MultiCache) set(ctx context.Context, key string, value interface{}, ttl time.Duration, tags []string) error {
+	if ttl < 0 {
+		return redis_cache.ErrNegativeTTL
+	}
+	// Set in all tiers, redis is skipped while the breaker is open, a ttl of 0 never expires
+	_, span := tierSpan(ctx, TierMemory.String(), "set")
+	mc.inMemoryCache.SetWithTags(key, value, ttl, tags...)
+	span.End()
+	var backendErr error
+	for i, backend := range mc.backends {
+		_, span := tierSpan(ctx, mc.backendTier(i), "set")
+		err := backend.Set(key, value, ttl)
+		endSpan(span, err)
+		if err != nil { //keep writing the other tiers
+			backendErr = errors.Join(backendErr, fmt.Errorf("%s tier: %w", mc.backendTier(i), err))
+		}
+	}
+	mc.events.Publish(events.Event{Op: events.OpSet, Key: key, Value: value})
+	return errors.Join(backendErr, mc.setRedis(ctx, key, value, ttl, tags))
+}
+
+func (mc *MultiCache) setRedis(ctx context.Context, key string, value interface{}, ttl time.Duration, tags []string) error {
+	if mc.redisCache == nil {
+		return nil
+	}
+	deferred := pendingWrite{op: opSet, key: key, value: value, expiresAt: expiresAt(ttl), tags: tags}
+	if !mc.allowWrite(deferred) {
+		return nil
+	}
+	redisCtx, span := tierSpan(ctx, TierRedis.String(), "set")
+	err := mc.redisCache.WithContext(redisCtx).SetWithTags(key, value, ttl, tags...)
+	endSpan(span, err)
+	if mc.record(err) {
+		mc.deferWrite(deferred)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	return nil
+	return mc.publishInvalidation(opDelete, key) //drop stale copies on other replicas
 }
 
 func (mc *MultiCache) Get(key string) (interface{}, error) {
-	value1, _ := mc.inMemoryCache.Get(key)
-	value2, err := mc.redisCache.Get(key)
+	return mc.GetContext(context.Background(), key)
+}
+
+// GetContext is Get, tracing each tier it reads under ctx
+func (mc *MultiCache) GetContext(ctx context.Context, key string) (interface{}, error) {
+	ctx, span := tracing.Start(ctx, "MultiCache.Get", attribute.String("cache.key", key))
+	value, err := mc.get(ctx, key)
+	endSpan(span, err)
+	return value, err
+}
+
+func (mc *MultiCache) get(ctx context.Context, key string) (interface{}, error) {
+	_, span := tierSpan(ctx, TierMemory.String(), "get")
+	value1, found := mc.inMemoryCache.Get(key)
+	missing := !found && mc.inMemoryCache.IsMissing(key)
+	span.End()
+	if missing { //known to be missing, skip redis
+		return nil, redis.Nil
+	}
+	if mc.redisCache == nil {
+		if found {
+			return value1, nil
+		}
+		if value, ok := mc.fromBackends(ctx, key); ok {
+			return value, nil
+		}
+		mc.inMemoryCache.SetMissing(key)
+		return nil, redis.Nil
+	}
+	if (found && mc.writesQueued()) || !mc.breaker.Allow() { //redis may still hold an older value
+		return mc.withoutRedis(ctx, key, value1, found)
+	}
+	redisCtx, span := tierSpan(ctx, TierRedis.String(), "get")
+	value2, err := mc.redisCache.WithContext(redisCtx).Get(key)
+	endSpan(span, err)
+	mc.countRedis(err)
+	if mc.record(err) {
+		return mc.withoutRedis(ctx, key, value1, found)
+	}
+	if errors.Is(err, redis.Nil) {
+		if value, ok := mc.fromBackends(ctx, key); ok {
+			return value, nil
+		}
+		mc.inMemoryCache.SetMissing(key)
+	}
 
 	if value1 == value2 {
 		return value2, err
@@ -43,41 +168,342 @@ func (mc *MultiCache) Get(key string) (interface{}, error) {
 	return value2, nil
 }
 
+// Remaining time to live of key in the highest tier holding it, redis.Nil if missing everywhere
+func (mc *MultiCache) TTL(key string) (time.Duration, error) {
+	return mc.TTLContext(context.Background(), key)
+}
+
+// TTLContext is TTL, tracing each tier it reads under ctx
+func (mc *MultiCache) TTLContext(ctx context.Context, key string) (time.Duration, error) {
+	ctx, span := tracing.Start(ctx, "MultiCache.TTL", attribute.String("cache.key", key))
+	ttl, err := mc.ttl(ctx, key)
+	endSpan(span, err)
+	return ttl, err
+}
+
+func (mc *MultiCache) ttl(ctx context.Context, key string) (time.Duration, error) {
+	_, span := tierSpan(ctx, TierMemory.String(), "ttl")
+	ttl, ok := mc.inMemoryCache.TTL(key)
+	span.End()
+	if ok {
+		return ttl, nil
+	}
+	if mc.redisCache != nil && mc.breaker.Allow() {
+		redisCtx, span := tierSpan(ctx, TierRedis.String(), "ttl")
+		ttl, err := mc.redisCache.WithContext(redisCtx).TTL(key)
+		endSpan(span, err)
+		if !mc.record(err) {
+			if err != nil {
+				return 0, err
+			}
+			if ttl != -2 { //-2 missing, -1 no expiry
+				return ttl, nil
+			}
+		}
+	}
+	for i, backend := range mc.backends {
+		_, span := tierSpan(ctx, mc.backendTier(i), "ttl")
+		ttl, ok := backend.TTL(key)
+		span.End()
+		if ok {
+			return ttl, nil
+		}
+	}
+	return 0, redis.Nil
+}
+
+// Expire resets the ttl of key in every tier holding it without rewriting the value, a ttl of 0 or less deletes it.
+// False if the key is missing everywhere
+func (mc *MultiCache) Expire(key string, ttl time.Duration) (bool, error) {
+	return mc.ExpireContext(context.Background(), key, ttl)
+}
+
+// ExpireContext is Expire, tracing each tier it writes under ctx
+func (mc *MultiCache) ExpireContext(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ctx, span := tracing.Start(ctx, "MultiCache.Expire", attribute.String("cache.key", key))
+	found, err := mc.expire(ctx, key, ttl)
+	endSpan(span, err)
+	return found, err
+}
+
+func (mc *MultiCache) expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl > 0 {
+		return mc.setTTL(ctx, key, ttl)
+	}
+	_, err := mc.ttl(ctx, key)
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, mc.delete(ctx, key)
+}
+
+// Persist removes the ttl of key in every tier holding it, false if the key is missing everywhere
+func (mc *MultiCache) Persist(key string) (bool, error) {
+	return mc.PersistContext(context.Background(), key)
+}
+
+// PersistContext is Persist, tracing each tier it writes under ctx
+func (mc *MultiCache) PersistContext(ctx context.Context, key string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "MultiCache.Persist", attribute.String("cache.key", key))
+	found, err := mc.setTTL(ctx, key, 0)
+	endSpan(span, err)
+	return found, err
+}
+
+// setTTL updates the ttl of key in every tier holding it, 0 never expires. Backends cannot update a ttl, so the value is rewritten
+func (mc *MultiCache) setTTL(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	var found bool
+	_, span := tierSpan(ctx, TierMemory.String(), "expire")
+	if ttl == 0 {
+		found = mc.inMemoryCache.Persist(key)
+	} else {
+		found = mc.inMemoryCache.Expire(key, ttl)
+	}
+	span.End()
+	var backendErr error
+	for i, backend := range mc.backends {
+		_, span := tierSpan(ctx, mc.backendTier(i), "expire")
+		value, ok := backend.Get(key)
+		var err error
+		if ok {
+			err = backend.Set(key, value, ttl)
+			found = true
+		}
+		endSpan(span, err)
+		if err != nil {
+			backendErr = errors.Join(backendErr, fmt.Errorf("%s tier: %w", mc.backendTier(i), err))
+		}
+	}
+	ok, err := mc.setRedisTTL(ctx, key, ttl)
+	return found || ok, errors.Join(backendErr, err)
+}
+
+// setRedisTTL is setTTL on redis, false if redis does not hold the key or the write was deferred
+func (mc *MultiCache) setRedisTTL(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if mc.redisCache == nil {
+		return false, nil
+	}
+	deferred := pendingWrite{op: opExpire, key: key, expiresAt: expiresAt(ttl)}
+	if !mc.allowWrite(deferred) {
+		return false, nil
+	}
+	redisCtx, span := tierSpan(ctx, TierRedis.String(), "expire")
+	var ok bool
+	var err error
+	if ttl == 0 {
+		ok, err = mc.redisCache.WithContext(redisCtx).Persist(key)
+	} else {
+		ok, err = mc.redisCache.WithContext(redisCtx).Expire(key, ttl)
+	}
+	endSpan(span, err)
+	if mc.record(err) {
+		mc.deferWrite(deferred)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return ok, mc.publishInvalidation(opDelete, key) //other replicas reload the new ttl from redis
+}
+
+// expiresAt is the absolute expiry of a ttl, zero if it never expires
+func expiresAt(ttl time.Duration) time.Time {
+	if ttl == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+type Stats struct {
+	Memory in_memory.Stats `json:"memory"`
+	Redis  Health          `json:"redis"`
+	Tiers  []TierStats     `json:"tiers"`
+}
+
+func (mc *MultiCache) Stats() Stats {
+	return Stats{
+		Memory: mc.inMemoryCache.Stats(),
+		Redis:  mc.Health(),
+		Tiers:  mc.tierStats(),
+	}
+}
+
+// withoutRedis serves a read from the other tiers while redis is unavailable
+func (mc *MultiCache) withoutRedis(ctx context.Context, key string, value interface{}, found bool) (interface{}, error) {
+	if found {
+		return value, nil
+	}
+	if value, ok := mc.fromBackends(ctx, key); ok {
+		return value, nil
+	}
+	return nil, ErrRedisUnavailable
+}
+
 func (mc *MultiCache) GetAll() (map[string]interface{}, error) {
+	return mc.GetAllContext(context.Background())
+}
+
+// GetAllContext is GetAll, tracing each tier it reads under ctx
+func (mc *MultiCache) GetAllContext(ctx context.Context) (map[string]interface{}, error) {
+	ctx, span := tracing.Start(ctx, "MultiCache.GetAll")
+	values, err := mc.getAll(ctx)
+	endSpan(span, err)
+	return values, err
+}
+
+func (mc *MultiCache) getAll(ctx context.Context) (map[string]interface{}, error) {
+	// Get all from in-memory cache
+	_, span := tierSpan(ctx, TierMemory.String(), "getall")
+	inMemoryValues := mc.inMemoryCache.GetAll()
+	span.End()
+	if mc.redisCache == nil { //backends hold everything, memory has the freshest copies
+		values := mc.allFromBackends(ctx)
+		for key, value := range inMemoryValues {
+			values[key] = value
+		}
+		return values, nil
+	}
+	if !mc.breaker.Allow() {
+		return inMemoryValues, nil
+	}
+
 	// Get all from Redis
-	redisValues, err := mc.redisCache.GetAll()
+	redisCtx, span := tierSpan(ctx, TierRedis.String(), "getall")
+	redisValues, err := mc.redisCache.WithContext(redisCtx).GetAll()
+	endSpan(span, err)
+	if mc.record(err) {
+		return inMemoryValues, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Get all from in-memory cache
-	inMemoryValues := mc.inMemoryCache.GetAll()
+	// Report or repair divergence between redisValues and inMemoryValues
+	return mc.reconcile(ctx, inMemoryValues, redisValues)
+}
+
+// Scan pages through redis, or through memory when running without redis or while it is unavailable.
+// Cursors say which tier they belong to, a redis cursor fails with ErrRedisUnavailable while the breaker is open
+func (mc *MultiCache) Scan(cursor string, match string, count int) (map[string]interface{}, string, error) {
+	return mc.ScanContext(context.Background(), cursor, match, count)
+}
+
+// ScanContext is Scan, tracing the tier it reads under ctx
+func (mc *MultiCache) ScanContext(ctx context.Context, cursor string, match string, count int) (map[string]interface{}, string, error) {
+	ctx, span := tracing.Start(ctx, "MultiCache.Scan", attribute.String("cache.cursor", cursor))
+	values, next, err := mc.scan(ctx, cursor, match, count)
+	endSpan(span, err)
+	return values, next, err
+}
 
-	// Check if redisValues and inMemoryValues are the same
-	if reflect.DeepEqual(redisValues, inMemoryValues) {
-		return redisValues, nil
+func (mc *MultiCache) scan(ctx context.Context, cursor string, match string, count int) (map[string]interface{}, string, error) {
+	start := cursor == "" || cursor == in_memory.ScanStart
+	if in_memory.IsCursor(cursor) || (start && mc.redisCache == nil) {
+		return mc.inMemoryCache.Scan(cursor, match, count)
 	}
-	return redisValues, nil
+	if mc.redisCache == nil {
+		return nil, "", in_memory.ErrInvalidCursor
+	}
+	if !mc.breaker.Allow() {
+		if start {
+			return mc.inMemoryCache.Scan(cursor, match, count)
+		}
+		return nil, "", ErrRedisUnavailable
+	}
+	redisCtx, span := tierSpan(ctx, TierRedis.String(), "scan")
+	values, next, err := mc.redisCache.WithContext(redisCtx).Scan(cursor, match, count)
+	endSpan(span, err)
+	if mc.record(err) {
+		return nil, "", ErrRedisUnavailable
+	}
+	return values, next, err
 }
 
 func (mc *MultiCache) Delete(key string) error {
-	// Delete from both caches
+	return mc.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is Delete, tracing each tier it writes under ctx
+func (mc *MultiCache) DeleteContext(ctx context.Context, key string) error {
+	ctx, span := tracing.Start(ctx, "MultiCache.Delete", attribute.String("cache.key", key))
+	err := mc.delete(ctx, key)
+	endSpan(span, err)
+	return err
+}
+
+func (mc *MultiCache) delete(ctx context.Context, key string) error {
+	// Delete from all tiers
+	_, span := tierSpan(ctx, TierMemory.String(), "delete")
 	mc.inMemoryCache.Delete(key)
-	err := mc.redisCache.Delete(key)
+	span.End()
+	for i, backend := range mc.backends {
+		_, span := tierSpan(ctx, mc.backendTier(i), "delete")
+		backend.Delete(key)
+		span.End()
+	}
+	mc.events.Publish(events.Event{Op: events.OpDelete, Key: key})
+	if mc.redisCache == nil {
+		return nil
+	}
+	if !mc.allowWrite(pendingWrite{op: opDelete, key: key}) {
+		return nil
+	}
+	redisCtx, span := tierSpan(ctx, TierRedis.String(), "delete")
+	err := mc.redisCache.WithContext(redisCtx).Delete(key)
+	endSpan(span, err)
+	if mc.record(err) {
+		mc.deferWrite(pendingWrite{op: opDelete, key: key})
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	return nil
+	return mc.publishInvalidation(opDelete, key)
 }
 
 func (mc *MultiCache) DeleteAll() error {
-	// Delete all from both caches
+	return mc.DeleteAllContext(context.Background())
+}
+
+// DeleteAllContext is DeleteAll, tracing each tier it writes under ctx
+func (mc *MultiCache) DeleteAllContext(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "MultiCache.DeleteAll")
+	err := mc.deleteAll(ctx)
+	endSpan(span, err)
+	return err
+}
+
+func (mc *MultiCache) deleteAll(ctx context.Context) error {
+	// Delete all from all tiers
+	_, span := tierSpan(ctx, TierMemory.String(), "flush")
 	mc.inMemoryCache.DeleteAll()
-	err := mc.redisCache.DeleteAll()
+	span.End()
+	for i, backend := range mc.backends {
+		_, span := tierSpan(ctx, mc.backendTier(i), "flush")
+		backend.DeleteAll()
+		span.End()
+	}
+	mc.events.Publish(events.Event{Op: events.OpFlush})
+	if mc.redisCache == nil {
+		return nil
+	}
+	if !mc.allowWrite(pendingWrite{op: opFlush}) {
+		return nil
+	}
+	redisCtx, span := tierSpan(ctx, TierRedis.String(), "flush")
+	err := mc.redisCache.WithContext(redisCtx).DeleteAll()
+	endSpan(span, err)
+	if mc.record(err) {
+		mc.deferWrite(pendingWrite{op: opFlush})
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	return nil
+	return mc.publishInvalidation(opFlush, "")
 }
 
 // func (c *MultiCache) EvictFromBothCaches(key string) {
@@ -0,0 +1,214 @@
+package multicache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"unified/in_memory"
+	"unified/redis_cache"
+	"unified/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Cache tier treated as the source of truth when repairing
+type Tier int
+
+const (
+	TierRedis Tier = iota
+	TierMemory
+)
+
+func (t Tier) String() string {
+	if t == TierMemory {
+		return "memory"
+	}
+	return "redis"
+}
+
+// What GetAll does when the tiers disagree
+type ReconcileMode int
+
+const (
+	ReconcileOff    ReconcileMode = iota //return redis values, ignore divergence
+	ReconcileReport                      //record divergence for Divergences()
+	ReconcileRepair                      //record and re-sync from the authoritative tier
+)
+
+// Kinds of divergence between the tiers
+const (
+	MissingInMemory = "missing_in_memory"
+	MissingInRedis  = "missing_in_redis"
+	ValueMismatch   = "value_mismatch"
+)
+
+type Divergence struct {
+	Key         string      `json:"key"`
+	Kind        string      `json:"kind"`
+	MemoryValue interface{} `json:"memory_value,omitempty"`
+	RedisValue  interface{} `json:"redis_value,omitempty"`
+}
+
+func (mc *MultiCache) SetReconcileMode(mode ReconcileMode, authority Tier) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.reconcileMode = mode
+	mc.authority = authority
+}
+
+// Divergences found by the last GetAll in report or repair mode
+func (mc *MultiCache) Divergences() []Divergence {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	return mc.divergences
+}
+
+// Diff compares both tiers without changing either
+func (mc *MultiCache) Diff() ([]Divergence, error) {
+	return mc.DiffContext(context.Background())
+}
+
+// DiffContext is Diff, tracing each tier it reads under ctx
+func (mc *MultiCache) DiffContext(ctx context.Context) ([]Divergence, error) {
+	ctx, span := tracing.Start(ctx, "MultiCache.Diff")
+	divergences, err := mc.diff(ctx)
+	endSpan(span, err)
+	return divergences, err
+}
+
+func (mc *MultiCache) diff(ctx context.Context) ([]Divergence, error) {
+	if mc.redisCache == nil {
+		return nil, ErrNoRedis
+	}
+	redisCtx, span := tierSpan(ctx, TierRedis.String(), "getall")
+	redisValues, err := mc.redisCache.WithContext(redisCtx).GetAll()
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return diffTiers(mc.inMemoryCache.GetAll(), redisValues), nil
+}
+
+// Reconcile compares both tiers and re-syncs the other tier from authority
+func (mc *MultiCache) Reconcile(authority Tier) ([]Divergence, error) {
+	return mc.ReconcileContext(context.Background(), authority)
+}
+
+// ReconcileContext is Reconcile, tracing each tier it reads and repairs under ctx
+func (mc *MultiCache) ReconcileContext(ctx context.Context, authority Tier) ([]Divergence, error) {
+	ctx, span := tracing.Start(ctx, "MultiCache.Reconcile", attribute.String("cache.authority", authority.String()))
+	divergences, err := mc.diff(ctx)
+	if err == nil {
+		err = mc.repair(ctx, divergences, authority)
+	}
+	endSpan(span, err)
+	return divergences, err
+}
+
+// reconcile runs after GetAll fetched both tiers, according to the configured mode
+func (mc *MultiCache) reconcile(ctx context.Context, inMemoryValues map[string]interface{}, redisValues map[string]interface{}) (map[string]interface{}, error) {
+	mc.mutex.Lock()
+	mode, authority := mc.reconcileMode, mc.authority
+	mc.mutex.Unlock()
+
+	if mode == ReconcileOff {
+		return redisValues, nil
+	}
+	divergences := diffTiers(inMemoryValues, redisValues)
+	mc.mutex.Lock()
+	mc.divergences = divergences
+	mc.mutex.Unlock()
+
+	if mode == ReconcileRepair {
+		if err := mc.repair(ctx, divergences, authority); err != nil {
+			return nil, err
+		}
+	}
+	if authority == TierMemory {
+		return inMemoryValues, nil
+	}
+	return redisValues, nil
+}
+
+func (mc *MultiCache) repair(ctx context.Context, divergences []Divergence, authority Tier) error {
+	redisCache := mc.redisCache.WithContext(ctx)
+	for _, d := range divergences {
+		var err error
+		if authority == TierRedis {
+			err = mc.repairMemory(redisCache, d)
+		} else {
+			err = mc.repairRedis(redisCache, d)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repairMemory makes the in-memory cache match redisCache for one key
+func (mc *MultiCache) repairMemory(redisCache *redis_cache.RedisCache, d Divergence) error {
+	if d.Kind == MissingInRedis {
+		mc.inMemoryCache.Delete(d.Key)
+		return nil
+	}
+	ttl, err := redisCache.TTL(d.Key)
+	if err != nil {
+		return err
+	}
+	if ttl == in_memory.NoExpiry {
+		ttl = 0
+	} else if ttl <= 0 { //expired meanwhile
+		mc.inMemoryCache.Delete(d.Key)
+		return nil
+	}
+	mc.inMemoryCache.Set(d.Key, d.RedisValue, ttl)
+	return nil
+}
+
+// repairRedis makes redisCache match the in-memory cache for one key
+func (mc *MultiCache) repairRedis(redisCache *redis_cache.RedisCache, d Divergence) error {
+	if d.Kind == MissingInMemory {
+		return redisCache.Delete(d.Key)
+	}
+	ttl, ok := mc.inMemoryCache.TTL(d.Key)
+	if ttl == in_memory.NoExpiry {
+		ttl = 0
+	} else if !ok || ttl <= 0 {
+		return redisCache.Delete(d.Key)
+	}
+	return redisCache.Set(d.Key, d.MemoryValue, ttl)
+}
+
+func diffTiers(inMemoryValues map[string]interface{}, redisValues map[string]interface{}) []Divergence {
+	divergences := []Divergence{}
+	for key, redisValue := range redisValues {
+		memoryValue, ok := inMemoryValues[key]
+		switch {
+		case !ok:
+			divergences = append(divergences, Divergence{Key: key, Kind: MissingInMemory, RedisValue: redisValue})
+		case !sameValue(memoryValue, redisValue):
+			divergences = append(divergences, Divergence{Key: key, Kind: ValueMismatch, MemoryValue: memoryValue, RedisValue: redisValue})
+		}
+	}
+	for key, memoryValue := range inMemoryValues {
+		if _, ok := redisValues[key]; !ok {
+			divergences = append(divergences, Divergence{Key: key, Kind: MissingInRedis, MemoryValue: memoryValue})
+		}
+	}
+	sort.Slice(divergences, func(i, j int) bool { return divergences[i].Key < divergences[j].Key })
+	return divergences
+}
+
+// redis hands values back as strings, so compare on their string form
+func sameValue(memoryValue interface{}, redisValue interface{}) bool {
+	switch v := memoryValue.(type) {
+	case []byte:
+		return fmt.Sprint(redisValue) == string(v)
+	case float64: //json numbers, formatted the way go-redis writes them
+		return fmt.Sprint(redisValue) == strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(memoryValue) == fmt.Sprint(redisValue)
+}
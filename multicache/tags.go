@@ -0,0 +1,92 @@
+package multicache
+
+import (
+	"context"
+	"sort"
+
+	"unified/events"
+	"unified/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// InvalidateTag deletes every key set with tag from all tiers, returns the deleted keys
+func (mc *MultiCache) InvalidateTag(tag string) ([]string, error) {
+	return mc.InvalidateTagContext(context.Background(), tag)
+}
+
+// InvalidateTagContext is InvalidateTag, tracing each tier it writes under ctx
+func (mc *MultiCache) InvalidateTagContext(ctx context.Context, tag string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "MultiCache.InvalidateTag", attribute.String("cache.tag", tag))
+	keys, err := mc.invalidateTag(ctx, tag)
+	endSpan(span, err)
+	return keys, err
+}
+
+func (mc *MultiCache) invalidateTag(ctx context.Context, tag string) ([]string, error) {
+	_, span := tierSpan(ctx, TierMemory.String(), "invalidate_tag")
+	keys := mc.inMemoryCache.InvalidateTag(tag)
+	span.End()
+	if mc.redisCache == nil {
+		mc.dropTagged(keys)
+		return keys, nil
+	}
+	deferred := pendingWrite{op: opInvalidateTag, key: tag}
+	if !mc.allowWrite(deferred) {
+		mc.dropTagged(keys)
+		return keys, nil
+	}
+	redisCtx, span := tierSpan(ctx, TierRedis.String(), "invalidate_tag")
+	redisKeys, err := mc.redisCache.WithContext(redisCtx).InvalidateTag(tag)
+	endSpan(span, err)
+	if mc.record(err) {
+		mc.dropTagged(keys)
+		mc.deferWrite(deferred)
+		return keys, nil
+	}
+	if err != nil {
+		mc.dropTagged(keys)
+		return keys, err
+	}
+	keys = union(keys, redisKeys)
+	mc.dropTagged(keys)
+	return keys, mc.publishDeletes(keys)
+}
+
+// dropTagged removes invalidated keys from memory, where redis-only keys may have been read into, and the backends
+func (mc *MultiCache) dropTagged(keys []string) {
+	for _, key := range keys {
+		mc.inMemoryCache.Delete(key)
+		for _, backend := range mc.backends {
+			backend.Delete(key)
+		}
+		mc.events.Publish(events.Event{Op: events.OpDelete, Key: key})
+	}
+}
+
+// publishDeletes invalidates keys on the other replicas, returns the first error
+func (mc *MultiCache) publishDeletes(keys []string) error {
+	var first error
+	for _, key := range keys {
+		if err := mc.publishInvalidation(opDelete, key); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// union of two key lists, sorted
+func union(a []string, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	keys := make([]string, 0, len(a)+len(b))
+	for _, list := range [][]string{a, b} {
+		for _, key := range list {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
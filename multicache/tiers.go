@@ -0,0 +1,124 @@
+package multicache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"unified/circuit_breaker"
+	"unified/tracing"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TierStats describes a tier below memory, memory has its own in_memory.Stats
+type TierStats struct {
+	Tier      string `json:"tier"` //redis, or l2/l3 for backends by their level
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Evictions int64  `json:"evictions"`
+	Items     int64  `json:"items"` //-1 when unknown, e.g. redis is unavailable
+}
+
+// Redis sizes are read at most this often, stats of every namespace are gathered on each scrape
+const sizeRefresh = 5 * time.Second
+
+// cachedSize is the last size read from a tier
+type cachedSize struct {
+	items  int64
+	readAt time.Time
+	mutex  sync.Mutex //held while reading, concurrent scrapes share one round trip
+}
+
+// lookups of Get that reached a tier
+type tierCounter struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func (tc *tierCounter) count(found bool) {
+	if found {
+		tc.hits.Add(1)
+	} else {
+		tc.misses.Add(1)
+	}
+}
+
+// countRedis records a redis lookup, failures are neither hits nor misses
+func (mc *MultiCache) countRedis(err error) {
+	if err == nil || errors.Is(err, redis.Nil) {
+		mc.redisLookups.count(err == nil)
+	}
+}
+
+// backendTier names backend i by its level, l2 directly behind memory
+func (mc *MultiCache) backendTier(i int) string {
+	level := 2 + i
+	if mc.redisCache != nil {
+		level++
+	}
+	return fmt.Sprintf("l%d", level)
+}
+
+// tierSpan traces one access to a tier under ctx
+func tierSpan(ctx context.Context, tier string, op string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "tier "+tier, attribute.String("cache.tier", tier), attribute.String("cache.op", op))
+}
+
+// endSpan treats a missing key as a successful lookup
+func endSpan(span trace.Span, err error) {
+	if errors.Is(err, redis.Nil) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+func (mc *MultiCache) tierStats() []TierStats {
+	var tiers []TierStats
+	if mc.redisCache != nil {
+		items := int64(-1)
+		if mc.breaker.State() == circuit_breaker.StateClosed {
+			items = mc.redisItems()
+		}
+		tiers = append(tiers, TierStats{
+			Tier:      "redis",
+			Hits:      mc.redisLookups.hits.Load(),
+			Misses:    mc.redisLookups.misses.Load(),
+			Evictions: mc.redisCache.Evictions(),
+			Items:     items,
+		})
+	}
+	for i, backend := range mc.backends {
+		items := int64(-1)
+		if sized, ok := backend.(interface{ Len() int }); ok {
+			items = int64(sized.Len())
+		}
+		tiers = append(tiers, TierStats{
+			Tier:   mc.backendTier(i),
+			Hits:   mc.backendLookups[i].hits.Load(),
+			Misses: mc.backendLookups[i].misses.Load(),
+			Items:  items,
+		})
+	}
+	return tiers
+}
+
+// redisItems is the size of the redis LRU list read within sizeRefresh, -1 if redis fails
+func (mc *MultiCache) redisItems() int64 {
+	mc.redisSize.mutex.Lock()
+	defer mc.redisSize.mutex.Unlock()
+	if time.Since(mc.redisSize.readAt) < sizeRefresh {
+		return mc.redisSize.items
+	}
+	size, err := mc.redisCache.Size()
+	if err != nil {
+		return -1
+	}
+	mc.redisSize.items, mc.redisSize.readAt = size, time.Now()
+	return size
+}
@@ -0,0 +1,224 @@
+package namespace
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"unified/in_memory"
+	"unified/multicache"
+	"unified/redis_cache"
+)
+
+var (
+	ErrInvalidName = errors.New("namespace names are 1-64 letters, digits, '-', '_' or '.'")
+	ErrTooMany     = errors.New("too many namespaces")
+	ErrNotFound    = errors.New("namespace not found")
+)
+
+// Seconds between expired key sweeps of each namespace, as for the root cache
+const sweepInterval = 60
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// Namespace is one tenant's cache: its own in-memory LRU, redis keys and LRU list, and budget
+type Namespace struct {
+	Name     string
+	Capacity int
+	Memory   *in_memory.LRUCache
+	Redis    *redis_cache.RedisCache //nil when running without redis
+	Cache    *multicache.MultiCache
+}
+
+// Registry creates namespaces on first use, each with its own capacity budget
+type Registry struct {
+	capacity   int //default per-namespace capacity
+	capacities map[string]int
+	max        int //namespaces allowed, 0 for no limit
+	redisCache *redis_cache.RedisCache
+	onCreate   func(*Namespace)
+	namespaces map[string]*Namespace
+	creating   map[string]chan struct{} //closed once the namespace is registered
+	quiet      bool                     //event buses are closed, also those of namespaces created from now on
+	mutex      sync.Mutex
+}
+
+// NewRegistry gives every namespace capacity keys in memory and in redis, redisCache may be nil
+func NewRegistry(capacity int, redisCache *redis_cache.RedisCache) *Registry {
+	return &Registry{
+		capacity:   capacity,
+		capacities: make(map[string]int),
+		redisCache: redisCache,
+		namespaces: make(map[string]*Namespace),
+		creating:   make(map[string]chan struct{}),
+	}
+}
+
+// SetCapacity overrides the budget of one namespace, configure before it is first used
+func (r *Registry) SetCapacity(name string, capacity int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.capacities[name] = capacity
+}
+
+// SetMax caps the number of namespaces, 0 for no limit
+func (r *Registry) SetMax(max int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.max = max
+}
+
+// OnCreate is called with each new namespace before it is served, e.g. to configure its breaker or invalidation
+func (r *Registry) OnCreate(fn func(*Namespace)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onCreate = fn
+}
+
+// Get returns the namespace called name, creating it on first use
+func (r *Registry) Get(name string) (*Namespace, error) {
+	if !validName.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+	for {
+		r.mutex.Lock()
+		if ns, ok := r.namespaces[name]; ok {
+			r.mutex.Unlock()
+			return ns, nil
+		}
+		if creating, ok := r.creating[name]; ok { //wait for the other caller, then look again
+			r.mutex.Unlock()
+			<-creating
+			continue
+		}
+		if r.max > 0 && len(r.namespaces)+len(r.creating) >= r.max {
+			r.mutex.Unlock()
+			return nil, ErrTooMany
+		}
+		creating := make(chan struct{})
+		r.creating[name] = creating
+		capacity, ok := r.capacities[name]
+		if !ok {
+			capacity = r.capacity
+		}
+		onCreate := r.onCreate
+		r.mutex.Unlock()
+
+		//outside the lock, onCreate may subscribe to redis
+		ns := r.create(name, capacity, onCreate)
+		r.mutex.Lock()
+		r.namespaces[name] = ns
+		delete(r.creating, name)
+		if r.quiet {
+			closeEvents(ns)
+		}
+		r.mutex.Unlock()
+		close(creating)
+		return ns, nil
+	}
+}
+
+// Lookup returns the namespace called name without creating it, ErrNotFound unless it is in use or holds
+// keys in redis, e.g. from before a restart. Reads use it so they cannot use up the namespace limit
+func (r *Registry) Lookup(name string) (*Namespace, error) {
+	if !validName.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+	r.mutex.Lock()
+	ns, ok := r.namespaces[name]
+	_, pending := r.creating[name]
+	r.mutex.Unlock()
+	if ok {
+		return ns, nil
+	}
+	if !pending && !r.inRedis(name) {
+		return nil, ErrNotFound
+	}
+	return r.Get(name)
+}
+
+// inRedis reports whether the LRU list of the namespace holds keys
+func (r *Registry) inRedis(name string) bool {
+	if r.redisCache == nil {
+		return false
+	}
+	size, err := r.redisCache.Namespace(name, 0).Size()
+	return err == nil && size > 0
+}
+
+func (r *Registry) create(name string, capacity int, onCreate func(*Namespace)) *Namespace {
+	ns := &Namespace{
+		Name:     name,
+		Capacity: capacity,
+		Memory:   in_memory.NewLRUCache(capacity, sweepInterval),
+	}
+	if r.redisCache != nil {
+		ns.Redis = r.redisCache.Namespace(name, capacity)
+	}
+	ns.Cache = multicache.NewMultiCache(ns.Memory, ns.Redis)
+	if onCreate != nil {
+		onCreate(ns)
+	}
+	return ns
+}
+
+// Close flushes and stops every namespace, see MultiCache.Close and LRUCache.Close
+func (r *Registry) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var errs []error
+	for name, ns := range r.namespaces {
+		if err := errors.Join(ns.Cache.Close(), ns.Memory.Close()); err != nil {
+			errs = append(errs, fmt.Errorf("namespace %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CloseEvents ends the event streams of every namespace, including namespaces created afterwards
+func (r *Registry) CloseEvents() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.quiet = true
+	for _, ns := range r.namespaces {
+		closeEvents(ns)
+	}
+}
+
+func closeEvents(ns *Namespace) {
+	ns.Cache.Events().Close()
+	ns.Memory.Events().Close()
+}
+
+// Names of the namespaces in use, sorted
+func (r *Registry) Names() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	names := make([]string, 0, len(r.namespaces))
+	for name := range r.namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Stats of every namespace in use
+func (r *Registry) Stats() map[string]multicache.Stats {
+	r.mutex.Lock()
+	namespaces := make([]*Namespace, 0, len(r.namespaces))
+	for _, ns := range r.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	r.mutex.Unlock()
+
+	stats := make(map[string]multicache.Stats, len(namespaces))
+	for _, ns := range namespaces {
+		stats[ns.Name] = ns.Cache.Stats()
+	}
+	return stats
+}
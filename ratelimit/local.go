@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Buckets idle for this long are full again and are dropped
+const sweepEvery = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Local keeps token buckets in this process
+type Local struct {
+	rule      Rule
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+func NewLocal(rule Rule) *Local {
+	return &Local{rule: rule, buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+func (l *Local) Allow(key string) (bool, time.Duration, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= sweepEvery {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.rule.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(float64(l.rule.Burst), b.tokens+now.Sub(b.last).Seconds()*l.rule.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	return false, time.Duration((1 - b.tokens) / l.rule.Rate * float64(time.Second)), nil
+}
+
+// sweep drops buckets that have refilled completely, they behave like new ones
+func (l *Local) sweep(now time.Time) {
+	full := time.Duration(float64(l.rule.Burst) / l.rule.Rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"unified/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limiter is a set of token buckets, one per key
+type Limiter interface {
+	// Allow takes a token from key's bucket, or reports how long until one is available
+	Allow(key string) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// Rule is a token bucket refilled at Rate tokens per second, holding up to Burst
+type Rule struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseRules reads "group=rate:burst,..." e.g. "cache=100:200,ns=20:40,*=50:100".
+// Groups are the first path segment of a route (cache, ns, redis, inmemory), * applies to the others
+func ParseRules(s string) (map[string]Rule, error) {
+	rules := make(map[string]Rule)
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		group, limit, ok := strings.Cut(part, "=")
+		if !ok || group == "" {
+			return nil, fmt.Errorf("rate limit %q: want group=rate:burst", part)
+		}
+		rule, err := ParseRule(limit)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit %q: %w", part, err)
+		}
+		rules[group] = rule
+	}
+	return rules, nil
+}
+
+// ParseRule reads "rate:burst", e.g. "50:100"
+func ParseRule(s string) (Rule, error) {
+	rateText, burstText, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return Rule{}, errors.New("want rate:burst")
+	}
+	rate, err := strconv.ParseFloat(rateText, 64)
+	if err != nil || rate <= 0 {
+		return Rule{}, errors.New("rate must be a positive number")
+	}
+	burst, err := strconv.Atoi(burstText)
+	if err != nil || burst < 1 {
+		return Rule{}, errors.New("burst must be at least 1")
+	}
+	return Rule{Rate: rate, Burst: burst}, nil
+}
+
+// Identity keys buckets by the authenticated caller, or the client IP without authentication.
+// The client IP is only taken from X-Forwarded-For behind the engine's trusted proxies
+func Identity(c *gin.Context) string {
+	if principal := auth.PrincipalOf(c); principal != nil {
+		return "user:" + principal.Name
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// ClientIP keys buckets by the client IP whoever the caller claims to be, e.g. to limit requests before authentication
+func ClientIP(c *gin.Context) string {
+	return "addr:" + c.ClientIP()
+}
+
+// Middleware limits each route group with its own limiter, keyed by identity. Health probes are never limited.
+// Over the limit it answers 429 with Retry-After; a failing limiter lets requests through
+func Middleware(limiters map[string]Limiter, identity func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group := Group(c.FullPath())
+		limiter, ok := limiters[group]
+		if !ok {
+			limiter, ok = limiters["*"]
+		}
+		if !ok || group == "" || group == "health" || group == "healthz" || group == "readyz" {
+			c.Next()
+			return
+		}
+		allowed, retryAfter, err := limiter.Allow(group + ":" + identity(c))
+		if err != nil || allowed {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"status": "error", "error": "rate limit exceeded"})
+	}
+}
+
+// Group is the first segment of a route, e.g. cache for /cache/:key
+func Group(route string) string {
+	group, _, _ := strings.Cut(strings.TrimPrefix(route, "/"), "/")
+	return group
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"unified/circuit_breaker"
+	"unified/redis_cache"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// bucketScript refills and takes from the bucket in KEYS[1]: ARGV rate per second, burst, now in milliseconds.
+// Returns {allowed, milliseconds until a token is available}
+var bucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, wait}
+`)
+
+// Longest a request waits on redis for its bucket before falling back
+const redisTimeout = 200 * time.Millisecond
+
+// Redis keeps token buckets in redis so limits hold across instances.
+// While redis fails it falls back to per-instance buckets, without waiting on redis while its breaker is open
+type Redis struct {
+	client   *redis.Client
+	rule     Rule
+	fallback *Local
+	breaker  *circuit_breaker.CircuitBreaker
+}
+
+// NewRedis connects to the server of client with its own pool that honours redisTimeout and does not retry
+func NewRedis(client *redis.Client, rule Rule) *Redis {
+	options := *client.Options()
+	options.ContextTimeoutEnabled = true
+	options.MaxRetries = -1
+	return &Redis{
+		client:   redis.NewClient(&options),
+		rule:     rule,
+		fallback: NewLocal(rule),
+		breaker:  circuit_breaker.NewCircuitBreaker(5, time.Second, 30*time.Second),
+	}
+}
+
+func (r *Redis) Allow(key string) (bool, time.Duration, error) {
+	if !r.breaker.Allow() {
+		return r.fallback.Allow(key)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	now := time.Now().UnixMilli() //instances are assumed to have synchronized clocks
+	result, err := bucketScript.Run(ctx, r.client, []string{redis_cache.RateLimitPrefix + key}, r.rule.Rate, r.rule.Burst, now).Int64Slice()
+	if err != nil || len(result) != 2 {
+		r.breaker.Failure()
+		return r.fallback.Allow(key)
+	}
+	r.breaker.Success()
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
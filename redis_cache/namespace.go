@@ -0,0 +1,71 @@
+package redis_cache
+
+import (
+	"sync/atomic"
+)
+
+// Namespaced keys, LRU lists and tag sets live under cache_ns:<name>:
+const namespacePrefix = "cache_ns:"
+
+// Token buckets of ratelimit.Redis live under cache_ratelimit:, next to the root cache
+const RateLimitPrefix = "cache_ratelimit:"
+
+// Keys deleted per round trip when flushing a keyspace
+const deleteBatch = 500
+
+// Namespace returns a cache for one tenant sharing rc's connection, with its own keys, LRU list of maxSize and tag sets.
+// The name must not contain ':'
+func (rc *RedisCache) Namespace(name string, maxSize int) *RedisCache {
+	return &RedisCache{
+		Client:    rc.Client,
+		MaxSize:   maxSize,
+		prefix:    namespacePrefix + name + ":",
+		evictions: new(atomic.Int64),
+	}
+}
+
+// key is the redis key of a cache key
+func (rc *RedisCache) key(key string) string {
+	return rc.prefix + key
+}
+
+func (rc *RedisCache) keys(keys []string) []string {
+	if rc.prefix == "" {
+		return keys
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = rc.key(key)
+	}
+	return prefixed
+}
+
+// listKey holds the LRU order, most recent first
+func (rc *RedisCache) listKey() string {
+	return rc.key("cache_keys")
+}
+
+// deleteMatching removes every key matching the glob pattern, except those skip reports (nil for none)
+func (rc *RedisCache) deleteMatching(match string, skip func(string) bool) error {
+	iter := rc.Client.Scan(rc.context(), 0, match, deleteBatch).Iterator()
+	batch := make([]string, 0, deleteBatch)
+	for iter.Next(rc.context()) {
+		if skip != nil && skip(iter.Val()) {
+			continue
+		}
+		batch = append(batch, iter.Val())
+		if len(batch) == deleteBatch {
+			if err := rc.Client.Del(rc.context(), batch...).Err(); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return rc.Client.Del(rc.context(), batch...).Err()
+	}
+	return nil
+}
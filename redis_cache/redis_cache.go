@@ -3,107 +3,298 @@ package redis_cache
 import (
 	"context"
 	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"unified/in_memory"
+
 	"github.com/redis/go-redis/v9"
 )
 
-// Empty Context
-var ctx = context.Background()
+// Validation errors, returned before Redis is contacted
+var (
+	ErrEmptyKey      = errors.New("key cannot be empty")
+	ErrNegativeTTL   = errors.New("ttl cannot be negative")
+	ErrInvalidCursor = errors.New("invalid scan cursor")
+)
 
 // Configurable maxsize and redis.Client Initialization
 type RedisCache struct {
-	Client  *redis.Client
-	MaxSize int
+	Client    *redis.Client
+	MaxSize   int
+	prefix    string          //namespace key prefix, empty for the root keyspace
+	evictions *atomic.Int64   //keys this instance evicted past MaxSize
+	ctx       context.Context //commands run under it, see WithContext
 }
 
-// Redis Cache Initialization
+// Redis Cache Initialization, starts from an empty database
 func NewCache(addr string, password string, db int, maxSize int) *RedisCache {
+	rc := Connect(addr, password, db, maxSize)
+	rc.Client.FlushDB(rc.context())
+	return rc
+}
+
+// Connect keeps existing keys, e.g. to warm up the in-memory cache from them
+func Connect(addr string, password string, db int, maxSize int) *RedisCache {
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
 		DB:       db,
 	})
-	rdb.FlushDB(ctx)
 	return &RedisCache{
-		Client:  rdb,
-		MaxSize: maxSize,
+		Client:    rdb,
+		MaxSize:   maxSize,
+		evictions: new(atomic.Int64),
 	}
 }
 
-// REDIS LRU OPERATION METHODS
+// Key with its value and remaining ttl, as read for warm-up
+type Item struct {
+	Key   string
+	Value string
+	TTL   time.Duration
+}
+
+// REDIS LRU OPERATION METHODS, a ttl of 0 never expires
 func (rc *RedisCache) Set(key string, value interface{}, ttl time.Duration) error {
+	return rc.SetWithTags(key, value, ttl)
+}
+
+func (rc *RedisCache) Get(key string) (string, error) {
+	val, err := rc.Client.Get(rc.context(), rc.key(key)).Result()
+	if err != nil {
+		return "", err
+	}
+
+	rc.updateAccessOrder(key)
+	return val, nil
+}
+
+// Remaining time to live, negative if the key has no expiry or does not exist
+func (rc *RedisCache) TTL(key string) (time.Duration, error) {
+	return rc.Client.TTL(rc.context(), rc.key(key)).Result()
+}
+
+// Expire resets the ttl of key without rewriting its value, false if the key does not exist.
+// A ttl of 0 or less deletes the key. Tag sets of the key are kept alive at least as long
+func (rc *RedisCache) Expire(key string, ttl time.Duration) (bool, error) {
 	if key == "" {
-		return errors.New("key cannot be empty")
+		return false, ErrEmptyKey
 	}
-	if ttl == 0 {
-		return errors.New("ttl cannot be zero")
+	if ttl <= 0 {
+		deleted, err := rc.Client.Del(rc.context(), rc.key(key)).Result()
+		if err != nil {
+			return false, err
+		}
+		rc.Client.Del(rc.context(), rc.keyTagsKey(key))
+		rc.Client.LRem(rc.context(), rc.listKey(), 0, key)
+		return deleted > 0, nil
 	}
-	err := rc.Client.Set(ctx, key, value, ttl).Err()
-	if err != nil {
-		return err
+	return rc.expire(key, milliseconds(ttl))
+}
+
+// Persist removes the ttl of key and its tag sets so they never expire, false if the key does not exist
+func (rc *RedisCache) Persist(key string) (bool, error) {
+	if key == "" {
+		return false, ErrEmptyKey
 	}
+	return rc.expire(key, 0)
+}
 
-	rc.updateAccessOrder(key)    //Maintain LRU Order
-	return rc.evictIfNecessary() //perform LRU eviction if more than size
+func (rc *RedisCache) expire(key string, millis int64) (bool, error) {
+	found, err := expireScript.Run(rc.context(), rc.Client, []string{rc.key(key), rc.keyTagsKey(key)},
+		millis, key, rc.key(tagPrefix)).Int()
+	return found == 1, err
 }
 
-func (rc *RedisCache) Get(key string) (string, error) {
-	val, err := rc.Client.Get(ctx, key).Result()
+// Most recently used items, up to n, most recent first. Does not touch LRU order
+func (rc *RedisCache) Recent(n int) ([]Item, error) {
+	keys, err := rc.Client.LRange(rc.context(), rc.listKey(), 0, int64(n)-1).Result()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	rc.updateAccessOrder(key)
-	return val, nil
+	pipe := rc.Client.Pipeline() //one round trip for all values and ttls
+	gets := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		gets[i] = pipe.Get(rc.context(), rc.key(key))
+		ttls[i] = pipe.PTTL(rc.context(), rc.key(key))
+	}
+	if _, err := pipe.Exec(rc.context()); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(keys))
+	for i, key := range keys {
+		val, err := gets[i].Result()
+		if err != nil { //expired since it was listed
+			continue
+		}
+		items = append(items, Item{Key: key, Value: val, TTL: ttls[i].Val()})
+	}
+	return items, nil
 }
 
 func (rc *RedisCache) updateAccessOrder(key string) {
-	// remove and readd to maintain LRU order
-	rc.Client.LRem(ctx, "cache_keys", 0, key)
-	rc.Client.LPush(ctx, "cache_keys", key)
+	// remove and readd to maintain LRU order, in one transaction so concurrent updates cannot list a key twice
+	rc.Client.TxPipelined(rc.context(), func(pipe redis.Pipeliner) error {
+		pipe.LRem(rc.context(), rc.listKey(), 0, key)
+		pipe.LPush(rc.context(), rc.listKey(), key)
+		return nil
+	})
 }
 func (rc *RedisCache) evictIfNecessary() error {
-	size := rc.Client.LLen(ctx, "cache_keys").Val() //Redis List length, val return int64
-	if size > int64(rc.MaxSize) {                   //
+	size := rc.Client.LLen(rc.context(), rc.listKey()).Val() //Redis List length, val return int64
+	if size > int64(rc.MaxSize) {                            //
 		excess := size - int64(rc.MaxSize)
 		for i := int64(0); i < excess; i++ {
-			key := rc.Client.RPop(ctx, "cache_keys").Val()
-			rc.Client.Del(ctx, key)
+			key := rc.Client.RPop(rc.context(), rc.listKey()).Val()
+			rc.Client.Del(rc.context(), rc.key(key), rc.keyTagsKey(key))
+			rc.evictions.Add(1)
 		}
 	}
 	return nil
 }
 
+// Ping checks that redis answers
+func (rc *RedisCache) Ping() error {
+	return rc.Client.Ping(rc.context()).Err()
+}
+
+// Size is the number of keys tracked in the LRU list
+func (rc *RedisCache) Size() (int64, error) {
+	return rc.Client.LLen(rc.context(), rc.listKey()).Result()
+}
+
+// WithContext runs the commands of the returned cache under ctx, e.g. to trace them as part of a request
+func (rc *RedisCache) WithContext(ctx context.Context) *RedisCache {
+	scoped := *rc
+	scoped.ctx = ctx
+	return &scoped
+}
+
+func (rc *RedisCache) context() context.Context {
+	if rc.ctx == nil {
+		return context.Background()
+	}
+	return rc.ctx
+}
+
+// Evictions counts the keys this instance evicted, other instances sharing redis count their own
+func (rc *RedisCache) Evictions() int64 {
+	return rc.evictions.Load()
+}
+
 func (rc *RedisCache) GetAll() (map[string]interface{}, error) {
-	keys, err := rc.Client.LRange(ctx, "cache_keys", 0, -1).Result() //get all elements from list
+	keys, err := rc.Client.LRange(rc.context(), rc.listKey(), 0, -1).Result() //get all elements from list
 	if err != nil {
 		return nil, err
 	}
+	return rc.values(keys)
+}
 
-	values := make(map[string]interface{})
-	for _, key := range keys {
-		val, err := rc.Client.Get(ctx, key).Result() //returns the value for the specific key
+// Keys scanned per SCAN call when the caller gives no count
+const defaultScanCount = 10
+
+// Scan returns up to about count items whose keys match the glob pattern (empty matches all) and the
+// cursor of the next page, "0" once complete. Same guarantees as Redis SCAN: keys present for the
+// whole scan are returned, possibly more than once
+func (rc *RedisCache) Scan(cursor string, match string, count int) (map[string]interface{}, string, error) {
+	position := uint64(0)
+	if cursor != "" {
+		var err error
+		if position, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+	}
+	if count <= 0 {
+		count = defaultScanCount
+	}
+
+	if rc.prefix != "" { //only this namespace
+		if match == "" {
+			match = "*"
+		}
+		match = in_memory.EscapeGlob(rc.prefix) + match
+	}
+	var keys []string
+	for { //SCAN may return empty pages, keep going until something is found or the scan ends
+		page, next, err := rc.Client.Scan(rc.context(), position, match, int64(count)).Result()
 		if err != nil {
-			continue
+			return nil, "", err
+		}
+		for _, key := range page {
+			key = strings.TrimPrefix(key, rc.prefix)
+			if !isBookkeeping(key) { //LRU list, tag sets, namespaces and rate limit buckets, not cache items
+				keys = append(keys, key)
+			}
+		}
+		position = next
+		if position == 0 || len(keys) >= count {
+			break
+		}
+	}
+	values, err := rc.values(keys)
+	if err != nil {
+		return nil, "", err
+	}
+	return values, strconv.FormatUint(position, 10), nil
+}
+
+// Keys fetched per MGET
+const mgetBatch = 1000
+
+// values reads keys in batches, skipping keys that expired meanwhile
+func (rc *RedisCache) values(keys []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(keys))
+	for start := 0; start < len(keys); start += mgetBatch {
+		batch := keys[start:min(start+mgetBatch, len(keys))]
+		vals, err := rc.Client.MGet(rc.context(), rc.keys(batch)...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, val := range vals {
+			if val != nil {
+				values[batch[i]] = val
+			}
 		}
-		values[key] = val
 	}
 	return values, nil
 }
 
 func (rc *RedisCache) Delete(key string) error {
-	err := rc.Client.Del(ctx, key).Err()
+	err := rc.Client.Del(rc.context(), rc.key(key), rc.keyTagsKey(key)).Err()
 	if err != nil {
 		return err
 	}
-	rc.Client.LRem(ctx, "cache_keys", 0, key) //Remove element from list
+	rc.Client.LRem(rc.context(), rc.listKey(), 0, key) //Remove element from list
 	return nil
 }
+
+// DeleteAll deletes the keys of this keyspace: the root cache keeps every namespace and the rate limit buckets,
+// a namespace only deletes its own keys
 func (rc *RedisCache) DeleteAll() error {
-	_, err := rc.Client.FlushAll(ctx).Result()
-	if err != nil {
-		return err
+	if rc.prefix != "" {
+		return rc.deleteMatching(in_memory.GlobPrefix(rc.prefix), nil)
 	}
-	return nil
+	return rc.deleteMatching("*", func(key string) bool {
+		return strings.HasPrefix(key, namespacePrefix) || strings.HasPrefix(key, RateLimitPrefix)
+	})
+}
+
+// REDIS PUB/SUB METHODS
+func (rc *RedisCache) Publish(channel string, message interface{}) error {
+	return rc.Client.Publish(rc.context(), channel, message).Err()
+}
+
+func (rc *RedisCache) Subscribe(channel string) (*redis.PubSub, error) {
+	pubsub := rc.Client.Subscribe(rc.context(), channel)
+	if _, err := pubsub.Receive(rc.context()); err != nil { //wait for subscription confirmation
+		pubsub.Close()
+		return nil, err
+	}
+	return pubsub, nil
 }
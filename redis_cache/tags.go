@@ -0,0 +1,157 @@
+package redis_cache
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Tag index: one set of keys per tag and one set of tags per key, stored next to the cache items
+const (
+	tagPrefix     = "cache_tags:"
+	keyTagsPrefix = "cache_keytags:"
+)
+
+func (rc *RedisCache) tagKey(tag string) string {
+	return rc.key(tagPrefix + tag)
+}
+
+// keyTagsKey holds the tags key was last set with, it expires with the key
+func (rc *RedisCache) keyTagsKey(key string) string {
+	return rc.key(keyTagsPrefix + key)
+}
+
+// isBookkeeping reports keys used by the cache itself rather than items
+func isBookkeeping(key string) bool {
+	return key == "cache_keys" || strings.HasPrefix(key, tagPrefix) || strings.HasPrefix(key, keyTagsPrefix) ||
+		strings.HasPrefix(key, namespacePrefix) || strings.HasPrefix(key, RateLimitPrefix)
+}
+
+// extendTagSet adds member to a tag set and keeps the set at least as long as ttl milliseconds, 0 forever
+const extendTagSet = `
+local function extend(set, member, ttl)
+	local existed = redis.call('EXISTS', set)
+	redis.call('SADD', set, member)
+	if ttl == 0 then
+		redis.call('PERSIST', set)
+	elseif existed == 0 then
+		redis.call('PEXPIRE', set, ttl)
+	else
+		local current = redis.call('PTTL', set)
+		if current >= 0 and current < ttl then
+			redis.call('PEXPIRE', set, ttl)
+		end
+	end
+end
+`
+
+// setScript writes ARGV[1] to KEYS[1] for ARGV[2] milliseconds (0 forever), removes the key ARGV[3] from the
+// tag sets listed in KEYS[2] and adds it to the tag sets KEYS[3..], named ARGV[5..] under the prefix ARGV[4]
+var setScript = redis.NewScript(extendTagSet + `
+local ttl = tonumber(ARGV[2])
+for _, tag in ipairs(redis.call('SMEMBERS', KEYS[2])) do
+	redis.call('SREM', ARGV[4] .. tag, ARGV[3])
+end
+redis.call('DEL', KEYS[2])
+if ttl == 0 then
+	redis.call('SET', KEYS[1], ARGV[1])
+else
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+end
+for i = 3, #KEYS do
+	extend(KEYS[i], ARGV[3], ttl)
+	redis.call('SADD', KEYS[2], ARGV[i + 2])
+end
+if #KEYS > 2 and ttl > 0 then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+return 1
+`)
+
+// expireScript resets the ttl of KEYS[1] and its tag list KEYS[2] to ARGV[1] milliseconds (0 forever) and
+// keeps the tag sets under the prefix ARGV[3] alive as long, 0 if the key ARGV[2] does not exist
+var expireScript = redis.NewScript(extendTagSet + `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local ttl = tonumber(ARGV[1])
+if ttl == 0 then
+	redis.call('PERSIST', KEYS[1])
+	redis.call('PERSIST', KEYS[2])
+else
+	redis.call('PEXPIRE', KEYS[1], ttl)
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+for _, tag in ipairs(redis.call('SMEMBERS', KEYS[2])) do
+	extend(ARGV[3] .. tag, ARGV[2], ttl)
+end
+return 1
+`)
+
+// invalidateScript deletes the keys of the tag set KEYS[1] that are still tagged ARGV[1], their tag lists under
+// ARGV[3] and their place in the LRU list KEYS[2], then the tag set. Returns the deleted keys
+var invalidateScript = redis.NewScript(`
+local deleted = {}
+for _, key in ipairs(redis.call('SMEMBERS', KEYS[1])) do
+	local tags = ARGV[3] .. key
+	if redis.call('SISMEMBER', tags, ARGV[1]) == 1 then
+		for _, tag in ipairs(redis.call('SMEMBERS', tags)) do
+			redis.call('SREM', ARGV[4] .. tag, key)
+		end
+		redis.call('DEL', tags)
+		redis.call('LREM', KEYS[2], 0, key)
+		if redis.call('DEL', ARGV[2] .. key) == 1 then
+			table.insert(deleted, key)
+		end
+	end
+end
+redis.call('DEL', KEYS[1])
+return deleted
+`)
+
+// SetWithTags is Set, attaching tags that InvalidateTag deletes the key by. Replaces earlier tags of key;
+// the value and tags are written in one script
+func (rc *RedisCache) SetWithTags(key string, value interface{}, ttl time.Duration, tags ...string) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	if ttl < 0 {
+		return ErrNegativeTTL
+	}
+	keys := make([]string, 0, len(tags)+2)
+	keys = append(keys, rc.key(key), rc.keyTagsKey(key))
+	args := make([]interface{}, 0, len(tags)+4)
+	args = append(args, value, milliseconds(ttl), key, rc.key(tagPrefix))
+	for _, tag := range tags {
+		keys = append(keys, rc.tagKey(tag))
+		args = append(args, tag)
+	}
+	if err := setScript.Run(rc.context(), rc.Client, keys, args...).Err(); err != nil {
+		return err
+	}
+
+	rc.updateAccessOrder(key)    //Maintain LRU Order
+	return rc.evictIfNecessary() //perform LRU eviction if more than size
+}
+
+// InvalidateTag deletes every key set with tag and the tag set itself, returns the deleted keys
+func (rc *RedisCache) InvalidateTag(tag string) ([]string, error) {
+	deleted, err := invalidateScript.Run(rc.context(), rc.Client, []string{rc.tagKey(tag), rc.listKey()},
+		tag, rc.prefix, rc.key(keyTagsPrefix), rc.key(tagPrefix)).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(deleted) //keys that expired or were evicted meanwhile are skipped
+	return deleted, nil
+}
+
+// milliseconds rounds a positive ttl below a millisecond up so it still expires
+func milliseconds(ttl time.Duration) int64 {
+	millis := ttl.Milliseconds()
+	if ttl > 0 && millis == 0 {
+		millis = 1
+	}
+	return millis
+}
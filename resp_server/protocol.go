@@ -0,0 +1,144 @@
+package resp_server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var errProtocol = errors.New("protocol error")
+
+// DefaultMaxBulk is the size limit of one argument unless Server.SetMaxBulkSize changes it
+const DefaultMaxBulk = 1 << 20
+
+// Limits guarding against hostile clients
+const (
+	maxArgs        = 1024 * 1024
+	maxLineSize    = 64 * 1024 //inline commands and headers, same as redis
+	maxCommandSize = 64 << 20  //all arguments of one command
+	preallocArgs   = 64        //announced counts are not trusted beyond this
+)
+
+// readCommand reads one command, either a RESP array of bulk strings or an inline command.
+// Memory grows with the bytes received, not with the sizes the client announces
+func readCommand(r *bufio.Reader, maxBulk int) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != '*' { //inline command, e.g. typed into telnet
+		return strings.Fields(line), nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count > maxArgs {
+		return nil, errProtocol
+	}
+	args := make([]string, 0, min(max(count, 0), preallocArgs))
+	total := 0
+	for i := 0; i < count; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errProtocol
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulk || total+size > maxCommandSize {
+			return nil, errProtocol
+		}
+		total += size
+		arg, err := readBulk(r, size)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// readBulk reads a payload of size bytes and its CRLF, the buffer grows as the payload arrives
+func readBulk(r *bufio.Reader, size int) (string, error) {
+	var buf bytes.Buffer
+	buf.Grow(min(size, r.Size()))
+	if _, err := io.CopyN(&buf, r, int64(size)); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	if _, err := r.Discard(2); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// readLine reads a line of at most maxLineSize bytes
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineSize {
+			return "", errProtocol
+		}
+		line = append(line, chunk...)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return "", err
+		}
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// Reply writer, encodes nulls and maps according to the negotiated protocol version
+type writer struct {
+	w     *bufio.Writer
+	proto int //2 or 3
+}
+
+func (w *writer) simple(s string) {
+	fmt.Fprintf(w.w, "+%s\r\n", s)
+}
+
+func (w *writer) error(msg string) {
+	fmt.Fprintf(w.w, "-%s\r\n", msg)
+}
+
+func (w *writer) integer(n int64) {
+	fmt.Fprintf(w.w, ":%d\r\n", n)
+}
+
+func (w *writer) bulk(s string) {
+	fmt.Fprintf(w.w, "$%d\r\n%s\r\n", len(s), s)
+}
+
+func (w *writer) null() {
+	if w.proto == 3 {
+		w.w.WriteString("_\r\n")
+		return
+	}
+	w.w.WriteString("$-1\r\n")
+}
+
+func (w *writer) array(n int) {
+	fmt.Fprintf(w.w, "*%d\r\n", n)
+}
+
+// mapHeader starts a map of n pairs, a flat array in RESP2
+func (w *writer) mapHeader(n int) {
+	if w.proto == 3 {
+		fmt.Fprintf(w.w, "%%%d\r\n", n)
+		return
+	}
+	w.array(2 * n)
+}
@@ -0,0 +1,431 @@
+package resp_server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"unified/cache_store"
+	"unified/logging"
+)
+
+var ErrServerClosed = errors.New("resp_server: server closed")
+
+// Server speaks the Redis protocol (RESP2 and RESP3) on top of a cache_store.Store, so redis-cli and
+// existing client libraries can use the cache directly
+type Server struct {
+	store      cache_store.Store
+	defaultTTL time.Duration //used by SET without EX/PX, 0 never expires
+	maxBulk    int           //largest argument accepted, larger ones close the connection
+	auditor    *logging.Auditor
+	listener   net.Listener
+	conns      map[net.Conn]bool //true while running a command
+	closed     bool
+	nextID     int64
+	mutex      sync.Mutex
+}
+
+func NewServer(store cache_store.Store, defaultTTL time.Duration) *Server {
+	return &Server{
+		store:      store,
+		defaultTTL: defaultTTL,
+		maxBulk:    DefaultMaxBulk,
+		conns:      make(map[net.Conn]bool),
+	}
+}
+
+// SetMaxBulkSize limits the size of one argument, such as a value, in bytes. Configure before serving
+func (s *Server) SetMaxBulkSize(n int) {
+	s.maxBulk = n
+}
+
+// SetAuditor records every SET, DEL and FLUSHDB with auditor. Configure before serving
+func (s *Server) SetAuditor(auditor *logging.Auditor) {
+	s.auditor = auditor
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections until Close is called
+func (s *Server) Serve(listener net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mutex.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			closed := s.closed
+			s.mutex.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// How often Shutdown checks for connections that went idle
+const shutdownPoll = 50 * time.Millisecond
+
+// Shutdown stops accepting, closes idle connections and lets busy ones send the replies of the commands
+// they read before closing them. When ctx is done first the remaining connections are dropped as by Close
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.mutex.Unlock()
+
+	ticker := time.NewTicker(shutdownPoll)
+	defer ticker.Stop()
+	for !s.closeIdle() {
+		select {
+		case <-ctx.Done():
+			s.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return err
+}
+
+// closeIdle closes the connections waiting for a command, true once none are left
+func (s *Server) closeIdle() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for conn, busy := range s.conns {
+		if !busy {
+			conn.Close()
+			delete(s.conns, conn)
+		}
+	}
+	return len(s.conns) == 0
+}
+
+// Close stops the listener and drops open connections
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return err
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = false
+	return true
+}
+
+// setBusy marks conn as running a command, or idle once its replies are flushed. False when the
+// connection must end: Shutdown closed it while idle, or shutdown began and its replies are out
+func (s *Server) setBusy(conn net.Conn, busy bool) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, open := s.conns[conn]; !open || (!busy && s.closed) {
+		return false
+	}
+	s.conns[conn] = busy
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.conns, conn)
+}
+
+// Per-connection state
+type session struct {
+	id     int64
+	client net.Addr
+	out    *writer
+	quit   bool
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.untrack(conn)
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	sess := &session{
+		id:     atomic.AddInt64(&s.nextID, 1),
+		client: conn.RemoteAddr(),
+		out:    &writer{w: bufio.NewWriter(conn), proto: 2},
+	}
+	for !sess.quit {
+		args, err := readCommand(reader, s.maxBulk)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				sess.out.error("ERR Protocol error")
+				sess.out.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if !s.setBusy(conn, true) {
+			return
+		}
+		s.dispatch(sess, args)
+		if reader.Buffered() == 0 { //flush once per pipelined batch
+			if err := sess.out.w.Flush(); err != nil {
+				return
+			}
+			if !s.setBusy(conn, false) {
+				return
+			}
+		}
+	}
+	sess.out.w.Flush()
+}
+
+func (s *Server) dispatch(sess *session, args []string) {
+	out := sess.out
+	name := strings.ToUpper(args[0])
+	switch name {
+	case "PING":
+		switch len(args) {
+		case 1:
+			out.simple("PONG")
+		case 2:
+			out.bulk(args[1])
+		default:
+			wrongArgs(out, name)
+		}
+	case "ECHO":
+		if len(args) != 2 {
+			wrongArgs(out, name)
+			return
+		}
+		out.bulk(args[1])
+	case "GET":
+		if len(args) != 2 {
+			wrongArgs(out, name)
+			return
+		}
+		s.get(out, args[1])
+	case "MGET":
+		if len(args) < 2 {
+			wrongArgs(out, name)
+			return
+		}
+		out.array(len(args) - 1)
+		for _, key := range args[1:] {
+			s.get(out, key)
+		}
+	case "SET":
+		s.set(sess, args)
+	case "DEL", "UNLINK":
+		if len(args) < 2 {
+			wrongArgs(out, name)
+			return
+		}
+		var deleted int64
+		for _, key := range args[1:] {
+			ok, err := s.store.Delete(key)
+			s.audit(sess, name, logging.Action{Name: "delete", Key: key}, err)
+			if err != nil {
+				out.error("ERR " + err.Error())
+				return
+			}
+			if ok {
+				deleted++
+			}
+		}
+		out.integer(deleted)
+	case "EXISTS":
+		if len(args) < 2 {
+			wrongArgs(out, name)
+			return
+		}
+		var found int64
+		for _, key := range args[1:] {
+			_, ok, err := s.store.TTL(key)
+			if err != nil {
+				out.error("ERR " + err.Error())
+				return
+			}
+			if ok {
+				found++
+			}
+		}
+		out.integer(found)
+	case "TTL", "PTTL":
+		if len(args) != 2 {
+			wrongArgs(out, name)
+			return
+		}
+		ttl, ok, err := s.store.TTL(args[1])
+		switch {
+		case err != nil:
+			out.error("ERR " + err.Error())
+		case !ok:
+			out.integer(-2)
+		case ttl < 0:
+			out.integer(-1)
+		case name == "TTL":
+			out.integer(int64((ttl + time.Second/2) / time.Second))
+		default:
+			out.integer(ttl.Milliseconds())
+		}
+	case "FLUSHDB", "FLUSHALL":
+		err := s.store.DeleteAll()
+		s.audit(sess, name, logging.Action{Name: "flush"}, err)
+		if err != nil {
+			out.error("ERR " + err.Error())
+			return
+		}
+		out.simple("OK")
+	case "HELLO":
+		s.hello(sess, args)
+	case "SELECT":
+		if len(args) != 2 {
+			wrongArgs(out, name)
+			return
+		}
+		if args[1] != "0" {
+			out.error("ERR DB index is out of range")
+			return
+		}
+		out.simple("OK")
+	case "CLIENT":
+		if len(args) >= 2 && strings.EqualFold(args[1], "ID") {
+			out.integer(sess.id)
+			return
+		}
+		out.simple("OK") //SETNAME, SETINFO and friends are accepted and ignored
+	case "COMMAND":
+		out.array(0)
+	case "QUIT":
+		out.simple("OK")
+		sess.quit = true
+	default:
+		out.error("ERR unknown command '" + args[0] + "'")
+	}
+}
+
+func (s *Server) audit(sess *session, command string, action logging.Action, err error) {
+	s.auditor.Record(context.Background(), logging.Call{Protocol: "resp", Command: command, Client: sess.client, Action: action}, err)
+}
+
+func wrongArgs(out *writer, name string) {
+	out.error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+}
+
+func (s *Server) get(out *writer, key string) {
+	value, ok, err := s.store.Get(key)
+	switch {
+	case err != nil:
+		out.error("ERR " + err.Error())
+	case !ok:
+		out.null()
+	default:
+		out.bulk(cache_store.EncodeValue(value))
+	}
+}
+
+// SET key value [EX seconds | PX milliseconds]
+func (s *Server) set(sess *session, args []string) {
+	out := sess.out
+	if len(args) < 3 {
+		wrongArgs(out, "SET")
+		return
+	}
+	ttl := s.defaultTTL
+	for i := 3; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		if (option != "EX" && option != "PX") || i+1 >= len(args) {
+			out.error("ERR syntax error")
+			return
+		}
+		n, err := strconv.ParseInt(args[i+1], 10, 64)
+		if err != nil || n <= 0 {
+			out.error("ERR invalid expire time in 'set' command")
+			return
+		}
+		if option == "EX" {
+			ttl = time.Duration(n) * time.Second
+		} else {
+			ttl = time.Duration(n) * time.Millisecond
+		}
+		i++
+	}
+	err := s.store.Set(args[1], args[2], ttl)
+	s.audit(sess, "SET", logging.Action{Name: "set", Key: args[1]}, err)
+	if err != nil {
+		out.error("ERR " + err.Error())
+		return
+	}
+	out.simple("OK")
+}
+
+// HELLO [protover] switches the connection protocol and describes the server
+func (s *Server) hello(sess *session, args []string) {
+	out := sess.out
+	if len(args) >= 2 {
+		proto, err := strconv.Atoi(args[1])
+		if err != nil {
+			out.error("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if proto != 2 && proto != 3 {
+			out.error("NOPROTO unsupported protocol version")
+			return
+		}
+		out.proto = proto
+	}
+	out.mapHeader(7)
+	out.bulk("server")
+	out.bulk("unified")
+	out.bulk("version")
+	out.bulk("1.0.0")
+	out.bulk("proto")
+	out.integer(int64(out.proto))
+	out.bulk("id")
+	out.integer(sess.id)
+	out.bulk("mode")
+	out.bulk("standalone")
+	out.bulk("role")
+	out.bulk("master")
+	out.bulk("modules")
+	out.array(0)
+}
@@ -0,0 +1,162 @@
+package test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	api "unified/api_handler"
+	"unified/auth"
+	"unified/client"
+	"unified/multicache"
+	"unified/namespace"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 1. Test Role and Prefix Rules
+func TestAuthAllows(t *testing.T) {
+	reader := &auth.Principal{Name: "reader", Role: auth.RoleRead}
+	billing := &auth.Principal{Name: "billing", Role: auth.RoleWrite, Prefixes: []string{"invoice:"}}
+	acme := &auth.Principal{Name: "acme", Role: auth.RoleAdmin, Namespaces: []string{"acme"}}
+	cases := []struct {
+		principal *auth.Principal
+		req       auth.Request
+		allowed   bool
+	}{
+		{reader, auth.Request{Role: auth.RoleRead, Keys: []string{"any"}}, true},
+		{reader, auth.Request{Role: auth.RoleWrite, Keys: []string{"any"}}, false},
+		{billing, auth.Request{Role: auth.RoleWrite, Keys: []string{"invoice:1"}}, true},
+		{billing, auth.Request{Role: auth.RoleWrite, Keys: []string{"user:1"}}, false},
+		{billing, auth.Request{Role: auth.RoleRead, AllKeys: true}, false},
+		{billing, auth.Request{Role: auth.RoleRead, AllKeys: true, Prefix: "invoice:2024"}, true},
+		{billing, auth.Request{Role: auth.RoleAdmin, AllKeys: true}, false},
+		{billing, auth.Request{Role: auth.RoleWrite, Namespace: "acme", Keys: []string{"invoice:1"}}, false},
+		{acme, auth.Request{Role: auth.RoleAdmin, Namespace: "acme", AllKeys: true}, true},
+		{acme, auth.Request{Role: auth.RoleRead, Keys: []string{"any"}}, false},
+		{acme, auth.Request{Role: auth.RoleRead, Namespace: "globex", Keys: []string{"any"}}, false},
+	}
+	for i, tc := range cases {
+		if got := tc.principal.Allows(tc.req); got != tc.allowed {
+			t.Errorf("Case %d: expected %v, got %v", i, tc.allowed, got)
+		}
+	}
+}
+
+// 2. Test HMAC Tokens
+func TestAuthTokens(t *testing.T) {
+	secret := []byte("secret")
+	tokens := auth.NewHMACTokens(secret)
+
+	token, _ := auth.SignToken(secret, auth.Claims{Subject: "alice", Role: "write", Prefixes: []string{"user:"}})
+	principal, err := tokens.Verify(token)
+	if err != nil || principal.Name != "alice" || principal.Role != auth.RoleWrite || len(principal.Prefixes) != 1 {
+		t.Errorf("Expected alice with write on user:, got %+v, %v", principal, err)
+	}
+	if _, err := tokens.Verify(token[:len(token)-2] + "xx"); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Errorf("Expected a tampered token to be rejected, got %v", err)
+	}
+	forged, _ := auth.SignToken([]byte("other"), auth.Claims{Subject: "mallory", Role: "admin"})
+	if _, err := tokens.Verify(forged); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Errorf("Expected a token signed with another secret to be rejected, got %v", err)
+	}
+	expired, _ := auth.SignToken(secret, auth.Claims{Subject: "bob", Role: "read", Expires: time.Now().Add(-time.Minute).Unix()})
+	if _, err := tokens.Verify(expired); !errors.Is(err, auth.ErrExpired) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+}
+
+// 3. Test Middleware on the HTTP API
+func TestAuthHTTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authenticator, err := auth.Config{
+		HMACSecret: "secret",
+		APIKeys: []auth.KeyConfig{
+			{Name: "ops", Key: "k-ops", Role: "admin"},
+			{Name: "billing", Key: "k-billing", Role: "write", Prefixes: []string{"invoice:"}},
+		},
+	}.Authenticator()
+	if err != nil {
+		t.Fatalf("Authenticator failed: %v", err)
+	}
+	multiCache := multicache.NewMultiCache(setupTestInMemoryCache(), nil)
+	server := httptest.NewServer(api.SetupUnifiedRoutes(multiCache, auth.Middleware(authenticator, api.Access)))
+	defer server.Close()
+
+	anonymous := client.New(server.URL, 2*time.Second)
+	if err := anonymous.Cache().Set(ctx, "invoice:1", "a", 10*time.Second); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized without credentials, got %v", err)
+	}
+
+	billing := client.New(server.URL, 2*time.Second)
+	billing.SetAPIKey("k-billing")
+	if err := billing.Cache().Set(ctx, "invoice:1", "a", 10*time.Second); err != nil {
+		t.Errorf("Expected billing to write invoice:1, got %v", err)
+	}
+	if err := billing.Cache().Set(ctx, "user:1", "a", 10*time.Second); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden outside the prefix, got %v", err)
+	}
+	if _, _, err := billing.Cache().Scan(ctx, "0", "", 10); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for an unrestricted scan, got %v", err)
+	}
+	if err := billing.Cache().DeleteAll(ctx); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for a flush, got %v", err)
+	}
+
+	reader := client.New(server.URL, 2*time.Second)
+	token, _ := auth.SignToken([]byte("secret"), auth.Claims{Subject: "alice", Role: "read"})
+	reader.SetToken(token)
+	if value, err := reader.Cache().Get(ctx, "invoice:1"); err != nil || value != "a" {
+		t.Errorf("Expected the token to read invoice:1, got %v, %v", value, err)
+	}
+	if err := reader.Cache().Delete(ctx, "invoice:1"); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for a read-only token, got %v", err)
+	}
+
+	ops := client.New(server.URL, 2*time.Second)
+	ops.SetAPIKey("k-ops")
+	if err := ops.Cache().DeleteAll(ctx); err != nil {
+		t.Errorf("Expected ops to flush, got %v", err)
+	}
+}
+
+// 4. Test Namespace Rules on the HTTP API
+func TestAuthNamespaces(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authenticator, err := auth.Config{
+		HMACSecret: "secret",
+		APIKeys: []auth.KeyConfig{
+			{Name: "billing", Key: "k-billing", Role: "write", Prefixes: []string{"invoice:"}},
+		},
+	}.Authenticator()
+	if err != nil {
+		t.Fatalf("Authenticator failed: %v", err)
+	}
+	r := api.SetupUnifiedRoutes(multicache.NewMultiCache(setupTestInMemoryCache(), nil), auth.Middleware(authenticator, api.Access))
+	api.SetupNamespaceRoutes(r, namespace.NewRegistry(10, nil))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	billing := client.New(server.URL, 2*time.Second)
+	billing.SetAPIKey("k-billing")
+	if err := billing.Namespace("acme").Set(ctx, "invoice:1", "a", 10*time.Second); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("Expected prefixes to stay in the root keyspace, got %v", err)
+	}
+
+	tenant := client.New(server.URL, 2*time.Second)
+	token, _ := auth.SignToken([]byte("secret"), auth.Claims{Subject: "acme", Role: "admin", Namespaces: []string{"acme"}})
+	tenant.SetToken(token)
+	if err := tenant.Namespace("acme").Set(ctx, "key", "a", 10*time.Second); err != nil {
+		t.Errorf("Expected acme to write its namespace, got %v", err)
+	}
+	if err := tenant.Namespace("acme").DeleteAll(ctx); err != nil {
+		t.Errorf("Expected acme to flush its namespace, got %v", err)
+	}
+	if err := tenant.Namespace("globex").Set(ctx, "key", "a", 10*time.Second); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden in another namespace, got %v", err)
+	}
+	if err := tenant.Cache().DeleteAll(ctx); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden in the root keyspace, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	api "unified/api_handler"
+	"unified/cachectl"
+	"unified/multicache"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupCachectl(t *testing.T) func(args ...string) (string, int) {
+	gin.SetMode(gin.TestMode)
+	multiCache := multicache.NewMultiCache(setupTestInMemoryCache(), nil)
+	server := httptest.NewServer(api.SetupUnifiedRoutes(multiCache))
+	t.Cleanup(server.Close)
+	return func(args ...string) (string, int) {
+		var stdout, stderr bytes.Buffer
+		code := cachectl.Run(ctx, append([]string{"-addr", server.URL}, args...), strings.NewReader(""), &stdout, &stderr)
+		return stdout.String() + stderr.String(), code
+	}
+}
+
+// 1. Test Get, Set, Del, Getall, Flush
+func TestCachectlCommands(t *testing.T) {
+	run := setupCachectl(t)
+
+	if out, code := run("set", "-ttl", "30s", "key1", "value1"); code != 0 || out != "OK\n" {
+		t.Errorf("Expected OK, got %q (exit %d)", out, code)
+	}
+	run("set", "key2", `{"n":1}`)
+	if out, code := run("get", "key1"); code != 0 || out != "value1\n" {
+		t.Errorf("Expected value1, got %q (exit %d)", out, code)
+	}
+	if out, _ := run("-o", "json", "get", "key2"); !strings.Contains(out, `"n": 1`) {
+		t.Errorf("Expected JSON value to be kept as an object, got %q", out)
+	}
+	if out, code := run("ttl", "key1"); code != 0 || !strings.HasSuffix(out, "s\n") {
+		t.Errorf("Expected a duration, got %q (exit %d)", out, code)
+	}
+	if out, code := run("getall"); code != 0 || !strings.HasPrefix(out, "KEY") || !strings.Contains(out, "key2  {\"n\":1}") {
+		t.Errorf("Expected a table of keys, got %q (exit %d)", out, code)
+	}
+	if out, code := run("stats"); code != 0 || !strings.Contains(out, "memory.hits") {
+		t.Errorf("Expected flattened stats, got %q (exit %d)", out, code)
+	}
+
+	if _, code := run("del", "key1"); code != 0 {
+		t.Errorf("Expected del to succeed, exit %d", code)
+	}
+	if out, code := run("get", "key1"); code != 1 || !strings.Contains(out, "not found") {
+		t.Errorf("Expected not found with exit 1, got %q (exit %d)", out, code)
+	}
+	if _, code := run("flush"); code != 0 {
+		t.Errorf("Expected flush to succeed, exit %d", code)
+	}
+	if out, _ := run("-o", "json", "getall"); strings.TrimSpace(out) != "{}" {
+		t.Errorf("Expected an empty cache, got %q", out)
+	}
+	if _, code := run("bogus"); code != 2 {
+		t.Errorf("Expected exit 2 for an unknown command, got %d", code)
+	}
+}
+
+// 2. Test Import and Export
+func TestCachectlImportExport(t *testing.T) {
+	run := setupCachectl(t)
+	file := filepath.Join(t.TempDir(), "keys.json")
+	os.WriteFile(file, []byte(`{"a": "1", "b": {"nested": true}}`), 0o644)
+
+	if out, code := run("import", "-ttl", "1m", file); code != 0 || out != "Imported 2 keys\n" {
+		t.Errorf("Expected 2 keys imported, got %q (exit %d)", out, code)
+	}
+	out, code := run("export", "-")
+	var exported map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &exported); code != 0 || err != nil {
+		t.Fatalf("Expected exported JSON, got %q (exit %d)", out, code)
+	}
+	if exported["a"] != "1" || exported["b"].(map[string]interface{})["nested"] != true {
+		t.Errorf("Expected imported values to round-trip with their types, got %v", exported)
+	}
+}
+
+// 3. Test Shell Completion
+func TestCachectlCompletion(t *testing.T) {
+	run := setupCachectl(t)
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		if out, code := run("completion", shell); code != 0 || !strings.Contains(out, "getall") {
+			t.Errorf("Expected a %s completion script listing commands, got exit %d", shell, code)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"unified/circuit_breaker"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := circuit_breaker.NewCircuitBreaker(2, 50*time.Millisecond, 200*time.Millisecond)
+
+	//1. OPEN AFTER THRESHOLD
+	t.Run("Opens after consecutive failures", func(t *testing.T) {
+		breaker.Failure()
+		if breaker.State() != circuit_breaker.StateClosed {
+			t.Errorf("Expected breaker to stay closed below threshold")
+		}
+		breaker.Failure()
+		if breaker.State() != circuit_breaker.StateOpen {
+			t.Errorf("Expected breaker to open at threshold, got %v", breaker.State())
+		}
+		if breaker.Allow() {
+			t.Errorf("Expected open breaker to reject calls")
+		}
+	})
+	//2. SINGLE PROBE WHEN HALF-OPEN
+	t.Run("Half-open allows a single probe", func(t *testing.T) {
+		time.Sleep(60 * time.Millisecond)
+		if !breaker.Allow() {
+			t.Fatalf("Expected probe to be allowed after backoff")
+		}
+		if breaker.State() != circuit_breaker.StateHalfOpen {
+			t.Errorf("Expected half-open state, got %v", breaker.State())
+		}
+		if breaker.Allow() {
+			t.Errorf("Expected second call to be rejected while probing")
+		}
+	})
+	//3. FAILED PROBE BACKS OFF
+	t.Run("Failed probe reopens with longer backoff", func(t *testing.T) {
+		breaker.Failure()
+		if breaker.State() != circuit_breaker.StateOpen {
+			t.Errorf("Expected breaker to reopen, got %v", breaker.State())
+		}
+		time.Sleep(60 * time.Millisecond)
+		if breaker.Allow() {
+			t.Errorf("Expected backoff to have doubled")
+		}
+		time.Sleep(50 * time.Millisecond)
+		if !breaker.Allow() {
+			t.Errorf("Expected probe to be allowed after doubled backoff")
+		}
+	})
+	//4. SUCCESSFUL PROBE CLOSES
+	t.Run("Successful probe closes", func(t *testing.T) {
+		breaker.Success()
+		if breaker.State() != circuit_breaker.StateClosed {
+			t.Errorf("Expected breaker to close, got %v", breaker.State())
+		}
+		if !breaker.Allow() {
+			t.Errorf("Expected closed breaker to allow calls")
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	api "unified/api_handler"
+	"unified/client"
+	"unified/multicache"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupUnifiedTestServer(t *testing.T) *client.Client {
+	gin.SetMode(gin.TestMode)
+	multiCache := multicache.NewMultiCache(setupTestInMemoryCache(), nil)
+	server := httptest.NewServer(api.SetupUnifiedRoutes(multiCache))
+	t.Cleanup(server.Close)
+	return client.New(server.URL, 2*time.Second)
+}
+
+func setupSplitTestServer(t *testing.T) *client.Client {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	api.SetupInMemoryRoutes(r, setupTestInMemoryCache())
+	api.SetupRedisRoutes(r, setupRedisTestCache())
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return client.New(server.URL, 2*time.Second)
+}
+
+// 1. Test Unified Routes
+func TestClientCache(t *testing.T) {
+	cache := setupUnifiedTestServer(t).Cache()
+
+	if err := cache.Set(ctx, "key1", map[string]interface{}{"n": 1.0}, 10*time.Second); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+	value, err := cache.Get(ctx, "key1")
+	if err != nil || value.(map[string]interface{})["n"] != 1.0 {
+		t.Errorf("Expected {n: 1}, got %v, %v", value, err)
+	}
+	if _, err := cache.Get(ctx, "missing"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	cache.Set(ctx, "key 2", "space", 10*time.Second)
+	if value, err := cache.Get(ctx, "key 2"); err != nil || value != "space" {
+		t.Errorf("Expected escaped key to round-trip, got %v, %v", value, err)
+	}
+
+	values, err := cache.GetAll(ctx)
+	if err != nil || len(values) != 2 {
+		t.Errorf("Expected 2 values, got %v, %v", values, err)
+	}
+	if err := cache.Delete(ctx, "key1"); err != nil {
+		t.Errorf("Failed to delete key: %v", err)
+	}
+	if err := cache.DeleteAll(ctx); err != nil {
+		t.Errorf("Failed to delete all keys: %v", err)
+	}
+	if values, _ := cache.GetAll(ctx); len(values) != 0 {
+		t.Errorf("Expected an empty cache, got %v", values)
+	}
+}
+
+// 2. Test Redis and In-Memory Routes
+func TestClientRedisInMemory(t *testing.T) {
+	c := setupSplitTestServer(t)
+
+	redisAPI := c.Redis()
+	if err := redisAPI.Set(ctx, "key1", "value1", 10*time.Second); err != nil {
+		t.Fatalf("Failed to set redis key: %v", err)
+	}
+	if value, err := redisAPI.Get(ctx, "key1"); err != nil || value != "value1" {
+		t.Errorf("Expected 'value1', got %q, %v", value, err)
+	}
+	if _, err := redisAPI.Get(ctx, "missing"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if values, err := redisAPI.GetAll(ctx); err != nil || values["key1"] != "value1" {
+		t.Errorf("Expected key1 in GetAll, got %v, %v", values, err)
+	}
+	if err := redisAPI.Set(ctx, "", "value", 10*time.Second); !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest for an empty key, got %v", err)
+	}
+	if err := redisAPI.Set(ctx, "forever", "value", 0); err != nil {
+		t.Errorf("Expected ttl 0 to store a key that never expires, got %v", err)
+	}
+	if err := redisAPI.Set(ctx, "key2", "value", -5*time.Second); !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest for a negative ttl, got %v", err)
+	}
+	redisAPI.DeleteAll(ctx)
+
+	memory := c.InMemory()
+	if err := memory.Set(ctx, "key1", "value1", 10*time.Second); err != nil {
+		t.Fatalf("Failed to set in-memory key: %v", err)
+	}
+	if value, err := memory.Get(ctx, "key1"); err != nil || value != "value1" {
+		t.Errorf("Expected 'value1', got %v, %v", value, err)
+	}
+	if deleted, err := memory.Delete(ctx, "key1"); err != nil || !deleted {
+		t.Errorf("Expected key1 to be deleted, got %v, %v", deleted, err)
+	}
+	if deleted, err := memory.Delete(ctx, "key1"); err != nil || deleted {
+		t.Errorf("Expected delete of a missing key to return false, got %v, %v", deleted, err)
+	}
+}
+
+// 3. Test Retries and Timeouts
+func TestClientRetriesTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	multiCache := multicache.NewMultiCache(setupTestInMemoryCache(), nil)
+	routes := api.SetupUnifiedRoutes(multiCache)
+	var calls int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		routes.ServeHTTP(w, r)
+	}))
+	defer flaky.Close()
+
+	c := client.New(flaky.URL, time.Second)
+	c.SetRetries(2, time.Millisecond)
+	if err := c.Cache().Set(ctx, "key1", "value1", 10*time.Second); err != nil {
+		t.Errorf("Expected the third attempt to succeed, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	c.SetRetries(0, 0)
+	if err := c.Cache().Set(ctx, "key1", "value1", 10*time.Second); !errors.Is(err, client.ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable without retries, got %v", err)
+	}
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	c = client.New(slow.URL, 50*time.Millisecond)
+	c.SetRetries(0, 0)
+	start := time.Now()
+	if _, err := c.Cache().Get(ctx, "key1"); err == nil {
+		t.Errorf("Expected a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Expected the request to time out after 50ms, took %v", elapsed)
+	}
+}
+
+// 4. Test Keys Named Like Admin Routes
+func TestClientReservedKeys(t *testing.T) {
+	cache := setupUnifiedTestServer(t).Cache()
+
+	for _, key := range []string{"diff", "stats", "events"} {
+		if err := cache.Set(ctx, key, "value", 10*time.Second); err != nil {
+			t.Fatalf("Failed to set key %s: %v", key, err)
+		}
+		if value, err := cache.Get(ctx, key); err != nil || value != "value" {
+			t.Errorf("Expected key %s to be reachable, got %v, %v", key, value, err)
+		}
+	}
+
+	memory := setupSplitTestServer(t).InMemory()
+	memory.Set(ctx, "events", "value", 10*time.Second)
+	if value, err := memory.Get(ctx, "events"); err != nil || value != "value" {
+		t.Errorf("Expected in-memory key events to be reachable, got %v, %v", value, err)
+	}
+}
@@ -0,0 +1,160 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"unified/disk_cache"
+)
+
+func setupDiskTestCache(t *testing.T, dir string) *disk_cache.DiskCache {
+	cache, err := disk_cache.NewDiskCache(dir, 1024)
+	if err != nil {
+		t.Fatalf("Failed to open disk cache: %v", err)
+	}
+	return cache
+}
+
+// 1. Test Set, Get, Delete
+func TestDiskSetGetDelete(t *testing.T) {
+	cache := setupDiskTestCache(t, t.TempDir())
+	defer cache.Close()
+
+	cache.Set("key1", "value1", 10*time.Second)
+	value, ok := cache.Get("key1")
+	if !ok || value != "value1" {
+		t.Errorf("Expected 'value1', got %v", value)
+	}
+
+	if !cache.Delete("key1") {
+		t.Errorf("Expected Delete to report the key existed")
+	}
+	if _, ok := cache.Get("key1"); ok {
+		t.Errorf("Expected key1 to be deleted")
+	}
+	if cache.Delete("key1") {
+		t.Errorf("Expected Delete of missing key to return false")
+	}
+}
+
+// 2. Test Persistence Across Restart
+func TestDiskReopen(t *testing.T) {
+	dir := t.TempDir()
+	cache := setupDiskTestCache(t, dir)
+	for i := 0; i < 50; i++ { // enough to roll several 1KB segments
+		cache.Set("key"+strconv.Itoa(i), "value"+strconv.Itoa(i), 10*time.Second)
+	}
+	cache.Set("key0", "updated", 10*time.Second)
+	cache.Delete("key1")
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "segment-*.log"))
+	if len(segments) < 2 {
+		t.Errorf("Expected writes to roll over several segments, got %d", len(segments))
+	}
+
+	cache = setupDiskTestCache(t, dir)
+	defer cache.Close()
+	if value, _ := cache.Get("key0"); value != "updated" {
+		t.Errorf("Expected latest value 'updated', got %v", value)
+	}
+	if _, ok := cache.Get("key1"); ok {
+		t.Errorf("Expected deleted key1 to stay deleted")
+	}
+	if len(cache.GetAll()) != 49 {
+		t.Errorf("Expected 49 keys after reopen, got %d", len(cache.GetAll()))
+	}
+}
+
+// 3. Test TTL Expiration
+func TestDiskTTL(t *testing.T) {
+	cache := setupDiskTestCache(t, t.TempDir())
+	defer cache.Close()
+
+	cache.Set("short", "value", 1*time.Second)
+	cache.Set("long", "value", 10*time.Second)
+	if ttl, ok := cache.TTL("long"); !ok || ttl <= 8*time.Second {
+		t.Errorf("Expected ttl close to 10s, got %v", ttl)
+	}
+	time.Sleep(2 * time.Second)
+	if _, ok := cache.Get("short"); ok {
+		t.Errorf("Expected short to expire")
+	}
+	if _, ok := cache.Get("long"); !ok {
+		t.Errorf("Expected long to survive")
+	}
+}
+
+// 4. Test Compaction
+func TestDiskCompact(t *testing.T) {
+	dir := t.TempDir()
+	cache := setupDiskTestCache(t, dir)
+	for i := 0; i < 100; i++ {
+		cache.Set("key", "value"+strconv.Itoa(i), 10*time.Second)
+	}
+	if err := cache.Compact(); err != nil {
+		t.Fatalf("Failed to compact: %v", err)
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "segment-*.log"))
+	if len(segments) != 1 {
+		t.Errorf("Expected a single segment after compaction, got %d", len(segments))
+	}
+	if value, _ := cache.Get("key"); value != "value99" {
+		t.Errorf("Expected 'value99' after compaction, got %v", value)
+	}
+	cache.Close()
+
+	cache = setupDiskTestCache(t, dir)
+	defer cache.Close()
+	if value, _ := cache.Get("key"); value != "value99" {
+		t.Errorf("Expected 'value99' after reopen, got %v", value)
+	}
+}
+
+// 5. Test Torn Write
+func TestDiskTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	cache := setupDiskTestCache(t, dir)
+	cache.Set("key1", "value1", 10*time.Second)
+	cache.Close()
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "segment-*.log"))
+	file, _ := os.OpenFile(segments[len(segments)-1], os.O_APPEND|os.O_WRONLY, 0644)
+	file.Write([]byte{0, 1, 2, 3, 1, 0}) // partial header
+	file.Close()
+
+	cache = setupDiskTestCache(t, dir)
+	defer cache.Close()
+	if value, _ := cache.Get("key1"); value != "value1" {
+		t.Errorf("Expected 'value1' to survive a torn tail, got %v", value)
+	}
+	cache.Set("key2", "value2", 10*time.Second)
+	if value, _ := cache.Get("key2"); value != "value2" {
+		t.Errorf("Expected writes after recovery to work, got %v", value)
+	}
+}
+
+// 6. Test DeleteAll
+func TestDiskDeleteAll(t *testing.T) {
+	dir := t.TempDir()
+	cache := setupDiskTestCache(t, dir)
+	cache.Set("key1", "value1", 10*time.Second)
+	if !cache.DeleteAll() {
+		t.Errorf("Expected DeleteAll to report deleted keys")
+	}
+	if len(cache.GetAll()) != 0 {
+		t.Errorf("Expected empty cache after DeleteAll")
+	}
+	cache.Close()
+
+	cache = setupDiskTestCache(t, dir)
+	defer cache.Close()
+	if len(cache.GetAll()) != 0 {
+		t.Errorf("Expected empty cache after reopen")
+	}
+}
@@ -0,0 +1,126 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	api "unified/api_handler"
+	"unified/events"
+	"unified/in_memory"
+	"unified/multicache"
+
+	"github.com/gin-gonic/gin"
+)
+
+func nextEvent(t *testing.T, sub *events.Subscription) events.Event {
+	t.Helper()
+	select {
+	case event := <-sub.Events():
+		return event
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected an event")
+	}
+	return events.Event{}
+}
+
+// 1. Test In-Memory Evict and Expire Events
+func TestEventsInMemory(t *testing.T) {
+	cache := in_memory.NewLRUCache(2, 60)
+	sub := cache.Events().Subscribe("", 16)
+	defer sub.Close()
+
+	cache.Set("a", "1", 10*time.Second)
+	cache.Set("b", "2", 10*time.Second)
+	cache.Set("c", "3", 10*time.Second) //evicts a
+	for _, expected := range []string{"set a", "set b", "evict a", "set c"} {
+		event := nextEvent(t, sub)
+		if got := event.Op + " " + event.Key; got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+	}
+
+	cache.Set("short", "x", time.Second)
+	nextEvent(t, sub) //evicts b
+	nextEvent(t, sub)
+	time.Sleep(2100 * time.Millisecond)
+	cache.Get("short")
+	if event := nextEvent(t, sub); event.Op != events.OpExpire || event.Key != "short" || event.Value != "x" {
+		t.Errorf("Expected expire of short, got %+v", event)
+	}
+
+	cache.Delete("c")
+	cache.DeleteAll()
+	cache.Set("d", "4", 10*time.Second)
+	cache.DeleteAll()
+	for _, expected := range []string{events.OpDelete, events.OpSet, events.OpFlush} {
+		if event := nextEvent(t, sub); event.Op != expected {
+			t.Errorf("Expected %s, got %+v", expected, event)
+		}
+	}
+}
+
+// 2. Test MultiCache Events and Prefix Filtering
+func TestEventsMultiCache(t *testing.T) {
+	cache := multicache.NewMultiCache(in_memory.NewLRUCache(1, 60), nil)
+	sub := cache.Events().Subscribe("user:", 16)
+	defer sub.Close()
+
+	cache.Set("user:1", "alice", 10*time.Second)
+	cache.Set("other", "skipped", 10*time.Second) //evicts user:1 from memory
+	cache.Delete("user:2")                        //not in memory, still reported
+	cache.DeleteAll()
+	for _, expected := range []string{"set user:1", "evict user:1", "delete user:2", "flush "} {
+		event := nextEvent(t, sub)
+		if got := event.Op + " " + event.Key; got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+	}
+}
+
+// 3. Test Server-Sent Events Endpoint
+func TestEventsSSE(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cache := multicache.NewMultiCache(setupTestInMemoryCache(), nil)
+	server := httptest.NewServer(api.SetupUnifiedRoutes(cache))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/_events?prefix=user:")
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Errorf("Expected text/event-stream, got %q", resp.Header.Get("Content-Type"))
+	}
+
+	cache.Set("other", "skipped", 10*time.Second)
+	cache.Set("user:1", "alice", 10*time.Second)
+	cache.Delete("user:1")
+
+	reader := bufio.NewReader(resp.Body)
+	var received []events.Event
+	for len(received) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event stream: %v", err)
+		}
+		if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:"); ok {
+			var event events.Event
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				t.Fatalf("Failed to decode event %q: %v", data, err)
+			}
+			received = append(received, event)
+		}
+	}
+	if received[0].Op != events.OpSet || received[0].Key != "user:1" || received[0].Value != "alice" {
+		t.Errorf("Expected set of user:1, got %+v", received[0])
+	}
+	if received[1].Op != events.OpDelete || received[1].Key != "user:1" {
+		t.Errorf("Expected delete of user:1, got %+v", received[1])
+	}
+}
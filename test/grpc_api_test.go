@@ -0,0 +1,200 @@
+package test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"unified/auth"
+	"unified/grpc_api"
+	"unified/grpc_api/cachepb"
+	"unified/multicache"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// setupGrpcClient serves a memory-only MultiCache over an in-process listener
+func setupGrpcClient(t *testing.T, opts ...grpc.ServerOption) cachepb.CacheClient {
+	cache := multicache.NewMultiCache(setupTestInMemoryCache(), nil)
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc_api.NewServer(cache, opts...)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial bufnet: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return cachepb.NewCacheClient(conn)
+}
+
+func setRequest(key string, value interface{}) *cachepb.SetRequest {
+	v, _ := structpb.NewValue(value)
+	return &cachepb.SetRequest{Key: key, Value: v, Ttl: durationpb.New(10 * time.Second)}
+}
+
+// 1. Test Get, Set, Delete, GetAll, DeleteAll
+func TestGrpcCrud(t *testing.T) {
+	client := setupGrpcClient(t)
+
+	if _, err := client.Set(ctx, setRequest("key1", "value1")); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+	resp, err := client.Get(ctx, &cachepb.GetRequest{Key: "key1"})
+	if err != nil || resp.Value.GetStringValue() != "value1" {
+		t.Errorf("Expected 'value1', got %v, %v", resp.GetValue(), err)
+	}
+	if _, err := client.Get(ctx, &cachepb.GetRequest{Key: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+	forever := setRequest("key2", "value2")
+	forever.Ttl = nil
+	if _, err := client.Set(ctx, forever); err != nil {
+		t.Errorf("Expected a missing ttl to store a key that never expires, got %v", err)
+	}
+	negative := setRequest("key2", "value2")
+	negative.Ttl = durationpb.New(-time.Second)
+	if _, err := client.Set(ctx, negative); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a negative ttl, got %v", err)
+	}
+
+	client.Set(ctx, setRequest("key2", map[string]interface{}{"n": 1.5}))
+	all, err := client.GetAll(ctx, &cachepb.GetAllRequest{})
+	if err != nil || len(all.Values) != 2 || all.Values["key2"].GetStructValue().Fields["n"].GetNumberValue() != 1.5 {
+		t.Errorf("Expected 2 values including a structured one, got %v, %v", all.GetValues(), err)
+	}
+
+	if _, err := client.Delete(ctx, &cachepb.DeleteRequest{Key: "key1"}); err != nil {
+		t.Errorf("Failed to delete key: %v", err)
+	}
+	if _, err := client.Get(ctx, &cachepb.GetRequest{Key: "key1"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected key1 to be deleted, got %v", err)
+	}
+	if _, err := client.DeleteAll(ctx, &cachepb.DeleteAllRequest{}); err != nil {
+		t.Errorf("Failed to delete all keys: %v", err)
+	}
+	if all, _ := client.GetAll(ctx, &cachepb.GetAllRequest{}); len(all.GetValues()) != 0 {
+		t.Errorf("Expected an empty cache, got %v", all.GetValues())
+	}
+}
+
+// 2. Test Batch Operations
+func TestGrpcBatch(t *testing.T) {
+	client := setupGrpcClient(t)
+
+	set, err := client.BatchSet(ctx, &cachepb.BatchSetRequest{Items: []*cachepb.SetRequest{
+		setRequest("a", "1"), setRequest("b", "2"), setRequest("c", "3"),
+	}})
+	if err != nil || set.Stored != 3 {
+		t.Fatalf("Expected 3 keys stored, got %v, %v", set.GetStored(), err)
+	}
+	if _, err := client.BatchDelete(ctx, &cachepb.BatchDeleteRequest{Keys: []string{"b"}}); err != nil {
+		t.Errorf("Failed to batch delete: %v", err)
+	}
+	get, err := client.BatchGet(ctx, &cachepb.BatchGetRequest{Keys: []string{"a", "b", "c"}})
+	if err != nil || len(get.Values) != 2 || len(get.Missing) != 1 || get.Missing[0] != "b" {
+		t.Errorf("Expected a and c found and b missing, got %v %v, %v", get.GetValues(), get.GetMissing(), err)
+	}
+}
+
+// 3. Test Streaming Watch
+func TestGrpcWatch(t *testing.T) {
+	client := setupGrpcClient(t)
+	watchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(watchCtx, &cachepb.WatchRequest{Prefix: "user:"})
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	// the watch is registered once the server handler runs, retry the first write until it is seen
+	first := make(chan *cachepb.WatchEvent, 1)
+	go func() {
+		event, err := stream.Recv()
+		if err == nil {
+			first <- event
+		}
+	}()
+	var event *cachepb.WatchEvent
+	for event == nil {
+		client.Set(ctx, setRequest("user:1", "alice"))
+		select {
+		case event = <-first:
+		case <-time.After(50 * time.Millisecond):
+		case <-watchCtx.Done():
+			t.Fatalf("Expected a watch event")
+		}
+	}
+	if event.Op != cachepb.WatchEvent_OP_SET || event.Key != "user:1" || event.Value.GetStringValue() != "alice" {
+		t.Errorf("Expected set of user:1, got %v", event)
+	}
+
+	client.Set(ctx, setRequest("other", "skipped")) //filtered by prefix
+	client.Delete(ctx, &cachepb.DeleteRequest{Key: "user:1"})
+	client.DeleteAll(ctx, &cachepb.DeleteAllRequest{})
+
+	expected := []cachepb.WatchEvent_Op{cachepb.WatchEvent_OP_DELETE, cachepb.WatchEvent_OP_FLUSH}
+	for i := 0; i < len(expected); {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Failed to receive event: %v", err)
+		}
+		if event.Op == cachepb.WatchEvent_OP_SET && event.Key == "user:1" { //extra retries above
+			continue
+		}
+		if event.Op != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], event)
+		}
+		i++
+	}
+}
+
+// 4. Test Authentication Interceptors
+func TestGrpcAuth(t *testing.T) {
+	authenticator, err := auth.Config{
+		APIKeys: []auth.KeyConfig{
+			{Name: "billing", Key: "k-billing", Role: "write", Prefixes: []string{"invoice:"}},
+		},
+	}.Authenticator()
+	if err != nil {
+		t.Fatalf("Failed to build authenticator: %v", err)
+	}
+	client := setupGrpcClient(t,
+		grpc.ChainUnaryInterceptor(grpc_api.UnaryAuth(authenticator)),
+		grpc.ChainStreamInterceptor(grpc_api.StreamAuth(authenticator)))
+	billing := metadata.AppendToOutgoingContext(ctx, auth.APIKeyHeader, "k-billing")
+
+	if _, err := client.Set(ctx, setRequest("invoice:1", "paid")); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without credentials, got %v", err)
+	}
+	if _, err := client.Set(billing, setRequest("invoice:1", "paid")); err != nil {
+		t.Errorf("Expected billing to write invoice:1, got %v", err)
+	}
+	if _, err := client.Set(billing, setRequest("user:1", "alice")); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied outside invoice:, got %v", err)
+	}
+	if _, err := client.DeleteAll(billing, &cachepb.DeleteAllRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied on delete all, got %v", err)
+	}
+
+	stream, err := client.Watch(billing, &cachepb.WatchRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied watching every key, got %v", err)
+	}
+}
@@ -1,7 +1,11 @@
 package test
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 	inmemory "unified/in_memory"
@@ -93,13 +97,14 @@ func TestInMemoryCache(t *testing.T) {
 			t.Errorf("Expected value 'new_value2', got %v", value)
 		}
 	})
-	//9. IMMEDIATE EXPIRATION
-	t.Run("Set with immediate expiration", func(t *testing.T) {
+	//9. NO EXPIRATION
+	t.Run("Set without expiration", func(t *testing.T) {
 		cache.Set("key5", "value5", 0)
 		_, ok := cache.Get("key5")
-		if ok {
-			t.Errorf("Expected key5 to expire immediately and not exist in cache")
+		if !ok {
+			t.Errorf("Expected key5 to never expire")
 		}
+		cache.Delete("key5")
 	})
 	//10. GETALL AFTER EXPIRATION
 	t.Run("GetAll after some keys expire", func(t *testing.T) {
@@ -180,3 +185,233 @@ func TestInMemoryCache(t *testing.T) {
 		}
 	})
 }
+
+func TestInMemoryNegativeCache(t *testing.T) {
+	cache := inmemory.NewLRUCache(3, 60)
+	cache.EnableNegativeCache(2, 100*time.Millisecond)
+
+	//1. MISSING KEY REMEMBERED
+	t.Run("SetMissing and IsMissing", func(t *testing.T) {
+		cache.SetMissing("missing1")
+		if !cache.IsMissing("missing1") {
+			t.Errorf("Expected missing1 to be cached as missing")
+		}
+		if cache.IsMissing("other") {
+			t.Errorf("Expected other not to be cached as missing")
+		}
+	})
+	//2. SET CLEARS NEGATIVE ENTRY
+	t.Run("Set invalidates negative entry", func(t *testing.T) {
+		cache.Set("missing1", "value1", 10*time.Second)
+		if cache.IsMissing("missing1") {
+			t.Errorf("Expected Set to clear the negative entry")
+		}
+	})
+	//3. NEGATIVE ENTRIES EXPIRE
+	t.Run("Negative entry expires", func(t *testing.T) {
+		cache.SetMissing("missing2")
+		time.Sleep(150 * time.Millisecond)
+		if cache.IsMissing("missing2") {
+			t.Errorf("Expected negative entry to expire")
+		}
+	})
+	//4. OWN CAPACITY
+	t.Run("Negative entries do not evict values", func(t *testing.T) {
+		cache.Set("key1", "value1", 10*time.Second)
+		cache.Set("key2", "value2", 10*time.Second)
+		for i := 0; i < 5; i++ {
+			cache.SetMissing("missing" + strconv.Itoa(i))
+		}
+		stats := cache.Stats()
+		if stats.NegativeEntries != 2 {
+			t.Errorf("Expected negative entries capped at 2, got %d", stats.NegativeEntries)
+		}
+		if _, ok := cache.Get("key1"); !ok {
+			t.Errorf("Expected key1 to survive negative entries")
+		}
+	})
+	//5. STATS
+	t.Run("Negative hits counted separately", func(t *testing.T) {
+		before := cache.Stats()
+		cache.IsMissing("missing4")
+		cache.Get("key2")
+		after := cache.Stats()
+		if after.NegativeHits != before.NegativeHits+1 {
+			t.Errorf("Expected one more negative hit, got %d -> %d", before.NegativeHits, after.NegativeHits)
+		}
+		if after.Hits != before.Hits+1 {
+			t.Errorf("Expected one more hit, got %d -> %d", before.Hits, after.Hits)
+		}
+	})
+}
+
+func TestInMemorySnapshot(t *testing.T) {
+	cache := inmemory.NewLRUCache(5, 60)
+	cache.Set("key1", "value1", 10*time.Second)
+	cache.Set("key2", "value2", 20*time.Second)
+	cache.Set("key3", "value3", 30*time.Second)
+	cache.Get("key1") // order is now key1, key3, key2
+
+	var buf bytes.Buffer
+	if err := cache.Snapshot(&buf); err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+
+	//1. ROUND TRIP
+	t.Run("Restore keeps values, ttls and order", func(t *testing.T) {
+		restored := inmemory.NewLRUCache(5, 60)
+		n, err := restored.Restore(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("Failed to restore snapshot: %v", err)
+		}
+		if n != 3 {
+			t.Errorf("Expected 3 keys restored, got %d", n)
+		}
+		expected := []string{"key1", "key3", "key2"}
+		entries := restored.Entries()
+		for i, key := range expected {
+			if entries[i].Key != key {
+				t.Errorf("Expected key %s at position %d, got %s", key, i, entries[i].Key)
+			}
+		}
+		ttl, ok := restored.TTL("key3")
+		if !ok || ttl < 28*time.Second || ttl > 30*time.Second {
+			t.Errorf("Expected key3 ttl close to 30s, got %v", ttl)
+		}
+		if value, _ := restored.Get("key2"); value != "value2" {
+			t.Errorf("Expected 'value2', got %v", value)
+		}
+	})
+	//2. UNKNOWN VERSION
+	t.Run("Restore rejects unknown version", func(t *testing.T) {
+		restored := inmemory.NewLRUCache(5, 60)
+		_, err := restored.Restore(strings.NewReader(`{"version":99}` + "\n"))
+		if err == nil {
+			t.Errorf("Expected error for unsupported snapshot version")
+		}
+	})
+	//3. FILE ROUND TRIP
+	t.Run("SaveSnapshot and LoadSnapshot", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.snapshot")
+		if err := cache.SaveSnapshot(path); err != nil {
+			t.Fatalf("Failed to save snapshot: %v", err)
+		}
+		restored := inmemory.NewLRUCache(5, 60)
+		n, err := restored.LoadSnapshot(path)
+		if err != nil || n != 3 {
+			t.Errorf("Expected 3 keys loaded, got %d (%v)", n, err)
+		}
+	})
+}
+
+func TestInMemoryAppendLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.aof")
+
+	// reopen replays the log into a fresh cache
+	reopen := func(t *testing.T) *inmemory.LRUCache {
+		cache := inmemory.NewLRUCache(5, 60)
+		if _, err := cache.OpenAppendLog(path, inmemory.FsyncAlways, 0); err != nil {
+			t.Fatalf("Failed to open append log: %v", err)
+		}
+		return cache
+	}
+
+	//1. REPLAY SET AND DELETE
+	t.Run("Replay Set and Delete", func(t *testing.T) {
+		cache := reopen(t)
+		cache.Set("key1", "value1", 10*time.Second)
+		cache.Set("key2", "value2", 10*time.Second)
+		cache.Set("key3", "value3", 10*time.Second)
+		cache.Delete("key2")
+		if err := cache.CloseAppendLog(); err != nil {
+			t.Fatalf("Failed to close append log: %v", err)
+		}
+
+		cache = reopen(t)
+		defer cache.CloseAppendLog()
+		if value, ok := cache.Get("key1"); !ok || value != "value1" {
+			t.Errorf("Expected key1 to be replayed, got %v", value)
+		}
+		if _, ok := cache.Get("key2"); ok {
+			t.Errorf("Expected key2 to stay deleted after replay")
+		}
+	})
+	//2. REPLAY DELETE ALL
+	t.Run("Replay DeleteAll", func(t *testing.T) {
+		cache := reopen(t)
+		cache.DeleteAll()
+		cache.Set("key4", "value4", 10*time.Second)
+		cache.CloseAppendLog()
+
+		cache = reopen(t)
+		defer cache.CloseAppendLog()
+		if items := cache.GetAll(); len(items) != 1 || items["key4"] != "value4" {
+			t.Errorf("Expected only key4 after replay, got %v", items)
+		}
+	})
+	//3. COMPACTION
+	t.Run("Compaction keeps contents and shrinks log", func(t *testing.T) {
+		cache := reopen(t)
+		for i := 0; i < 100; i++ {
+			cache.Set("key5", "value"+strconv.Itoa(i), 10*time.Second)
+		}
+		before, _ := os.Stat(path)
+		if err := cache.CompactAppendLog(); err != nil {
+			t.Fatalf("Failed to compact: %v", err)
+		}
+		after, _ := os.Stat(path)
+		if after.Size() >= before.Size() {
+			t.Errorf("Expected log to shrink, %d -> %d bytes", before.Size(), after.Size())
+		}
+		cache.Set("key6", "value6", 10*time.Second) // written after compaction
+		cache.CloseAppendLog()
+
+		cache = reopen(t)
+		defer cache.CloseAppendLog()
+		if value, _ := cache.Get("key5"); value != "value99" {
+			t.Errorf("Expected 'value99' after compaction, got %v", value)
+		}
+		if _, ok := cache.Get("key6"); !ok {
+			t.Errorf("Expected key6 written after compaction to be replayed")
+		}
+	})
+	//4. WRITES DURING COMPACTION
+	t.Run("Writes during compaction are kept", func(t *testing.T) {
+		cache := reopen(t)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 200; i++ {
+				cache.Set("key7", "value"+strconv.Itoa(i), 10*time.Second)
+			}
+		}()
+		for i := 0; i < 20; i++ {
+			if err := cache.CompactAppendLog(); err != nil {
+				t.Fatalf("Failed to compact: %v", err)
+			}
+		}
+		<-done
+		cache.CloseAppendLog()
+
+		cache = reopen(t)
+		defer cache.CloseAppendLog()
+		if value, _ := cache.Get("key7"); value != "value199" {
+			t.Errorf("Expected the last write during compaction, got %v", value)
+		}
+	})
+	//5. TORN WRITE
+	t.Run("Partial last record is dropped", func(t *testing.T) {
+		file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		file.WriteString(`{"op":"set","key":"torn"`)
+		file.Close()
+
+		cache := reopen(t)
+		defer cache.CloseAppendLog()
+		if _, ok := cache.Get("torn"); ok {
+			t.Errorf("Expected partial record to be ignored")
+		}
+		if _, ok := cache.Get("key4"); !ok {
+			t.Errorf("Expected earlier records to be replayed")
+		}
+	})
+}
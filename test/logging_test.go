@@ -0,0 +1,227 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	api "unified/api_handler"
+	"unified/auth"
+	"unified/cache_store"
+	"unified/client"
+	"unified/grpc_api"
+	"unified/grpc_api/cachepb"
+	"unified/in_memory"
+	"unified/logging"
+	"unified/memcache_server"
+	"unified/multicache"
+	"unified/resp_server"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// records decodes one JSON log record per line
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Invalid log line %q: %v", line, err)
+		}
+		out = append(out, record)
+	}
+	return out
+}
+
+// 1. Test Request IDs in Responses and Logs
+func TestLoggingRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	logger, _ := logging.New(&buf, "info", "json")
+	multiCache := multicache.NewMultiCache(setupTestInMemoryCache(), nil)
+	server := httptest.NewServer(api.SetupUnifiedRoutes(multiCache, logging.Middleware(logger)))
+	defer server.Close()
+
+	resp, _ := http.Get(server.URL + "/cache/missing")
+	resp.Body.Close()
+	generated := resp.Header.Get(logging.RequestIDHeader)
+	if generated == "" {
+		t.Errorf("Expected a generated request id")
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/health", nil)
+	req.Header.Set(logging.RequestIDHeader, "abc-123")
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if id := resp.Header.Get(logging.RequestIDHeader); id != "abc-123" {
+		t.Errorf("Expected the client's request id to be kept, got %q", id)
+	}
+
+	logged := records(t, &buf)
+	if len(logged) != 2 {
+		t.Fatalf("Expected 2 request logs, got %d", len(logged))
+	}
+	if logged[0]["request_id"] != generated || logged[0]["route"] != "/cache/:key" || logged[0]["status"] != float64(404) {
+		t.Errorf("Expected the 404 with its request id, got %v", logged[0])
+	}
+	if logged[1]["request_id"] != "abc-123" {
+		t.Errorf("Expected request id abc-123, got %v", logged[1])
+	}
+}
+
+// 2. Test Audit Records of Mutating Requests
+func TestLoggingAudit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	auditLogger, _ := logging.New(&buf, "info", "json")
+	authenticator, _ := auth.Config{APIKeys: []auth.KeyConfig{
+		{Name: "billing", Key: "k-billing", Role: "write", Prefixes: []string{"invoice:"}},
+	}}.Authenticator()
+	multiCache := multicache.NewMultiCache(setupTestInMemoryCache(), nil)
+	server := httptest.NewServer(api.SetupUnifiedRoutes(multiCache,
+		logging.Middleware(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))),
+		logging.Audit(auditLogger, api.AuditAction),
+		auth.Middleware(authenticator, api.Access)))
+	defer server.Close()
+
+	billing := client.New(server.URL, 2*time.Second)
+	billing.SetAPIKey("k-billing")
+	billing.Cache().Set(ctx, "invoice:1", "a", 10*time.Second)
+	billing.Cache().Get(ctx, "invoice:1")
+	billing.Cache().Delete(ctx, "user:1")
+	billing.Cache().DeleteAll(ctx)
+
+	expected := []struct {
+		action, key string
+		status      float64
+	}{
+		{"set", "invoice:1", 200},
+		{"delete", "user:1", 403},
+		{"flush", "", 403},
+	}
+	logged := records(t, &buf)
+	if len(logged) != len(expected) {
+		t.Fatalf("Expected %d audit records, got %d: %v", len(expected), len(logged), logged)
+	}
+	for i, want := range expected {
+		got := logged[i]
+		if got["who"] != "billing" || got["action"] != want.action || got["key"] != want.key || got["status"] != want.status || got["request_id"] == "" {
+			t.Errorf("Expected billing %s %q with %v, got %v", want.action, want.key, want.status, got)
+		}
+	}
+}
+
+// 3. Test Debug Logs of the In-Memory Cache
+func TestLoggingCacheDebug(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := logging.New(&buf, "debug", "json")
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	cache := setupTestInMemoryCache()
+	cache.Set("key", "secret", 0)
+	cache.Get("key")
+	cache.Delete("key")
+
+	var messages []string
+	for _, record := range records(t, &buf) {
+		if record["key"] == "key" {
+			messages = append(messages, record["msg"].(string))
+		}
+	}
+	if strings.Join(messages, ",") != "set key,get key,deleted key" {
+		t.Errorf("Expected set, get and delete debug logs, got %v", messages)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("Expected values to be left out of debug logs")
+	}
+}
+
+// 4. Test Audit Records of the gRPC, Redis and Memcached Protocols
+func TestLoggingAuditProtocols(t *testing.T) {
+	var buf bytes.Buffer
+	auditLogger, _ := logging.New(&buf, "info", "json")
+	auditor := logging.NewAuditor(auditLogger)
+
+	authenticator, _ := auth.Config{APIKeys: []auth.KeyConfig{
+		{Name: "billing", Key: "k-billing", Role: "write", Prefixes: []string{"invoice:"}},
+	}}.Authenticator()
+	grpcClient := setupGrpcClient(t, grpc.ChainUnaryInterceptor(grpc_api.UnaryAudit(auditor), grpc_api.UnaryAuth(authenticator)))
+	billing := metadata.AppendToOutgoingContext(ctx, "x-api-key", "k-billing")
+	grpcClient.Set(billing, setRequest("invoice:1", "a"))
+	grpcClient.Get(billing, &cachepb.GetRequest{Key: "invoice:1"})
+	grpcClient.Delete(billing, &cachepb.DeleteRequest{Key: "user:1"})
+
+	cache := in_memory.NewLRUCache(10, 60)
+	respServer := resp_server.NewServer(cache_store.InMemoryStore(cache), time.Minute)
+	respServer.SetAuditor(auditor)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go respServer.Serve(listener)
+	defer respServer.Close()
+	respClient := redis.NewClient(&redis.Options{Addr: listener.Addr().String()})
+	defer respClient.Close()
+	respClient.Set(ctx, "key1", "a", 0)
+	respClient.Get(ctx, "key1")
+	respClient.Del(ctx, "key1", "key2")
+
+	memcacheServer := memcache_server.NewServer(cache_store.InMemoryStore(cache), time.Minute)
+	memcacheServer.SetAuditor(auditor)
+	listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go memcacheServer.Serve(listener)
+	defer memcacheServer.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	memcache := &memcacheConn{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	memcache.send("set key3 0 0 1\r\nb\r\n", "STORED")
+	memcache.send("get key3\r\n", "VALUE key3 0 1", "b", "END")
+	memcache.send("incr key3 1\r\n", "CLIENT_ERROR cannot increment or decrement non-numeric value")
+	memcache.send("flush_all\r\n", "OK")
+
+	expected := []struct {
+		protocol, who, action, key, command string
+		failed                              bool
+	}{
+		{"grpc", "billing", "set", "invoice:1", "/cache.v1.Cache/Set", false},
+		{"grpc", "billing", "delete", "user:1", "/cache.v1.Cache/Delete", true},
+		{"resp", "anonymous", "set", "key1", "SET", false},
+		{"resp", "anonymous", "delete", "key1", "DEL", false},
+		{"resp", "anonymous", "delete", "key2", "DEL", false},
+		{"memcache", "anonymous", "set", "key3", "set", false},
+		{"memcache", "anonymous", "set", "key3", "incr", true},
+		{"memcache", "anonymous", "flush", "", "flush_all", false},
+	}
+	logged := records(t, &buf)
+	if len(logged) != len(expected) {
+		t.Fatalf("Expected %d audit records, got %d: %v", len(expected), len(logged), logged)
+	}
+	for i, want := range expected {
+		got := logged[i]
+		client := want.protocol == "grpc" || got["client_ip"] == "127.0.0.1" //bufconn has no ip
+		if got["protocol"] != want.protocol || got["who"] != want.who || got["action"] != want.action || got["key"] != want.key ||
+			got["command"] != want.command || (got["error"] != "") != want.failed || !client {
+			t.Errorf("Expected %s %s %s %q by %s (failed %v), got %v", want.protocol, want.command, want.action, want.key, want.who, want.failed, got)
+		}
+	}
+}
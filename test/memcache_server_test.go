@@ -0,0 +1,158 @@
+package test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"unified/cache_store"
+	"unified/in_memory"
+	"unified/memcache_server"
+)
+
+type memcacheConn struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func setupMemcacheServer(t *testing.T) (*in_memory.LRUCache, *memcacheConn) {
+	cache := in_memory.NewLRUCache(10, 60)
+	server := memcache_server.NewServer(cache_store.InMemoryStore(cache), time.Minute)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return cache, &memcacheConn{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// send writes a request and checks the reply lines
+func (c *memcacheConn) send(request string, expected ...string) []string {
+	c.t.Helper()
+	c.conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := c.conn.Write([]byte(request)); err != nil {
+		c.t.Fatalf("Failed to write %q: %v", request, err)
+	}
+	lines := make([]string, 0, len(expected))
+	for _, want := range expected {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("Failed to read reply to %q: %v", request, err)
+		}
+		line = strings.TrimSuffix(line, "\r\n")
+		if want != "*" && line != want {
+			c.t.Errorf("Expected %q in reply to %q, got %q", want, strings.TrimSpace(request), line)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// 1. Test Storage Commands
+func TestMemcacheStorage(t *testing.T) {
+	cache, c := setupMemcacheServer(t)
+
+	c.send("set key1 5 0 6\r\nvalue1\r\n", "STORED")
+	if value, ok := cache.Get("key1"); !ok || value != "value1" {
+		t.Errorf("Expected 'value1' in the in-memory cache, got %v", value)
+	}
+	c.send("get key1 missing\r\n", "VALUE key1 5 6", "value1", "END")
+
+	c.send("add key1 0 0 1\r\nx\r\n", "NOT_STORED")
+	c.send("add key2 0 0 1\r\nx\r\n", "STORED")
+	c.send("replace missing 0 0 1\r\nx\r\n", "NOT_STORED")
+	c.send("replace key2 0 0 1\r\ny\r\n", "STORED")
+	c.send("append key2 0 0 1\r\nz\r\n", "STORED")
+	c.send("prepend key2 0 0 1\r\nx\r\n", "STORED")
+	c.send("get key2\r\n", "VALUE key2 0 3", "xyz", "END")
+
+	c.send("delete key2\r\n", "DELETED")
+	c.send("delete key2\r\n", "NOT_FOUND")
+	c.send("set key3 0 0 1 noreply\r\n1\r\n")
+	c.send("flush_all\r\n", "OK")
+	c.send("get key1 key3\r\n", "END")
+	c.send("bogus\r\n", "ERROR")
+}
+
+// 2. Test CAS Tokens
+func TestMemcacheCas(t *testing.T) {
+	cache, c := setupMemcacheServer(t)
+
+	c.send("set key1 0 0 2\r\nv1\r\n", "STORED")
+	lines := c.send("gets key1\r\n", "*", "v1", "END")
+	fields := strings.Fields(lines[0])
+	if len(fields) != 5 {
+		t.Fatalf("Expected VALUE line with a cas token, got %q", lines[0])
+	}
+	token := fields[4]
+
+	c.send("cas key1 0 0 2 "+token+"\r\nv2\r\n", "STORED")
+	c.send("cas key1 0 0 2 "+token+"\r\nv3\r\n", "EXISTS")
+	c.send("cas missing 0 0 2 "+token+"\r\nv3\r\n", "NOT_FOUND")
+
+	// writes through other APIs invalidate the token too
+	lines = c.send("gets key1\r\n", "*", "v2", "END")
+	token = strings.Fields(lines[0])[4]
+	cache.Set("key1", "changed", time.Minute)
+	c.send("cas key1 0 0 2 "+token+"\r\nv4\r\n", "EXISTS")
+
+	// writing the same value back still changes the token
+	lines = c.send("gets key1\r\n", "*", "changed", "END")
+	token = strings.Fields(lines[0])[4]
+	c.send("set key1 0 0 1\r\nA\r\n", "STORED")
+	c.send("set key1 0 0 7\r\nchanged\r\n", "STORED")
+	c.send("cas key1 0 0 2 "+token+"\r\nv5\r\n", "EXISTS")
+}
+
+// 3. Test Incr, Decr and Touch
+func TestMemcacheIncrDecrTouch(t *testing.T) {
+	cache, c := setupMemcacheServer(t)
+
+	c.send("set counter 0 30 2\r\n10\r\n", "STORED")
+	c.send("incr counter 5\r\n", "15")
+	c.send("decr counter 20\r\n", "0")
+	c.send("incr missing 1\r\n", "NOT_FOUND")
+	c.send("set text 0 0 3\r\nabc\r\n", "STORED")
+	c.send("incr text 1\r\n", "CLIENT_ERROR cannot increment or decrement non-numeric value")
+
+	if ttl, ok := cache.TTL("counter"); !ok || ttl > 30*time.Second {
+		t.Errorf("Expected incr to keep the 30s ttl, got %v", ttl)
+	}
+	c.send("touch counter 100\r\n", "TOUCHED")
+	if ttl, ok := cache.TTL("counter"); !ok || ttl <= 30*time.Second {
+		t.Errorf("Expected touch to extend the ttl, got %v", ttl)
+	}
+	c.send("touch missing 100\r\n", "NOT_FOUND")
+	c.send("touch counter -1\r\n", "TOUCHED")
+	c.send("get counter\r\n", "END")
+}
+
+// 4. Test Meta Commands
+func TestMemcacheMeta(t *testing.T) {
+	_, c := setupMemcacheServer(t)
+
+	c.send("ms key1 2 T60 F7\r\nv1\r\n", "HD")
+	lines := c.send("mg key1 v f c k Oabc\r\n", "*", "v1")
+	fields := strings.Fields(lines[0])
+	if len(fields) != 6 || fields[0] != "VA" || fields[1] != "2" || fields[2] != "f7" || fields[4] != "kkey1" || fields[5] != "Oabc" {
+		t.Errorf("Expected 'VA 2 f7 c<cas> kkey1 Oabc', got %q", lines[0])
+	}
+	cas := fields[3][1:]
+
+	c.send("ms key1 2 C"+cas+"\r\nv2\r\n", "HD")
+	c.send("ms key1 2 C"+cas+"\r\nv3\r\n", "EX")
+	c.send("ms key1 2 ME\r\nv3\r\n", "NS")
+	c.send("mg missing v\r\n", "EN")
+	c.send("mg missing v q\r\nmn\r\n", "MN")
+	c.send("md key1 q\r\nmd key1\r\n", "NF")
+}
@@ -0,0 +1,121 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	api "unified/api_handler"
+	"unified/client"
+	"unified/in_memory"
+	"unified/metrics"
+	"unified/multicache"
+
+	"github.com/gin-gonic/gin"
+)
+
+func scrape(t *testing.T, url string) string {
+	resp, err := http.Get(url + "/metrics")
+	if err != nil {
+		t.Fatalf("Scrape failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+// 1. Test Tier Stats
+func TestMetricsTierStats(t *testing.T) {
+	multiCache := multicache.NewMultiCache(setupTestInMemoryCache(), setupTestRedisCache())
+	multiCache.Set("key", "value", 10*time.Second)
+	multiCache.Get("key")
+	multiCache.Get("missing")
+
+	tiers := multiCache.Stats().Tiers
+	if len(tiers) != 1 || tiers[0].Tier != "redis" {
+		t.Fatalf("Expected a redis tier, got %+v", tiers)
+	}
+	if tiers[0].Hits != 1 || tiers[0].Misses != 1 || tiers[0].Items != 1 {
+		t.Errorf("Expected 1 hit, 1 miss and 1 item in redis, got %+v", tiers[0])
+	}
+}
+
+// 2. Test the /metrics Endpoint
+func TestMetricsEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serviceMetrics := metrics.New()
+	redisCache := setupTestRedisCache()
+	serviceMetrics.InstrumentRedis(redisCache.Client)
+	multiCache := multicache.NewMultiCache(setupTestInMemoryCache(), redisCache)
+	serviceMetrics.CollectCaches(func() map[string]multicache.Stats {
+		return map[string]multicache.Stats{"": multiCache.Stats()}
+	})
+	r := api.SetupUnifiedRoutes(multiCache, serviceMetrics.Middleware())
+	r.GET("/metrics", gin.WrapH(serviceMetrics.Handler()))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	c := client.New(server.URL, 2*time.Second)
+	c.Cache().Set(ctx, "key", "value", 10*time.Second)
+	c.Cache().Get(ctx, "key")
+	c.Cache().Get(ctx, "missing")
+
+	body := scrape(t, server.URL)
+	for _, want := range []string{
+		`http_request_duration_seconds_count{method="GET",route="/cache/:key",status="200"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/cache/:key",status="404"} 1`,
+		`cache_hits_total{namespace="",tier="memory"} 1`,
+		`cache_misses_total{namespace="",tier="redis"} 1`,
+		`cache_items{namespace="",tier="redis"} 1`,
+		`cache_redis_available{namespace=""} 1`,
+		`redis_command_duration_seconds_count{command="get"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %s in the scrape", want)
+		}
+	}
+}
+
+// 3. Test Bounded Namespace Labels and Cached Redis Sizes
+func TestMetricsNamespaceLabels(t *testing.T) {
+	serviceMetrics := metrics.New()
+	serviceMetrics.CollectCaches(func() map[string]multicache.Stats {
+		stats := make(map[string]multicache.Stats)
+		for i := 0; i < 60; i++ {
+			stats[fmt.Sprintf("ns%02d", i)] = multicache.Stats{
+				Memory: in_memory.Stats{Items: 1, Capacity: 2},
+				Redis:  multicache.Health{Redis: "disabled"},
+			}
+		}
+		return stats
+	})
+	server := httptest.NewServer(serviceMetrics.Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Scrape failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{`cache_capacity{namespace="ns49"} 2`, `cache_items{namespace="(other)",tier="memory"} 10`} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected %s in the scrape", want)
+		}
+	}
+	if strings.Contains(string(body), `namespace="ns50"`) {
+		t.Errorf("Expected namespaces past the first 50 to share a label")
+	}
+
+	redisCache := setupTestRedisCache()
+	multiCache := multicache.NewMultiCache(setupTestInMemoryCache(), redisCache)
+	multiCache.Set("key", "value", 10*time.Second)
+	multiCache.Stats()
+	redisCache.Set("other", "value", 10*time.Second)
+	if items := multiCache.Stats().Tiers[0].Items; items != 1 {
+		t.Errorf("Expected the redis size read by the previous call, got %d", items)
+	}
+}
@@ -1,7 +1,12 @@
 package test
 
 import (
+	"errors"
+	"io"
+	"net"
+	"reflect"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -192,3 +197,385 @@ func TestMultiCache_TTLExpiration(t *testing.T) {
 	// Wait for in-memory cache to evict
 	time.Sleep(60 + 100*time.Millisecond)
 }
+
+func TestMultiCache_CrossInstanceInvalidation(t *testing.T) {
+	inMemoryA := setupTestInMemoryCache()
+	inMemoryB := setupTestInMemoryCache()
+	redisCache := setupTestRedisCache()
+	cacheA := multicache.NewMultiCache(inMemoryA, redisCache)
+	cacheB := multicache.NewMultiCache(inMemoryB, redisCache)
+
+	for _, cache := range []*multicache.MultiCache{cacheA, cacheB} {
+		if err := cache.EnableInvalidation("test_invalidation"); err != nil {
+			t.Fatalf("Failed to enable invalidation: %v", err)
+		}
+		defer cache.DisableInvalidation()
+	}
+
+	// waitEvicted polls the in-memory cache until the key is gone
+	waitEvicted := func(cache *in_memory.LRUCache, key string) bool {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if _, ok := cache.Get(key); !ok {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	// Set through B, then overwrite through A
+	inMemoryA.Set("shared", "older", 10*time.Second)
+	if err := cacheB.Set("shared", "old", 10*time.Second); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+	if !waitEvicted(inMemoryA, "shared") { // B's invalidation reaches A before A writes
+		t.Fatalf("Expected key to be evicted from instance A after Set on B")
+	}
+	if err := cacheA.Set("shared", "new", 10*time.Second); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+	if !waitEvicted(inMemoryB, "shared") {
+		t.Errorf("Expected stale key to be evicted from instance B after Set on A")
+	}
+	if value, ok := inMemoryA.Get("shared"); !ok || value != "new" {
+		t.Errorf("Expected instance A to keep its own write, got %v", value)
+	}
+
+	// Delete through A
+	inMemoryB.Set("shared", "new", 10*time.Second)
+	if err := cacheA.Delete("shared"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if !waitEvicted(inMemoryB, "shared") {
+		t.Errorf("Expected key to be evicted from instance B after Delete on A")
+	}
+
+	// DeleteAll through B
+	inMemoryA.Set("key1", "value1", 10*time.Second)
+	if err := cacheB.DeleteAll(); err != nil {
+		t.Fatalf("Failed to delete all keys: %v", err)
+	}
+	if !waitEvicted(inMemoryA, "key1") {
+		t.Errorf("Expected instance A to be flushed after DeleteAll on B")
+	}
+}
+
+func TestMultiCache_RedisUnavailable(t *testing.T) {
+	inMemoryCache := setupTestInMemoryCache()
+	redisCache := redis_cache.NewCache("localhost:6390", "", 0, 10) // nothing listens here
+	cache := multicache.NewMultiCache(inMemoryCache, redisCache)
+	cache.ConfigureBreaker(1, time.Minute, time.Minute)
+	cache.SetWritePolicy(multicache.WriteQueue, 10)
+
+	// Set keeps working against memory
+	err := cache.Set("key1", "value1", 10*time.Second)
+	if err != nil {
+		t.Fatalf("Expected Set to degrade gracefully, got %v", err)
+	}
+
+	value, err := cache.Get("key1")
+	if err != nil {
+		t.Fatalf("Expected Get to be served from memory, got %v", err)
+	}
+	if value != "value1" {
+		t.Errorf("Expected value 'value1', got %v", value)
+	}
+
+	_, err = cache.Get("missing")
+	if !errors.Is(err, multicache.ErrRedisUnavailable) {
+		t.Errorf("Expected ErrRedisUnavailable for key missing from memory, got %v", err)
+	}
+
+	if err := cache.Delete("key1"); err != nil {
+		t.Errorf("Expected Delete to degrade gracefully, got %v", err)
+	}
+
+	health := cache.Health()
+	if health.Status != "degraded" || health.Redis != "open" {
+		t.Errorf("Expected degraded status with open breaker, got %+v", health)
+	}
+	if health.QueuedWrites != 2 {
+		t.Errorf("Expected 2 queued writes, got %d", health.QueuedWrites)
+	}
+}
+
+// redisProxy forwards to the test redis and can be taken down to simulate an outage
+type redisProxy struct {
+	addr     string
+	listener net.Listener
+	conns    []net.Conn
+	mutex    sync.Mutex
+}
+
+func startRedisProxy(t *testing.T) *redisProxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	proxy := &redisProxy{addr: listener.Addr().String()}
+	proxy.serve(listener)
+	t.Cleanup(proxy.down)
+	return proxy
+}
+
+func (p *redisProxy) serve(listener net.Listener) {
+	p.listener = listener
+	go func() {
+		for {
+			client, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server, err := net.Dial("tcp", "localhost:6379")
+			if err != nil {
+				client.Close()
+				continue
+			}
+			p.mutex.Lock()
+			p.conns = append(p.conns, client, server)
+			p.mutex.Unlock()
+			go io.Copy(server, client)
+			go io.Copy(client, server)
+		}
+	}()
+}
+
+// down refuses new connections and cuts the open ones
+func (p *redisProxy) down() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.listener.Close()
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+}
+
+func (p *redisProxy) up(t *testing.T) {
+	listener, err := net.Listen("tcp", p.addr)
+	if err != nil {
+		t.Fatalf("Failed to listen again: %v", err)
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.serve(listener)
+}
+
+// waitHealthy waits for queued writes to be replayed and the breaker to close
+func waitHealthy(cache *multicache.MultiCache) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cache.Health().Status == "ok" {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestMultiCache_ReplayOrder(t *testing.T) {
+	proxy := startRedisProxy(t)
+	redisCache := setupTestRedisCache()
+	cache := multicache.NewMultiCache(setupTestInMemoryCache(), redis_cache.Connect(proxy.addr, "", 0, 10))
+	cache.ConfigureBreaker(1, 10*time.Millisecond, 10*time.Millisecond)
+	cache.SetWritePolicy(multicache.WriteQueue, 10)
+
+	// A Set queued during the outage must not overwrite a newer Set made during recovery
+	proxy.down()
+	cache.Set("key1", "old", 0)
+	proxy.up(t)
+	time.Sleep(20 * time.Millisecond) //backoff elapses
+	if err := cache.Set("key1", "new", 0); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+	if value, err := cache.Get("key1"); err != nil || value != "new" {
+		t.Errorf("Expected 'new' while replaying, got %v, %v", value, err)
+	}
+	if !waitHealthy(cache) {
+		t.Fatalf("Expected the queue to drain, got %+v", cache.Health())
+	}
+	if value, err := redisCache.Get("key1"); err != nil || value != "new" {
+		t.Errorf("Expected redis to hold 'new', got %v, %v", value, err)
+	}
+
+	// A flush queued during the outage must not wipe keys written during recovery
+	proxy.down()
+	cache.DeleteAll()
+	proxy.up(t)
+	time.Sleep(20 * time.Millisecond)
+	if err := cache.Set("key2", "value2", 0); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+	if !waitHealthy(cache) {
+		t.Fatalf("Expected the queue to drain, got %+v", cache.Health())
+	}
+	if _, err := redisCache.Get("key1"); err == nil {
+		t.Errorf("Expected the queued flush to delete key1")
+	}
+	if value, err := redisCache.Get("key2"); err != nil || value != "value2" {
+		t.Errorf("Expected redis to hold key2 written after the flush, got %v, %v", value, err)
+	}
+}
+
+func TestMultiCache_Reconcile(t *testing.T) {
+	inMemoryCache := setupTestInMemoryCache()
+	redisCache := setupTestRedisCache()
+	cache := multicache.NewMultiCache(inMemoryCache, redisCache)
+
+	for i := 1; i <= 4; i++ {
+		key := "key" + strconv.Itoa(i)
+		if err := cache.Set(key, "value"+strconv.Itoa(i), 10*time.Second); err != nil {
+			t.Fatalf("Failed to set key %s: %v", key, err)
+		}
+	}
+
+	// Diverge the tiers behind MultiCache's back
+	inMemoryCache.Delete("key1")
+	inMemoryCache.Set("key2", "stale", 10*time.Second)
+	redisCache.Delete("key3")
+
+	divergences, err := cache.Diff()
+	if err != nil {
+		t.Fatalf("Failed to diff tiers: %v", err)
+	}
+	expected := []multicache.Divergence{
+		{Key: "key1", Kind: multicache.MissingInMemory, RedisValue: "value1"},
+		{Key: "key2", Kind: multicache.ValueMismatch, MemoryValue: "stale", RedisValue: "value2"},
+		{Key: "key3", Kind: multicache.MissingInRedis, MemoryValue: "value3"},
+	}
+	if !reflect.DeepEqual(divergences, expected) {
+		t.Fatalf("Expected divergences %+v, got %+v", expected, divergences)
+	}
+
+	// GetAll in report mode records but does not repair
+	cache.SetReconcileMode(multicache.ReconcileReport, multicache.TierRedis)
+	if _, err := cache.GetAll(); err != nil {
+		t.Fatalf("Failed to get all values: %v", err)
+	}
+	if len(cache.Divergences()) != 3 {
+		t.Errorf("Expected 3 reported divergences, got %d", len(cache.Divergences()))
+	}
+
+	// GetAll in repair mode re-syncs memory from redis
+	cache.SetReconcileMode(multicache.ReconcileRepair, multicache.TierRedis)
+	if _, err := cache.GetAll(); err != nil {
+		t.Fatalf("Failed to get all values: %v", err)
+	}
+	divergences, err = cache.Diff()
+	if err != nil {
+		t.Fatalf("Failed to diff tiers: %v", err)
+	}
+	if len(divergences) != 0 {
+		t.Errorf("Expected tiers to match after repair, got %+v", divergences)
+	}
+	if value, _ := inMemoryCache.Get("key2"); value != "value2" {
+		t.Errorf("Expected key2 repaired to 'value2', got %v", value)
+	}
+
+	// Reconcile with memory as the authority pushes memory to redis
+	inMemoryCache.Set("key5", "memory-only", 10*time.Second)
+	if _, err := cache.Reconcile(multicache.TierMemory); err != nil {
+		t.Fatalf("Failed to reconcile: %v", err)
+	}
+	value, err := redisCache.Get("key5")
+	if err != nil || value != "memory-only" {
+		t.Errorf("Expected key5 pushed to redis, got %v (%v)", value, err)
+	}
+}
+
+func TestMultiCache_NegativeCache(t *testing.T) {
+	inMemoryCache := setupTestInMemoryCache()
+	inMemoryCache.EnableNegativeCache(10, 10*time.Second)
+	redisCache := setupTestRedisCache()
+	cache := multicache.NewMultiCache(inMemoryCache, redisCache)
+
+	// First lookup goes to redis and records the miss
+	if _, err := cache.Get("ghost"); err == nil {
+		t.Fatalf("Expected error for missing key")
+	}
+	// Create the key behind MultiCache's back, the negative entry still answers
+	redisCache.Set("ghost", "boo", 10*time.Second)
+	if _, err := cache.Get("ghost"); err == nil {
+		t.Errorf("Expected negative cache to answer without hitting redis")
+	}
+	if stats := cache.Stats(); stats.Memory.NegativeHits != 1 {
+		t.Errorf("Expected 1 negative hit, got %d", stats.Memory.NegativeHits)
+	}
+
+	// A Set through MultiCache invalidates the negative entry
+	if err := cache.Set("ghost", "boo", 10*time.Second); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+	value, err := cache.Get("ghost")
+	if err != nil || value != "boo" {
+		t.Errorf("Expected 'boo' after Set, got %v (%v)", value, err)
+	}
+}
+
+func TestMultiCache_DiskTier(t *testing.T) {
+	// 1. disk as L2, no redis
+	t.Run("Disk as L2", func(t *testing.T) {
+		inMemoryCache := setupTestInMemoryCache()
+		diskCache := setupDiskTestCache(t, t.TempDir())
+		defer diskCache.Close()
+		cache := multicache.NewMultiCache(inMemoryCache, nil)
+		cache.AddBackend(diskCache)
+
+		if err := cache.Set("key1", "value1", 10*time.Second); err != nil {
+			t.Fatalf("Failed to set key: %v", err)
+		}
+		inMemoryCache.Delete("key1") // evicted from memory
+		value, err := cache.Get("key1")
+		if err != nil || value != "value1" {
+			t.Errorf("Expected 'value1' from disk, got %v (%v)", value, err)
+		}
+		if _, ok := inMemoryCache.Get("key1"); !ok {
+			t.Errorf("Expected disk hit to be promoted into memory")
+		}
+		if health := cache.Health(); health.Redis != "disabled" {
+			t.Errorf("Expected redis to be reported disabled, got %+v", health)
+		}
+
+		cache.Delete("key1")
+		if _, err := cache.Get("key1"); err == nil {
+			t.Errorf("Expected key1 deleted from all tiers")
+		}
+	})
+	// 2. disk as L3 behind redis
+	t.Run("Disk as L3", func(t *testing.T) {
+		inMemoryCache := setupTestInMemoryCache()
+		redisCache := setupTestRedisCache()
+		diskCache := setupDiskTestCache(t, t.TempDir())
+		defer diskCache.Close()
+		cache := multicache.NewMultiCache(inMemoryCache, redisCache)
+		cache.AddBackend(diskCache)
+
+		if err := cache.Set("key1", "value1", 10*time.Second); err != nil {
+			t.Fatalf("Failed to set key: %v", err)
+		}
+		inMemoryCache.Delete("key1")
+		redisCache.Delete("key1") // evicted from redis
+		value, err := cache.Get("key1")
+		if err != nil || value != "value1" {
+			t.Errorf("Expected 'value1' from disk, got %v (%v)", value, err)
+		}
+	})
+	// 3. a failed disk write fails the Set
+	t.Run("Disk write failure", func(t *testing.T) {
+		diskCache := setupDiskTestCache(t, t.TempDir())
+		cache := multicache.NewMultiCache(setupTestInMemoryCache(), nil)
+		cache.AddBackend(diskCache)
+
+		diskCache.Close() // writes now fail
+		if err := cache.Set("key1", "value1", 10*time.Second); err == nil {
+			t.Errorf("Expected Set to report the disk write error")
+		}
+		if _, err := cache.Expire("key1", time.Second); err != nil {
+			t.Errorf("Expected Expire to skip a key the disk does not hold, got %v", err)
+		}
+		if err := diskCache.Set("key1", make(chan int), 0); err == nil {
+			t.Errorf("Expected an unmarshalable value to be rejected")
+		}
+	})
+}
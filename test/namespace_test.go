@@ -0,0 +1,176 @@
+package test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	api "unified/api_handler"
+	"unified/client"
+	"unified/multicache"
+	"unified/namespace"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 1. Test Namespaced Redis Keys
+func TestNamespaceRedis(t *testing.T) {
+	root := setupTestRedisCache()
+	acme := root.Namespace("acme", 2)
+	globex := root.Namespace("globex", 2)
+
+	acme.Set("key1", "a1", 10*time.Second)
+	globex.Set("key1", "g1", 10*time.Second)
+	root.Set("key1", "r1", 10*time.Second)
+	if value, _ := acme.Get("key1"); value != "a1" {
+		t.Errorf("Expected acme's own value, got %v", value)
+	}
+	if value, _ := globex.Get("key1"); value != "g1" {
+		t.Errorf("Expected globex's own value, got %v", value)
+	}
+
+	acme.Set("key2", "a2", 10*time.Second)
+	acme.Set("key3", "a3", 10*time.Second) //evicts acme's key1 only
+	if _, err := acme.Get("key1"); err == nil {
+		t.Errorf("Expected acme's key1 to be evicted")
+	}
+	if _, err := globex.Get("key1"); err != nil {
+		t.Errorf("Expected globex's key1 to survive acme's eviction, got %v", err)
+	}
+
+	values, _, err := root.Scan("0", "", 100)
+	if err != nil || len(values) != 1 || values["key1"] != "r1" {
+		t.Errorf("Expected the root scan to skip namespaces, got %v, %v", values, err)
+	}
+	values, _, err = acme.Scan("0", "key*", 100)
+	if err != nil || len(values) != 2 || values["key3"] != "a3" {
+		t.Errorf("Expected acme's two keys, got %v, %v", values, err)
+	}
+
+	if err := acme.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll failed: %v", err)
+	}
+	if all, _ := acme.GetAll(); len(all) != 0 {
+		t.Errorf("Expected acme to be empty, got %v", all)
+	}
+	if _, err := globex.Get("key1"); err != nil {
+		t.Errorf("Expected globex to survive acme's flush, got %v", err)
+	}
+}
+
+// 2. Test Namespace Registry
+func TestNamespaceRegistry(t *testing.T) {
+	registry := namespace.NewRegistry(2, nil)
+	registry.SetCapacity("big", 5)
+	registry.SetMax(2)
+
+	big, err := registry.Get("big")
+	if err != nil || big.Capacity != 5 {
+		t.Fatalf("Expected the capacity override, got %v, %v", big, err)
+	}
+	if again, _ := registry.Get("big"); again != big {
+		t.Errorf("Expected the same namespace on each Get")
+	}
+	if _, err := registry.Get("bad:name"); !errors.Is(err, namespace.ErrInvalidName) {
+		t.Errorf("Expected ErrInvalidName, got %v", err)
+	}
+	registry.Get("small")
+	if _, err := registry.Get("third"); !errors.Is(err, namespace.ErrTooMany) {
+		t.Errorf("Expected ErrTooMany past the limit, got %v", err)
+	}
+	if names := registry.Names(); len(names) != 2 || names[0] != "big" || names[1] != "small" {
+		t.Errorf("Expected [big small], got %v", names)
+	}
+}
+
+// 3. Test Namespace Routes
+func TestNamespaceHTTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := namespace.NewRegistry(3, setupTestRedisCache())
+	r := api.SetupUnifiedRoutes(multicache.NewMultiCache(setupTestInMemoryCache(), nil))
+	api.SetupNamespaceRoutes(r, registry)
+	server := httptest.NewServer(r)
+	defer server.Close()
+	c := client.New(server.URL, 2*time.Second)
+	acme, globex := c.Namespace("acme"), c.Namespace("globex")
+
+	globex.Set(ctx, "shared", "g", 10*time.Second)
+	for i := 0; i < 5; i++ { //a noisy tenant
+		acme.Set(ctx, "key"+strconv.Itoa(i), i, 10*time.Second)
+	}
+	if value, err := globex.Get(ctx, "shared"); err != nil || value != "g" {
+		t.Errorf("Expected globex's key to survive acme's writes, got %v, %v", value, err)
+	}
+	if _, err := c.Cache().Get(ctx, "shared"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected namespaced keys to stay out of the root keyspace, got %v", err)
+	}
+
+	stats, err := acme.Stats(ctx)
+	if err != nil || stats["memory"].(map[string]interface{})["items"] != float64(3) {
+		t.Errorf("Expected acme to hold 3 items, got %v, %v", stats, err)
+	}
+	if err := acme.DeleteAll(ctx); err != nil {
+		t.Errorf("Flush failed: %v", err)
+	}
+	if all, _ := acme.GetAll(ctx); len(all) != 0 {
+		t.Errorf("Expected acme to be empty after its flush, got %v", all)
+	}
+	if _, err := globex.Get(ctx, "shared"); err != nil {
+		t.Errorf("Expected globex to survive acme's flush, got %v", err)
+	}
+	if _, err := c.Namespace("bad name").Get(ctx, "key"); !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest for an invalid namespace, got %v", err)
+	}
+}
+
+// 4. Test Root Flush, Lookups and Creation Outside the Lock
+func TestNamespaceLifecycle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rc := setupTestRedisCache()
+	root := multicache.NewMultiCache(setupTestInMemoryCache(), rc)
+	registry := namespace.NewRegistry(3, rc)
+	registry.SetMax(2)
+	var created atomic.Int32
+	registry.OnCreate(func(ns *namespace.Namespace) {
+		registry.Names() //would deadlock if namespaces were created under the registry lock
+		created.Add(1)
+	})
+	r := api.SetupUnifiedRoutes(root)
+	api.SetupNamespaceRoutes(r, registry)
+	server := httptest.NewServer(r)
+	defer server.Close()
+	c := client.New(server.URL, 2*time.Second)
+
+	acme := c.Namespace("acme")
+	acme.Set(ctx, "key", "a", 10*time.Second)
+	c.Cache().Set(ctx, "key", "r", 10*time.Second)
+	if err := c.Cache().DeleteAll(ctx); err != nil {
+		t.Fatalf("Root flush failed: %v", err)
+	}
+	if value, err := acme.Get(ctx, "key"); err != nil || value != "a" {
+		t.Errorf("Expected acme to survive the root flush, got %v, %v", value, err)
+	}
+	if _, err := rc.Namespace("acme", 3).Get("key"); err != nil {
+		t.Errorf("Expected acme's redis key to survive the root flush, got %v", err)
+	}
+
+	for _, name := range []string{"ghost1", "ghost2", "ghost3"} {
+		if _, err := c.Namespace(name).Get(ctx, "key"); !errors.Is(err, client.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound reading unknown namespace %s, got %v", name, err)
+		}
+	}
+	if names := registry.Names(); len(names) != 1 || created.Load() != 1 {
+		t.Errorf("Expected reads not to create namespaces, got %v", names)
+	}
+
+	rc.Namespace("stored", 3).Set("key", "s", 10*time.Second) //e.g. written before a restart
+	if value, err := c.Namespace("stored").Get(ctx, "key"); err != nil || value != "s" {
+		t.Errorf("Expected a namespace with redis keys to be readable, got %v, %v", value, err)
+	}
+	if _, err := registry.Lookup("ghost1"); !errors.Is(err, namespace.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound from Lookup, got %v", err)
+	}
+}
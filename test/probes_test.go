@@ -0,0 +1,89 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	api "unified/api_handler"
+	"unified/health"
+	"unified/multicache"
+	"unified/redis_cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+func probe(t *testing.T, url string) (int, map[string]interface{}) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func probeServer(readiness *health.Readiness, multiCache *multicache.MultiCache) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	r := api.SetupUnifiedRoutes(multiCache)
+	api.SetupProbeRoutes(r, readiness, multiCache)
+	return httptest.NewServer(r)
+}
+
+// 1. Test Readiness During and After Startup
+func TestProbesStartup(t *testing.T) {
+	redisCache := setupTestRedisCache()
+	readiness := health.NewReadiness()
+	readiness.AddCheck("redis", func(ctx context.Context) error {
+		return redisCache.WithContext(ctx).Ping()
+	})
+	server := probeServer(readiness, multicache.NewMultiCache(setupTestInMemoryCache(), redisCache))
+	defer server.Close()
+
+	if status, _ := probe(t, server.URL+"/healthz"); status != http.StatusOK {
+		t.Errorf("Expected /healthz 200 while starting, got %d", status)
+	}
+	status, body := probe(t, server.URL+"/readyz")
+	checks, _ := body["checks"].(map[string]interface{})
+	if status != http.StatusServiceUnavailable || checks["startup"] != "starting" || checks["redis"] != "ok" {
+		t.Errorf("Expected /readyz 503 while starting, got %d %v", status, body)
+	}
+
+	readiness.MarkStarted()
+	if status, body := probe(t, server.URL+"/readyz"); status != http.StatusOK || body["ready"] != true {
+		t.Errorf("Expected /readyz 200 once started, got %d %v", status, body)
+	}
+}
+
+// 2. Test Readiness and Status Without Redis
+func TestProbesRedisDown(t *testing.T) {
+	redisCache := redis_cache.Connect("127.0.0.1:1", "", 0, 10) //nothing listens
+	if err := redisCache.Ping(); err == nil {
+		t.Fatalf("Expected Ping to fail")
+	}
+	readiness := health.NewReadiness()
+	readiness.AddCheck("redis", func(ctx context.Context) error {
+		return redisCache.WithContext(ctx).Ping()
+	})
+	readiness.MarkStarted()
+	multiCache := multicache.NewMultiCache(setupTestInMemoryCache(), redisCache)
+	server := probeServer(readiness, multiCache)
+	defer server.Close()
+
+	status, body := probe(t, server.URL+"/readyz")
+	checks, _ := body["checks"].(map[string]interface{})
+	if status != http.StatusServiceUnavailable || checks["redis"] == "ok" {
+		t.Errorf("Expected /readyz 503 with the redis error, got %d %v", status, body)
+	}
+	if status, _ := probe(t, server.URL+"/healthz"); status != http.StatusOK {
+		t.Errorf("Expected /healthz 200 without redis, got %d", status)
+	}
+	status, body = probe(t, server.URL+"/status")
+	tiers, _ := body["tiers"].([]interface{})
+	if status != http.StatusOK || body["ready"] != false || body["memory"] == nil || len(tiers) != 1 {
+		t.Errorf("Expected /status with memory and redis tiers, got %d %v", status, body)
+	}
+}
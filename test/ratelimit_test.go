@@ -0,0 +1,147 @@
+package test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	api "unified/api_handler"
+	"unified/auth"
+	"unified/client"
+	"unified/multicache"
+	"unified/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// 1. Test Rule Parsing
+func TestRateLimitRules(t *testing.T) {
+	rules, err := ratelimit.ParseRules("cache=100:200, *=0.5:1")
+	if err != nil || rules["cache"] != (ratelimit.Rule{Rate: 100, Burst: 200}) || rules["*"] != (ratelimit.Rule{Rate: 0.5, Burst: 1}) {
+		t.Errorf("Expected cache and * rules, got %v, %v", rules, err)
+	}
+	for _, invalid := range []string{"cache", "cache=10", "cache=0:1", "cache=1:0", "=1:1"} {
+		if _, err := ratelimit.ParseRules(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+// 2. Test Local and Redis Token Buckets
+func TestRateLimitBuckets(t *testing.T) {
+	redisCache := setupTestRedisCache()
+	rule := ratelimit.Rule{Rate: 1, Burst: 2}
+	//two instances sharing redis draw from the same bucket
+	shared := []ratelimit.Limiter{ratelimit.NewRedis(redisCache.Client, rule), ratelimit.NewRedis(redisCache.Client, rule)}
+	limiters := map[string][]ratelimit.Limiter{"local": {ratelimit.NewLocal(rule)}, "redis": shared}
+	for name, instances := range limiters {
+		for i := 0; i < 2; i++ {
+			if allowed, _, err := instances[i%len(instances)].Allow("alice"); !allowed || err != nil {
+				t.Errorf("%s: expected request %d within the burst, got %v, %v", name, i, allowed, err)
+			}
+		}
+		allowed, retryAfter, _ := instances[len(instances)-1].Allow("alice")
+		if allowed || retryAfter <= 0 || retryAfter > time.Second {
+			t.Errorf("%s: expected the third request to wait up to a second, got %v, %v", name, allowed, retryAfter)
+		}
+		if allowed, _, _ := instances[0].Allow("bob"); !allowed {
+			t.Errorf("%s: expected bob to get a separate bucket", name)
+		}
+	}
+
+	//buckets are not cache items, flushing the root cache keeps them
+	if values, _, err := redisCache.Scan("0", "", 100); err != nil || len(values) != 0 {
+		t.Errorf("Expected the root scan to skip rate limit buckets, got %v, %v", values, err)
+	}
+	if err := redisCache.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll failed: %v", err)
+	}
+	if allowed, _, _ := shared[0].Allow("alice"); allowed {
+		t.Errorf("Expected alice's bucket to survive DeleteAll")
+	}
+}
+
+// 3. Test 429 and Retry-After on the HTTP API
+func TestRateLimitHTTP(t *testing.T) {
+	limiters := map[string]ratelimit.Limiter{"cache": ratelimit.NewLocal(ratelimit.Rule{Rate: 0.1, Burst: 2})}
+	multiCache := multicache.NewMultiCache(setupTestInMemoryCache(), nil)
+	server := httptest.NewServer(api.SetupUnifiedRoutes(multiCache, ratelimit.Middleware(limiters, ratelimit.Identity)))
+	defer server.Close()
+
+	c := client.New(server.URL, 2*time.Second)
+	for i := 0; i < 2; i++ {
+		if err := c.Cache().Set(ctx, "key", "value", 10*time.Second); err != nil {
+			t.Errorf("Expected request %d within the burst, got %v", i, err)
+		}
+	}
+	_, err := c.Cache().Get(ctx, "key")
+	var apiErr *client.APIError
+	if !errors.Is(err, client.ErrRateLimited) || !errors.As(err, &apiErr) || apiErr.RetryAfter < time.Second {
+		t.Errorf("Expected ErrRateLimited with Retry-After, got %v", err)
+	}
+	resp, err := http.Get(server.URL + "/health")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected /health to stay unlimited, got %v, %v", resp, err)
+	} else {
+		resp.Body.Close()
+	}
+}
+
+// 4. Test Limits Before Authentication, Spoofed X-Forwarded-For and the Redis Breaker
+func TestRateLimitClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authenticator, _ := auth.Config{APIKeys: []auth.KeyConfig{{Name: "ops", Key: "k-ops", Role: "admin"}}}.Authenticator()
+	byIP := map[string]ratelimit.Limiter{"*": ratelimit.NewLocal(ratelimit.Rule{Rate: 0.1, Burst: 2})}
+	r := api.SetupUnifiedRoutes(multicache.NewMultiCache(setupTestInMemoryCache(), nil),
+		ratelimit.Middleware(byIP, ratelimit.ClientIP), auth.Middleware(authenticator, api.Access))
+	r.SetTrustedProxies(nil)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	expected := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusTooManyRequests}
+	for i, status := range expected {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/cache/key", nil)
+		req.Header.Set("X-API-Key", "wrong")
+		req.Header.Set("X-Forwarded-For", "10.0.0."+strconv.Itoa(i)) //not a trusted proxy, ignored
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request %d failed: %v", i, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("Expected %d for request %d, got %d", status, i, resp.StatusCode)
+		}
+	}
+
+	//a redis that accepts connections but never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close() //held open until the listener closes
+		}
+	}()
+	limiter := ratelimit.NewRedis(redis.NewClient(&redis.Options{Addr: listener.Addr().String()}), ratelimit.Rule{Rate: 1, Burst: 100})
+	for i := 0; i < 5; i++ { //trips the breaker
+		start := time.Now()
+		if allowed, _, _ := limiter.Allow("alice"); !allowed || time.Since(start) > time.Second {
+			t.Errorf("Expected a bounded fallback while redis hangs, got %v after %v", allowed, time.Since(start))
+		}
+	}
+	start := time.Now()
+	if allowed, _, _ := limiter.Allow("alice"); !allowed || time.Since(start) > 50*time.Millisecond {
+		t.Errorf("Expected the local fallback without waiting once the breaker is open, got %v after %v", allowed, time.Since(start))
+	}
+}
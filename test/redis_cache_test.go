@@ -2,6 +2,7 @@ package test
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"testing"
 	"time"
@@ -218,18 +219,18 @@ func TestRedisLargeValue(t *testing.T) {
 	}
 }
 
-// 9. Test Immediate Expiration
-func TestRedisImmediateExpiration(t *testing.T) {
+// 9. Test No Expiration
+func TestRedisNoExpiration(t *testing.T) {
 	cache := setupRedisTestCache()
 
-	err := cache.Set("key1", "value1", 0)
-	if err == nil {
-		t.Errorf("Expected error when setting with immediate expiration, got nil")
+	if err := cache.Set("key1", "value1", 0); err != nil {
+		t.Errorf("Expected no error when setting without expiration, got %v", err)
 	}
-
-	_, err = cache.Get("key1")
-	if err == nil {
-		t.Errorf("Expected error when getting immediately expired key, got nil")
+	if _, err := cache.Get("key1"); err != nil {
+		t.Errorf("Expected key1 to never expire, got %v", err)
+	}
+	if err := cache.Set("key2", "value2", -time.Second); !errors.Is(err, redis_cache.ErrNegativeTTL) {
+		t.Errorf("Expected ErrNegativeTTL for a negative ttl, got %v", err)
 	}
 }
 
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"unified/cache_store"
+	"unified/in_memory"
+	"unified/resp_server"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func setupRespServer(t *testing.T) (*in_memory.LRUCache, string) {
+	cache := in_memory.NewLRUCache(10, 60)
+	server := resp_server.NewServer(cache_store.InMemoryStore(cache), time.Minute)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return cache, listener.Addr().String()
+}
+
+// 1. Test Commands Over RESP3 and RESP2
+func TestRespServerCommands(t *testing.T) {
+	for _, protocol := range []int{3, 2} {
+		cache, addr := setupRespServer(t)
+		client := redis.NewClient(&redis.Options{Addr: addr, Protocol: protocol})
+		defer client.Close()
+
+		if pong, err := client.Ping(ctx).Result(); err != nil || pong != "PONG" {
+			t.Errorf("Expected PONG over RESP%d, got %q, %v", protocol, pong, err)
+		}
+
+		if err := client.Set(ctx, "key1", "value1", 10*time.Second).Err(); err != nil {
+			t.Errorf("Expected SET to succeed, got %v", err)
+		}
+		if value, ok := cache.Get("key1"); !ok || value != "value1" {
+			t.Errorf("Expected 'value1' in the in-memory cache, got %v", value)
+		}
+		if value, err := client.Get(ctx, "key1").Result(); err != nil || value != "value1" {
+			t.Errorf("Expected 'value1', got %q, %v", value, err)
+		}
+		if _, err := client.Get(ctx, "missing").Result(); err != redis.Nil {
+			t.Errorf("Expected redis.Nil for a missing key over RESP%d, got %v", protocol, err)
+		}
+
+		ttl, err := client.TTL(ctx, "key1").Result()
+		if err != nil || ttl <= 8*time.Second || ttl > 10*time.Second {
+			t.Errorf("Expected TTL close to 10s, got %v, %v", ttl, err)
+		}
+		if ttl, _ := client.TTL(ctx, "missing").Result(); ttl != -2 {
+			t.Errorf("Expected TTL -2 for a missing key, got %v", ttl)
+		}
+
+		client.Set(ctx, "key2", "value2", 0) //falls back to the server default ttl
+		values, err := client.MGet(ctx, "key1", "missing", "key2").Result()
+		if err != nil || len(values) != 3 || values[0] != "value1" || values[1] != nil || values[2] != "value2" {
+			t.Errorf("Expected [value1 <nil> value2], got %v, %v", values, err)
+		}
+
+		if deleted, err := client.Del(ctx, "key1", "missing").Result(); err != nil || deleted != 1 {
+			t.Errorf("Expected DEL to remove 1 key, got %d, %v", deleted, err)
+		}
+		if err := client.FlushDB(ctx).Err(); err != nil {
+			t.Errorf("Expected FLUSHDB to succeed, got %v", err)
+		}
+		if len(cache.GetAll()) != 0 {
+			t.Errorf("Expected the cache to be empty after FLUSHDB")
+		}
+	}
+}
+
+// 2. Test SET Expiry Options
+func TestRespServerExpiry(t *testing.T) {
+	_, addr := setupRespServer(t)
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+
+	if err := client.Do(ctx, "SET", "key1", "value1", "PX", "1500").Err(); err != nil {
+		t.Errorf("Expected SET PX to succeed, got %v", err)
+	}
+	if pttl, err := client.PTTL(ctx, "key1").Result(); err != nil || pttl <= 0 || pttl > 2*time.Second {
+		t.Errorf("Expected PTTL under 2s, got %v, %v", pttl, err)
+	}
+	if err := client.Do(ctx, "SET", "key1", "value1", "EX", "0").Err(); err == nil {
+		t.Errorf("Expected an error for a zero expire time")
+	}
+	if err := client.Do(ctx, "SET", "key1", "value1", "NX").Err(); err == nil {
+		t.Errorf("Expected a syntax error for an unsupported option")
+	}
+	if err := client.Do(ctx, "NOSUCHCOMMAND").Err(); err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("Expected an unknown command error, got %v", err)
+	}
+}
+
+// 3. Test Oversized Commands Are Refused Before Allocating
+func TestRespServerLimits(t *testing.T) {
+	cache := in_memory.NewLRUCache(10, 60)
+	server := resp_server.NewServer(cache_store.InMemoryStore(cache), time.Minute)
+	server.SetMaxBulkSize(64)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	for name, request := range map[string]string{
+		"announced bulk":  "*1\r\n$536870911\r\n",
+		"bulk over limit": "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$65\r\n",
+		"long line":       strings.Repeat("x", 70*1024) + "\r\n",
+	} {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		conn.Write([]byte(request))
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil || line != "-ERR Protocol error\r\n" {
+			t.Errorf("Expected a protocol error for %s, got %q, %v", name, line, err)
+		}
+		conn.Close()
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String(), Protocol: 2})
+	defer client.Close()
+	if err := client.Set(ctx, "key1", strings.Repeat("v", 64), 0).Err(); err != nil {
+		t.Errorf("Expected a value at the limit to be accepted, got %v", err)
+	}
+}
+
+// 4. Test Inline Commands
+func TestRespServerInline(t *testing.T) {
+	_, addr := setupRespServer(t)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	conn.Write([]byte("SET key1 value1\r\nGET key1\r\n"))
+	for _, expected := range []string{"+OK\r\n", "$6\r\n", "value1\r\n"} {
+		line, err := reader.ReadString('\n')
+		if err != nil || line != expected {
+			t.Errorf("Expected %q, got %q, %v", expected, line, err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package test
+
+import (
+	"errors"
+	"math"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	api "unified/api_handler"
+	"unified/client"
+	"unified/in_memory"
+	"unified/multicache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 1. Test Glob Matching
+func TestScanMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, key string
+		match        bool
+	}{
+		{"user:*", "user:1", true},
+		{"user:*", "session:1", false},
+		{"*:1", "user:1", true},
+		{"user:?", "user:10", false},
+		{"user:[0-4]", "user:3", true},
+		{"user:[^0-4]", "user:3", false},
+		{`a\*b`, "a*b", true},
+		{`a\*b`, "axb", false},
+		{in_memory.GlobPrefix("odd[*"), "odd[*key", true},
+		{in_memory.GlobPrefix("odd[*"), "oddxkey", false},
+	}
+	for _, tc := range cases {
+		if got := in_memory.MatchGlob(tc.pattern, tc.key); got != tc.match {
+			t.Errorf("MatchGlob(%q, %q) = %v, expected %v", tc.pattern, tc.key, got, tc.match)
+		}
+	}
+}
+
+// 2. Test In-Memory Cursor Pagination
+func TestScanInMemory(t *testing.T) {
+	cache := in_memory.NewLRUCache(100, 60)
+	for i := 0; i < 25; i++ {
+		cache.Set("user:"+strconv.Itoa(i), i, 10*time.Second)
+		cache.Set("other:"+strconv.Itoa(i), i, 10*time.Second)
+	}
+
+	seen := make(map[string]bool)
+	cursor, pages := in_memory.ScanStart, 0
+	for {
+		values, next, err := cache.Scan(cursor, "user:*", 10)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		pages++
+		for key := range values {
+			if seen[key] {
+				t.Errorf("Expected %s once, got it twice", key)
+			}
+			seen[key] = true
+		}
+		if len(values) > 10 {
+			t.Errorf("Expected at most 10 items per page, got %d", len(values))
+		}
+		if cursor = next; cursor == in_memory.ScanStart {
+			break
+		}
+		cache.Set("user:new"+strconv.Itoa(pages), pages, 10*time.Second) //writes during a scan are allowed
+	}
+	for i := 0; i < 25; i++ {
+		if !seen["user:"+strconv.Itoa(i)] {
+			t.Errorf("Expected user:%d to be scanned", i)
+		}
+	}
+	if pages != 3 {
+		t.Errorf("Expected 3 pages, got %d", pages)
+	}
+	if values, next, _ := cache.Scan(in_memory.ScanStart, "", math.MaxInt); len(values) != 52 || next != in_memory.ScanStart {
+		t.Errorf("Expected a count above the cache size to return every key at once, got %d items and cursor %q", len(values), next)
+	}
+	if _, _, err := cache.Scan("bogus", "", 10); !errors.Is(err, in_memory.ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+// 3. Test Redis Scan
+func TestScanRedis(t *testing.T) {
+	cache := setupTestRedisCache()
+	for i := 0; i < 8; i++ {
+		cache.Set("user:"+strconv.Itoa(i), "v"+strconv.Itoa(i), 10*time.Second)
+	}
+	cache.Set("other", "x", 10*time.Second)
+
+	seen := make(map[string]interface{})
+	cursor := "0"
+	for {
+		values, next, err := cache.Scan(cursor, "user:*", 3)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		for key, value := range values {
+			seen[key] = value
+		}
+		if cursor = next; cursor == "0" {
+			break
+		}
+	}
+	if len(seen) != 8 || seen["user:3"] != "v3" {
+		t.Errorf("Expected the 8 user keys with their values, got %v", seen)
+	}
+	all, _, _ := cache.Scan("0", "", 1000)
+	if _, ok := all["cache_keys"]; ok {
+		t.Errorf("Expected the LRU list to be skipped")
+	}
+}
+
+// 4. Test Scan Query Parameters
+func TestScanHTTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	multiCache := multicache.NewMultiCache(in_memory.NewLRUCache(100, 60), nil)
+	server := httptest.NewServer(api.SetupUnifiedRoutes(multiCache))
+	defer server.Close()
+	for i := 0; i < 15; i++ {
+		multiCache.Set("user:"+strconv.Itoa(i), i, 10*time.Second)
+	}
+	multiCache.Set("other", "x", 10*time.Second)
+
+	cache := client.New(server.URL, 2*time.Second).Cache()
+	values, cursor, err := cache.Scan(ctx, "0", "user:*", 10)
+	if err != nil || len(values) != 10 || cursor == "0" {
+		t.Fatalf("Expected a first page of 10 and a cursor, got %d, %q, %v", len(values), cursor, err)
+	}
+	values, cursor, err = cache.Scan(ctx, cursor, "user:*", 10)
+	if err != nil || len(values) != 5 || cursor != "0" {
+		t.Errorf("Expected a last page of 5, got %d, %q, %v", len(values), cursor, err)
+	}
+	if _, _, err := cache.Scan(ctx, "not-a-cursor", "", 10); !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest for a bad cursor, got %v", err)
+	}
+	if _, _, err := cache.Scan(ctx, "0", "", 5000); !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest for a page size over 1000, got %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	api "unified/api_handler"
+	"unified/cache_store"
+	"unified/events"
+	"unified/grpc_api"
+	"unified/grpc_api/cachepb"
+	"unified/health"
+	"unified/in_memory"
+	"unified/memcache_server"
+	"unified/multicache"
+	"unified/namespace"
+	"unified/redis_cache"
+	"unified/resp_server"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// 1. Test Close Flushes or Reports Queued Writes
+func TestShutdownMultiCacheClose(t *testing.T) {
+	cache := multicache.NewMultiCache(setupTestInMemoryCache(), setupTestRedisCache())
+	if err := cache.EnableInvalidation("shutdown_test"); err != nil {
+		t.Fatalf("Failed to enable invalidation: %v", err)
+	}
+	cache.Set("key", "value", 10*time.Second)
+	if err := cache.Close(); err != nil {
+		t.Errorf("Expected a clean close with redis up, got %v", err)
+	}
+
+	down := multicache.NewMultiCache(setupTestInMemoryCache(), redis_cache.Connect("localhost:6390", "", 0, 10)) // nothing listens here
+	down.ConfigureBreaker(1, time.Minute, time.Minute)
+	down.SetWritePolicy(multicache.WriteQueue, 10)
+	down.Set("key1", "value1", 10*time.Second)
+	down.Delete("key2")
+	err := down.Close()
+	if !errors.Is(err, multicache.ErrRedisUnavailable) {
+		t.Errorf("Expected the queued writes to be reported lost, got %v", err)
+	}
+}
+
+// 2. Test Close Syncs the Append-Only Log
+func TestShutdownInMemoryClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.aof")
+	cache := in_memory.NewLRUCache(10, 60)
+	if _, err := cache.OpenAppendLog(path, in_memory.FsyncNever, time.Minute); err != nil {
+		t.Fatalf("Failed to open append-only log: %v", err)
+	}
+	cache.Set("key", "value", 0)
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if err := cache.Close(); err != nil {
+		t.Errorf("Expected a second Close to be a no-op, got %v", err)
+	}
+	if value, ok := cache.Get("key"); !ok || value != "value" {
+		t.Errorf("Expected the cache to keep serving after Close, got %v", value)
+	}
+
+	reopened := in_memory.NewLRUCache(10, 60)
+	defer reopened.Close()
+	if replayed, err := reopened.OpenAppendLog(path, in_memory.FsyncNever, time.Minute); err != nil || replayed != 1 {
+		t.Errorf("Expected 1 replayed operation, got %d, %v", replayed, err)
+	}
+}
+
+// 3. Test Readiness Fails Once Stopping
+func TestShutdownReadiness(t *testing.T) {
+	readiness := health.NewReadiness()
+	readiness.MarkStarted()
+	server := probeServer(readiness, multicache.NewMultiCache(setupTestInMemoryCache(), nil))
+	defer server.Close()
+
+	readiness.MarkStopping()
+	status, body := probe(t, server.URL+"/readyz")
+	checks, _ := body["checks"].(map[string]interface{})
+	if status != http.StatusServiceUnavailable || checks["shutdown"] != "stopping" {
+		t.Errorf("Expected /readyz 503 while stopping, got %d %v", status, body)
+	}
+	if status, _ := probe(t, server.URL+"/healthz"); status != http.StatusOK {
+		t.Errorf("Expected /healthz 200 while draining, got %d", status)
+	}
+}
+
+// 4. Test Closing the Event Buses Ends Streams
+func TestShutdownEventStreams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	multiCache := multicache.NewMultiCache(setupTestInMemoryCache(), nil)
+	server := httptest.NewServer(api.SetupUnifiedRoutes(multiCache))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/_events")
+	if err != nil {
+		t.Fatalf("Failed to open the event stream: %v", err)
+	}
+	defer resp.Body.Close()
+	grpcServer := grpc_api.NewServer(multiCache)
+	listener := bufconn.Listen(1024 * 1024)
+	go grpcServer.Serve(listener)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial bufnet: %v", err)
+	}
+	defer conn.Close()
+	watch, err := cachepb.NewCacheClient(conn).Watch(ctx, &cachepb.WatchRequest{})
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	multiCache.Events().Close() //streams opened before or after end
+	drain, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := server.Config.Shutdown(drain); err != nil {
+		t.Errorf("Expected the HTTP server to drain once the stream ended, got %v", err)
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Errorf("Expected the event stream to end cleanly, got %v", err)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-drain.Done():
+		t.Errorf("Expected GracefulStop to return once the watch ended")
+		grpcServer.Stop()
+	}
+	if _, err := watch.Recv(); err != io.EOF {
+		t.Errorf("Expected the watch to end, got %v", err)
+	}
+}
+
+// 5. Test Closing the Namespace Event Buses Ends Streams
+func TestShutdownNamespaceEventStreams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := namespace.NewRegistry(10, nil)
+	r := api.SetupUnifiedRoutes(multicache.NewMultiCache(setupTestInMemoryCache(), nil))
+	api.SetupNamespaceRoutes(r, registry)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	if _, err := registry.Get("x"); err != nil {
+		t.Fatalf("Failed to create the namespace: %v", err)
+	}
+	resp, err := http.Get(server.URL + "/ns/x/_events")
+	if err != nil {
+		t.Fatalf("Failed to open the event stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	registry.CloseEvents()
+	drain, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := server.Config.Shutdown(drain); err != nil {
+		t.Errorf("Expected the HTTP server to drain once the namespace stream ended, got %v", err)
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Errorf("Expected the event stream to end cleanly, got %v", err)
+	}
+
+	late, err := registry.Get("y") //created while draining
+	if err != nil {
+		t.Fatalf("Failed to create the namespace: %v", err)
+	}
+	for _, bus := range []*events.Bus{late.Cache.Events(), late.Memory.Events()} {
+		if _, ok := <-bus.Subscribe("", 1).Events(); ok {
+			t.Errorf("Expected the buses of a namespace created after CloseEvents to be closed")
+		}
+	}
+}
+
+// slowStore delays writes so a command is still running when shutdown begins
+type slowStore struct {
+	cache_store.Store
+	started chan struct{}
+}
+
+func (s slowStore) Set(key string, value interface{}, ttl time.Duration) error {
+	close(s.started)
+	time.Sleep(200 * time.Millisecond)
+	return s.Store.Set(key, value, ttl)
+}
+
+// 6. Test Protocol Servers Drain Commands in Flight
+func TestShutdownProtocolServers(t *testing.T) {
+	servers := []struct {
+		name     string
+		new      func(cache_store.Store) (serve func(net.Listener) error, shutdown func(context.Context) error)
+		request  string
+		expected string
+	}{
+		{"resp", func(store cache_store.Store) (func(net.Listener) error, func(context.Context) error) {
+			server := resp_server.NewServer(store, 0)
+			return server.Serve, server.Shutdown
+		}, "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$1\r\na\r\n", "+OK"},
+		{"memcache", func(store cache_store.Store) (func(net.Listener) error, func(context.Context) error) {
+			server := memcache_server.NewServer(store, 0)
+			return server.Serve, server.Shutdown
+		}, "set key 0 0 1\r\na\r\n", "STORED"},
+	}
+	for _, test := range servers {
+		store := slowStore{Store: cache_store.InMemoryStore(in_memory.NewLRUCache(10, 60)), started: make(chan struct{})}
+		serve, shutdown := test.new(store)
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		go serve(listener)
+		busy, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer busy.Close()
+		idle, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer idle.Close()
+
+		busy.Write([]byte(test.request))
+		<-store.started
+		drain, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := shutdown(drain); err != nil {
+			t.Errorf("Expected %s to drain, got %v", test.name, err)
+		}
+		busy.SetDeadline(time.Now().Add(2 * time.Second))
+		reply, err := bufio.NewReader(busy).ReadString('\n')
+		if strings.TrimSpace(reply) != test.expected {
+			t.Errorf("Expected %s to answer the command in flight with %s, got %q, %v", test.name, test.expected, reply, err)
+		}
+		idle.SetDeadline(time.Now().Add(2 * time.Second))
+		if _, err := idle.Read(make([]byte, 1)); err != io.EOF {
+			t.Errorf("Expected %s to close the idle connection, got %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	api "unified/api_handler"
+	"unified/client"
+	"unified/in_memory"
+	"unified/multicache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// 1. Test In-Memory Tag Index
+func TestTagsInMemory(t *testing.T) {
+	cache := in_memory.NewLRUCache(3, 60)
+	cache.SetWithTags("order:1", "a", 10*time.Second, "customer:1")
+	cache.SetWithTags("order:2", "b", 10*time.Second, "customer:1", "customer:2")
+	cache.SetWithTags("order:3", "c", 10*time.Second, "customer:2")
+
+	cache.Set("order:3", "c2", 10*time.Second) //a plain set replaces the tags
+	if keys := cache.TaggedKeys("customer:2"); !reflect.DeepEqual(keys, []string{"order:2"}) {
+		t.Errorf("Expected [order:2] tagged customer:2, got %v", keys)
+	}
+
+	keys := cache.InvalidateTag("customer:1")
+	if !reflect.DeepEqual(keys, []string{"order:1", "order:2"}) {
+		t.Errorf("Expected order:1 and order:2 to be invalidated, got %v", keys)
+	}
+	if _, ok := cache.Get("order:2"); ok {
+		t.Errorf("Expected order:2 to be deleted")
+	}
+	if _, ok := cache.Get("order:3"); !ok {
+		t.Errorf("Expected order:3 to be kept")
+	}
+	if keys := cache.TaggedKeys("customer:2"); len(keys) != 0 {
+		t.Errorf("Expected deleted keys to leave their other tags, got %v", keys)
+	}
+
+	cache.SetWithTags("a", 1, 10*time.Second, "evicted")
+	cache.Set("b", 2, 10*time.Second)
+	cache.Set("c", 3, 10*time.Second) //evicts order:3
+	cache.Set("d", 4, 10*time.Second) //evicts a
+	if keys := cache.TaggedKeys("evicted"); len(keys) != 0 {
+		t.Errorf("Expected eviction to drop the key from its tags, got %v", keys)
+	}
+}
+
+// 2. Test Redis Tag Sets
+func TestTagsRedis(t *testing.T) {
+	cache := setupTestRedisCache()
+	cache.SetWithTags("order:1", "a", 10*time.Second, "customer:1")
+	cache.SetWithTags("order:2", "b", time.Minute, "customer:1")
+	cache.Set("order:3", "c", 10*time.Second)
+
+	if ttl := cache.Client.TTL(ctx, "cache_tags:customer:1").Val(); ttl <= 50*time.Second {
+		t.Errorf("Expected the tag set to live as long as its longest key, got %v", ttl)
+	}
+	values, _, err := cache.Scan("0", "", 100)
+	if err != nil || len(values) != 3 {
+		t.Errorf("Expected Scan to skip tag sets, got %v, %v", values, err)
+	}
+
+	keys, err := cache.InvalidateTag("customer:1")
+	if err != nil || !reflect.DeepEqual(keys, []string{"order:1", "order:2"}) {
+		t.Errorf("Expected order:1 and order:2 to be invalidated, got %v, %v", keys, err)
+	}
+	if _, err := cache.Get("order:1"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Expected order:1 to be deleted, got %v", err)
+	}
+	if _, err := cache.Get("order:3"); err != nil {
+		t.Errorf("Expected order:3 to be kept, got %v", err)
+	}
+	if exists := cache.Client.Exists(ctx, "cache_tags:customer:1").Val(); exists != 0 {
+		t.Errorf("Expected the tag set to be removed")
+	}
+}
+
+// 3. Test Tag Invalidation Across Tiers and HTTP
+func TestTagsHTTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mem := setupTestInMemoryCache()
+	rc := setupTestRedisCache()
+	multiCache := multicache.NewMultiCache(mem, rc)
+	server := httptest.NewServer(api.SetupUnifiedRoutes(multiCache))
+	defer server.Close()
+	cache := client.New(server.URL, 2*time.Second).Cache()
+
+	if err := cache.SetWithTags(ctx, "order:1", "a", 10*time.Second, "customer:1"); err != nil {
+		t.Fatalf("SetWithTags failed: %v", err)
+	}
+	rc.SetWithTags("order:2", "b", 10*time.Second, "customer:1") //written by another replica
+	cache.Set(ctx, "order:3", "c", 10*time.Second)
+
+	keys, err := cache.InvalidateTag(ctx, "customer:1")
+	if err != nil || !reflect.DeepEqual(keys, []string{"order:1", "order:2"}) {
+		t.Errorf("Expected order:1 and order:2 to be invalidated, got %v, %v", keys, err)
+	}
+	if _, ok := mem.Get("order:1"); ok {
+		t.Errorf("Expected order:1 to be deleted from memory")
+	}
+	if _, err := rc.Get("order:1"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Expected order:1 to be deleted from redis, got %v", err)
+	}
+	if _, err := cache.Get(ctx, "order:3"); err != nil {
+		t.Errorf("Expected order:3 to be kept, got %v", err)
+	}
+	if keys, err := cache.InvalidateTag(ctx, "unknown"); err != nil || len(keys) != 0 {
+		t.Errorf("Expected no keys for an unknown tag, got %v, %v", keys, err)
+	}
+}
+
+// 4. Test Redis Tags on Overwrite, Expire and Persist
+func TestTagsRedisUpdates(t *testing.T) {
+	cache := setupTestRedisCache()
+	cache.SetWithTags("order:1", "a", 10*time.Second, "customer:1")
+	cache.Set("order:1", "b", 10*time.Second) //no longer tagged
+	cache.SetWithTags("order:2", "c", 10*time.Second, "customer:1")
+	cache.SetWithTags("order:2", "d", 10*time.Second, "customer:2") //moved to another tag
+
+	if members := cache.Client.SMembers(ctx, "cache_tags:customer:1").Val(); len(members) != 0 {
+		t.Errorf("Expected overwritten keys to leave the tag set, got %v", members)
+	}
+	keys, err := cache.InvalidateTag("customer:1")
+	if err != nil || len(keys) != 0 {
+		t.Errorf("Expected no keys for customer:1, got %v, %v", keys, err)
+	}
+	if value, err := cache.Get("order:1"); err != nil || value != "b" {
+		t.Errorf("Expected order:1 to be kept, got %v, %v", value, err)
+	}
+
+	cache.SetWithTags("order:3", "e", 10*time.Second, "customer:3")
+	if ok, err := cache.Expire("order:3", time.Minute); !ok || err != nil {
+		t.Fatalf("Expire failed: %v, %v", ok, err)
+	}
+	if ttl := cache.Client.TTL(ctx, "cache_tags:customer:3").Val(); ttl <= 50*time.Second {
+		t.Errorf("Expected Expire to extend the tag set, got %v", ttl)
+	}
+	if ok, err := cache.Persist("order:3"); !ok || err != nil {
+		t.Fatalf("Persist failed: %v, %v", ok, err)
+	}
+	if ttl := cache.Client.TTL(ctx, "cache_tags:customer:3").Val(); ttl != -1 {
+		t.Errorf("Expected Persist to keep the tag set forever, got %v", ttl)
+	}
+	if ok, _ := cache.Persist("missing"); ok {
+		t.Errorf("Expected Persist of a missing key to report false")
+	}
+	keys, err = cache.InvalidateTag("customer:3")
+	if err != nil || !reflect.DeepEqual(keys, []string{"order:3"}) {
+		t.Errorf("Expected order:3 to be invalidated, got %v, %v", keys, err)
+	}
+	if exists := cache.Client.Exists(ctx, "cache_keytags:order:3").Val(); exists != 0 {
+		t.Errorf("Expected the tags of order:3 to be removed")
+	}
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	api "unified/api_handler"
+	"unified/multicache"
+	"unified/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// 1. Test Spans of a Traced Request
+func TestTracingSpans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(previous)
+
+	redisCache := setupTestRedisCache()
+	tracing.InstrumentRedis(redisCache.Client)
+	multiCache := multicache.NewMultiCache(setupTestInMemoryCache(), redisCache)
+	multiCache.Set("key", "value", 10*time.Second)
+	server := httptest.NewServer(api.SetupUnifiedRoutes(multiCache, otelgin.Middleware(tracing.ServiceName)))
+	defer server.Close()
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/cache/key", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	parents := make(map[string]string) //span name to parent span name
+	names := make(map[string]string)   //span id to name
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() != traceID {
+			continue
+		}
+		names[span.SpanContext().SpanID().String()] = span.Name()
+	}
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() == traceID {
+			parents[span.Name()] = names[span.Parent().SpanID().String()]
+		}
+	}
+	expected := map[string]string{
+		"/cache/:key":    "",
+		"MultiCache.Get": "/cache/:key",
+		"tier memory":    "MultiCache.Get",
+		"tier redis":     "MultiCache.Get",
+		"redis get":      "tier redis",
+	}
+	for name, parent := range expected {
+		if got, ok := parents[name]; !ok || got != parent {
+			t.Errorf("Expected span %q under %q in the incoming trace, got %q (%v)", name, parent, got, ok)
+		}
+	}
+	for _, span := range recorder.Ended() {
+		if span.Name() == "redis set" {
+			t.Errorf("Expected the untraced Set to record no redis span")
+		}
+	}
+}
+
+// 2. Test Every Handler Passes the Request Context
+func TestTracingHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	redisCache := setupTestRedisCache()
+	tracing.InstrumentRedis(redisCache.Client)
+	inMemoryCache := setupTestInMemoryCache()
+	multiCache := multicache.NewMultiCache(inMemoryCache, redisCache)
+	r := api.SetupUnifiedRoutes(multiCache, otelgin.Middleware(tracing.ServiceName))
+	r1 := gin.New()
+	r1.Use(otelgin.Middleware(tracing.ServiceName))
+	api.SetupInMemoryRoutes(r1, inMemoryCache)
+	api.SetupRedisRoutes(r1, redisCache)
+
+	requests := []struct {
+		router *gin.Engine
+		method string
+		path   string
+		spans  []string
+	}{
+		{r, http.MethodGet, "/cache", []string{"MultiCache.GetAll", "tier redis"}},
+		{r, http.MethodGet, "/cache?cursor=0", []string{"MultiCache.Scan", "tier redis"}},
+		{r, http.MethodGet, "/_diff", []string{"MultiCache.Diff"}},
+		{r, http.MethodDelete, "/cache/tags/tag", []string{"MultiCache.InvalidateTag", "tier redis"}},
+		{r, http.MethodDelete, "/cache", []string{"MultiCache.DeleteAll", "tier redis"}},
+		{r1, http.MethodGet, "/redis/", []string{"redis lrange"}},
+		{r1, http.MethodDelete, "/redis/key", []string{"redis del"}},
+		{r1, http.MethodGet, "/inmemory/key", []string{"tier memory"}},
+		{r1, http.MethodDelete, "/inmemory", []string{"tier memory"}},
+	}
+	for _, test := range requests {
+		before := len(recorder.Ended())
+		req := httptest.NewRequest(test.method, test.path, nil)
+		test.router.ServeHTTP(httptest.NewRecorder(), req)
+
+		traces := make(map[string]bool) //trace ids of the spans recorded
+		names := make(map[string]bool)
+		for _, span := range recorder.Ended()[before:] {
+			traces[span.SpanContext().TraceID().String()] = true
+			names[span.Name()] = true
+		}
+		if len(traces) != 1 {
+			t.Errorf("Expected %s %s to record a single trace, got %d", test.method, test.path, len(traces))
+		}
+		for _, name := range test.spans {
+			if !names[name] {
+				t.Errorf("Expected %s %s to record span %q, got %v", test.method, test.path, name, names)
+			}
+		}
+	}
+}
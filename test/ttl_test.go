@@ -0,0 +1,169 @@
+package test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	api "unified/api_handler"
+	"unified/client"
+	"unified/in_memory"
+	"unified/multicache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// 1. Test In-Memory Expire and Persist
+func TestTTLInMemory(t *testing.T) {
+	cache := setupTestInMemoryCache()
+	cache.Set("key1", "value1", 10*time.Second)
+
+	if ok := cache.Expire("key1", time.Hour); !ok {
+		t.Fatalf("Expected Expire to find key1")
+	}
+	if ttl, ok := cache.TTL("key1"); !ok || ttl <= 59*time.Minute {
+		t.Errorf("Expected TTL close to 1h, got %v, %v", ttl, ok)
+	}
+	if ok := cache.Persist("key1"); !ok {
+		t.Errorf("Expected Persist to find key1")
+	}
+	if ttl, ok := cache.TTL("key1"); !ok || ttl != in_memory.NoExpiry {
+		t.Errorf("Expected NoExpiry after Persist, got %v, %v", ttl, ok)
+	}
+	if value, ok := cache.Get("key1"); !ok || value != "value1" {
+		t.Errorf("Expected the value to be kept, got %v, %v", value, ok)
+	}
+	if ok := cache.Expire("key1", 0); !ok {
+		t.Errorf("Expected Expire with 0 to delete key1")
+	}
+	if _, ok := cache.Get("key1"); ok {
+		t.Errorf("Expected key1 to be deleted")
+	}
+	if cache.Expire("missing", time.Second) || cache.Persist("missing") {
+		t.Errorf("Expected Expire and Persist to report missing keys")
+	}
+}
+
+// 2. Test Persistent Keys Survive Snapshots and the Append Log
+func TestTTLPersistentReload(t *testing.T) {
+	dir := t.TempDir()
+	cache := in_memory.NewLRUCache(10, 60)
+	cache.Set("forever", "value", 0)
+	cache.Set("short", "value", 10*time.Second)
+	if err := cache.SaveSnapshot(filepath.Join(dir, "cache.snapshot")); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+	restored := in_memory.NewLRUCache(10, 60)
+	if _, err := restored.LoadSnapshot(filepath.Join(dir, "cache.snapshot")); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if ttl, ok := restored.TTL("forever"); !ok || ttl != in_memory.NoExpiry {
+		t.Errorf("Expected the persistent key in the snapshot, got %v, %v", ttl, ok)
+	}
+
+	path := filepath.Join(dir, "cache.aof")
+	logged := in_memory.NewLRUCache(10, 60)
+	if _, err := logged.OpenAppendLog(path, in_memory.FsyncAlways, 0); err != nil {
+		t.Fatalf("OpenAppendLog failed: %v", err)
+	}
+	logged.Set("key1", "value1", 10*time.Second)
+	logged.Persist("key1")
+	logged.CloseAppendLog()
+	replayed := in_memory.NewLRUCache(10, 60)
+	if _, err := replayed.OpenAppendLog(path, in_memory.FsyncAlways, 0); err != nil {
+		t.Fatalf("OpenAppendLog failed: %v", err)
+	}
+	defer replayed.CloseAppendLog()
+	if ttl, ok := replayed.TTL("key1"); !ok || ttl != in_memory.NoExpiry {
+		t.Errorf("Expected Persist to be replayed, got %v, %v", ttl, ok)
+	}
+}
+
+// 3. Test Redis Expire and Persist
+func TestTTLRedis(t *testing.T) {
+	cache := setupTestRedisCache()
+	cache.Set("key1", "value1", 10*time.Second)
+
+	if ok, err := cache.Expire("key1", time.Hour); err != nil || !ok {
+		t.Fatalf("Expected Expire to find key1, got %v, %v", ok, err)
+	}
+	if ttl, err := cache.TTL("key1"); err != nil || ttl <= 59*time.Minute {
+		t.Errorf("Expected TTL close to 1h, got %v, %v", ttl, err)
+	}
+	if ok, err := cache.Persist("key1"); err != nil || !ok {
+		t.Errorf("Expected Persist to find key1, got %v, %v", ok, err)
+	}
+	if ttl, err := cache.TTL("key1"); err != nil || ttl != -1 {
+		t.Errorf("Expected no expiry after Persist, got %v, %v", ttl, err)
+	}
+	if ok, _ := cache.Expire("missing", time.Second); ok {
+		t.Errorf("Expected Expire to report a missing key")
+	}
+	if ok, _ := cache.Persist("missing"); ok {
+		t.Errorf("Expected Persist to report a missing key")
+	}
+}
+
+// 4. Test MultiCache Expire Across Tiers
+func TestTTLMultiCache(t *testing.T) {
+	mem := setupTestInMemoryCache()
+	rc := setupTestRedisCache()
+	multiCache := multicache.NewMultiCache(mem, rc)
+	multiCache.Set("key1", "value1", 0)
+
+	if ttl, err := multiCache.TTL("key1"); err != nil || ttl != in_memory.NoExpiry {
+		t.Errorf("Expected a key without expiry, got %v, %v", ttl, err)
+	}
+	if ok, err := multiCache.Expire("key1", time.Hour); err != nil || !ok {
+		t.Fatalf("Expected Expire to find key1, got %v, %v", ok, err)
+	}
+	if ttl, ok := mem.TTL("key1"); !ok || ttl <= 59*time.Minute {
+		t.Errorf("Expected the memory ttl to be updated, got %v", ttl)
+	}
+	if ttl, err := rc.TTL("key1"); err != nil || ttl <= 59*time.Minute {
+		t.Errorf("Expected the redis ttl to be updated, got %v, %v", ttl, err)
+	}
+	if ok, err := multiCache.Expire("key1", 0); err != nil || !ok {
+		t.Errorf("Expected Expire with 0 to delete key1, got %v, %v", ok, err)
+	}
+	if _, err := multiCache.Get("key1"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Expected key1 to be deleted, got %v", err)
+	}
+	if ok, err := multiCache.Persist("missing"); err != nil || ok {
+		t.Errorf("Expected Persist to report a missing key, got %v, %v", ok, err)
+	}
+}
+
+// 5. Test TTL Endpoints
+func TestTTLHTTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	multiCache := multicache.NewMultiCache(in_memory.NewLRUCache(10, 60), nil)
+	server := httptest.NewServer(api.SetupUnifiedRoutes(multiCache))
+	defer server.Close()
+	cache := client.New(server.URL, 2*time.Second).Cache()
+
+	if err := cache.Set(ctx, "key1", "value1", 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if ttl, err := cache.TTL(ctx, "key1"); err != nil || ttl >= 0 {
+		t.Errorf("Expected no expiry, got %v, %v", ttl, err)
+	}
+	if err := cache.Expire(ctx, "key1", 90*time.Second); err != nil {
+		t.Errorf("Expire failed: %v", err)
+	}
+	if ttl, err := cache.TTL(ctx, "key1"); err != nil || ttl <= 80*time.Second || ttl > 90*time.Second {
+		t.Errorf("Expected TTL close to 90s, got %v, %v", ttl, err)
+	}
+	if err := cache.Persist(ctx, "key1"); err != nil {
+		t.Errorf("Persist failed: %v", err)
+	}
+	if ttl, _ := cache.TTL(ctx, "key1"); ttl >= 0 {
+		t.Errorf("Expected no expiry after Persist, got %v", ttl)
+	}
+	if err := cache.Persist(ctx, "missing"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing key, got %v", err)
+	}
+}
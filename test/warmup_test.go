@@ -0,0 +1,121 @@
+package test
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"unified/in_memory"
+	"unified/warmup"
+)
+
+func entryKeys(entries []in_memory.Entry) []string {
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	return keys
+}
+
+// 1. Test Warm-up From Redis
+func TestWarmUpFromRedis(t *testing.T) {
+	redisCache := setupTestRedisCache()
+	for i := 0; i < 5; i++ {
+		key := "key" + strconv.Itoa(i)
+		if err := redisCache.Set(key, "value"+strconv.Itoa(i), 10*time.Second); err != nil {
+			t.Fatalf("Failed to set key: %v", err)
+		}
+	}
+
+	cache := in_memory.NewLRUCache(10, 60)
+	var reported int
+	loaded, err := warmup.FromRedis(cache, redisCache, 3, func(done int, total int) { reported = done })
+	if err != nil {
+		t.Fatalf("Failed to warm up: %v", err)
+	}
+	if loaded != 3 || reported != 3 {
+		t.Errorf("Expected 3 keys loaded and reported, got %d and %d", loaded, reported)
+	}
+
+	expected := []string{"key4", "key3", "key2"}
+	actual := entryKeys(cache.Entries())
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected keys %v, got %v", expected, actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("Expected key %s at position %d, got %s", expected[i], i, actual[i])
+		}
+	}
+	if value, _ := cache.Get("key4"); value != "value4" {
+		t.Errorf("Expected 'value4', got %v", value)
+	}
+}
+
+// 2. Test Warm-up From Snapshot
+func TestWarmUpFromSnapshot(t *testing.T) {
+	source := in_memory.NewLRUCache(10, 60)
+	for i := 0; i < 5; i++ {
+		source.Set("key"+strconv.Itoa(i), "value"+strconv.Itoa(i), 10*time.Second)
+	}
+	source.Get("key0") // most recently used
+
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	if err := source.SaveSnapshot(path); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	cache := in_memory.NewLRUCache(10, 60)
+	loaded, err := warmup.FromSnapshot(cache, path, 3, nil)
+	if err != nil {
+		t.Fatalf("Failed to warm up: %v", err)
+	}
+	if loaded != 3 {
+		t.Errorf("Expected 3 keys loaded, got %d", loaded)
+	}
+
+	expected := entryKeys(source.Entries())[:3]
+	actual := entryKeys(cache.Entries())
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("Expected key %s at position %d, got %s", expected[i], i, actual[i])
+		}
+	}
+}
+
+// 3. Test Loading Skips Writes Made Meanwhile
+func TestWarmUpSkipsNewerWrites(t *testing.T) {
+	cache := in_memory.NewLRUCache(10, 60)
+	defer cache.Close()
+	entries := []in_memory.Entry{
+		{Key: "written", Value: "stale", TTL: time.Minute},
+		{Key: "deleted", Value: "stale", TTL: time.Minute},
+		{Key: "untouched", Value: "loaded", TTL: time.Minute},
+	}
+
+	cache.StartLoading()
+	cache.Set("written", "new", 0)
+	cache.Delete("deleted")
+	if loaded := cache.Load(entries); loaded != 1 {
+		t.Errorf("Expected 1 key loaded, got %d", loaded)
+	}
+	if value, _ := cache.Get("written"); value != "new" {
+		t.Errorf("Expected the write made while loading to stay, got %v", value)
+	}
+	if _, found := cache.Get("deleted"); found {
+		t.Errorf("Expected the key deleted while loading to stay deleted")
+	}
+	if value, _ := cache.Get("untouched"); value != "loaded" {
+		t.Errorf("Expected untouched keys to load, got %v", value)
+	}
+
+	cache.DeleteAll()
+	if loaded := cache.Load(entries); loaded != 0 {
+		t.Errorf("Expected nothing loaded after a flush, got %d", loaded)
+	}
+	cache.FinishLoading()
+	if loaded := cache.Load(entries); loaded != 3 {
+		t.Errorf("Expected every key loaded once loading is finished, got %d", loaded)
+	}
+}
@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName names the service in exported spans and the HTTP server spans
+const ServiceName = "unified-cache"
+
+// Tracer of the cache packages, a no-op until Setup installs an exporter
+func Tracer() trace.Tracer {
+	return otel.Tracer("unified")
+}
+
+// Setup exports spans to stdout or to an OTLP/HTTP collector at endpoint (host:port, empty for the
+// OTEL_EXPORTER_OTLP_* environment) and propagates W3C trace context. Call shutdown to flush on exit
+func Setup(exporter string, endpoint string) (shutdown func(context.Context) error, err error) {
+	var spanExporter sdktrace.SpanExporter
+	switch exporter {
+	case "stdout":
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		var options []otlptracehttp.Option
+		if endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure())
+		}
+		spanExporter, err = otlptracehttp.New(context.Background(), options...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, want stdout or otlp", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Start begins a child of the span in ctx. Work outside a traced request, such as gRPC calls and janitors,
+// gets a no-op span rather than a trace of its own
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return Tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End finishes span, marking it failed when err is set
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
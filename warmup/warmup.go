@@ -0,0 +1,62 @@
+package warmup
+
+import (
+	"os"
+
+	"unified/in_memory"
+	"unified/redis_cache"
+)
+
+// Keys loaded per progress report
+const batchSize = 100
+
+// Progress is called after each batch with the number of entries processed so far
+type Progress func(done int, total int)
+
+// Preload the n most recently used keys from redis into the in-memory cache
+func FromRedis(cache *in_memory.LRUCache, rc *redis_cache.RedisCache, n int, progress Progress) (int, error) {
+	items, err := rc.Recent(n)
+	if err != nil {
+		return 0, err
+	}
+	entries := make([]in_memory.Entry, len(items))
+	for i, item := range items {
+		entries[i] = in_memory.Entry{Key: item.Key, Value: item.Value, TTL: item.TTL}
+	}
+	return load(cache, entries, progress), nil
+}
+
+// Preload the n most recently used keys from a snapshot written by LRUCache.Snapshot
+func FromSnapshot(cache *in_memory.LRUCache, path string, n int, progress Progress) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	entries, err := in_memory.ReadSnapshot(file)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	return load(cache, entries, progress), nil
+}
+
+// load inserts entries least recent first in batches, reporting progress after each
+func load(cache *in_memory.LRUCache, entries []in_memory.Entry, progress Progress) int {
+	total := len(entries)
+	loaded := 0
+	for end := total; end > 0; end -= batchSize {
+		start := end - batchSize
+		if start < 0 {
+			start = 0
+		}
+		loaded += cache.Load(entries[start:end])
+		if progress != nil {
+			progress(total-start, total)
+		}
+	}
+	return loaded
+}